@@ -0,0 +1,351 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// codePattern - attribute codes are used as query parameters and JSON keys
+var codePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// AttributeError - attribute values that failed validation, by attribute code
+type AttributeError struct {
+	Errors map[string]string
+}
+
+func (e *AttributeError) Error() string {
+	codes := make([]string, 0, len(e.Errors))
+	for code := range e.Errors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = code + " " + e.Errors[code]
+	}
+	return "Invalid attributes: " + strings.Join(parts, ", ")
+}
+
+// Options - the allowed values of an enum attribute
+func Options(def models.AttributeDefinition) []string {
+	if def.Options == "" {
+		return []string{}
+	}
+	return strings.Split(def.Options, "\n")
+}
+
+// Applies - whether the attribute can be set on products of the type
+func Applies(def models.AttributeDefinition, productType string) bool {
+	return def.ProductType == "" || def.ProductType == productType
+}
+
+// ValidateDefinition - checks the code, type and enum options of a definition
+func ValidateDefinition(def models.AttributeDefinition) error {
+	if !codePattern.MatchString(def.Code) {
+		return errors.New("code must start with a letter and contain only lowercase letters, digits and underscores")
+	}
+
+	switch def.Type {
+	case models.AttributeString, models.AttributeNumber, models.AttributeBool:
+		if def.Options != "" {
+			return errors.New("only enum attributes have options")
+		}
+	case models.AttributeEnum:
+		if def.Options == "" {
+			return errors.New("enum attributes need at least one option")
+		}
+		seen := map[string]bool{}
+		for _, option := range Options(def) {
+			if strings.TrimSpace(option) == "" {
+				return errors.New("options can not be empty")
+			}
+			if seen[option] {
+				return errors.New("duplicate option " + option)
+			}
+			seen[option] = true
+		}
+	default:
+		return errors.New("type must be one of string, number, bool, enum")
+	}
+
+	return nil
+}
+
+// CheckDefinitionChange - refuses changes that would leave stored values
+// invalid: a new type, removed enum options still in use, or a product type
+// that products with a value do not have.
+func CheckDefinitionChange(db *gorm.DB, previous models.AttributeDefinition, def models.AttributeDefinition) error {
+	values := func() *gorm.DB {
+		return db.Model(&models.ProductAttribute{}).Where("attribute_definition_id = ?", def.ID)
+	}
+
+	var count int64
+	values().Count(&count)
+	if count == 0 {
+		return nil
+	}
+
+	if def.Type != previous.Type {
+		return fmt.Errorf("type can not change while %d products have a value", count)
+	}
+
+	if def.Type == models.AttributeEnum {
+		var used []string
+		values().Distinct().Pluck("value", &used)
+
+		allowed := map[string]bool{}
+		for _, option := range Options(def) {
+			allowed[option] = true
+		}
+		for _, value := range used {
+			if !allowed[value] {
+				return errors.New("option " + value + " is still in use")
+			}
+		}
+	}
+
+	if def.ProductType != "" && def.ProductType != previous.ProductType {
+		var other int64
+		values().Joins("JOIN products ON products.id = product_attributes.product_id").
+			Where("products.product_type <> ?", def.ProductType).Count(&other)
+		if other > 0 {
+			return fmt.Errorf("%d products of another type have a value", other)
+		}
+	}
+
+	return nil
+}
+
+// parseValue - checks a JSON value against the definition and returns its text
+// form, and its number form for number attributes
+func parseValue(def models.AttributeDefinition, raw interface{}) (string, *float64, error) {
+	switch def.Type {
+	case models.AttributeNumber:
+		number, ok := raw.(float64)
+		if !ok {
+			return "", nil, errors.New("must be a number")
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), &number, nil
+
+	case models.AttributeBool:
+		flag, ok := raw.(bool)
+		if !ok {
+			return "", nil, errors.New("must be true or false")
+		}
+		return strconv.FormatBool(flag), nil, nil
+
+	case models.AttributeEnum:
+		if value, ok := raw.(string); ok {
+			for _, option := range Options(def) {
+				if option == value {
+					return value, nil, nil
+				}
+			}
+		}
+		return "", nil, errors.New("must be one of " + strings.Join(Options(def), ", "))
+	}
+
+	value, ok := raw.(string)
+	if !ok {
+		return "", nil, errors.New("must be a string")
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil, errors.New("can not be empty")
+	}
+	return value, nil, nil
+}
+
+// typedValue - a stored value in the JSON type of its attribute
+func typedValue(def models.AttributeDefinition, attribute models.ProductAttribute) interface{} {
+	switch def.Type {
+	case models.AttributeNumber:
+		if attribute.NumberValue != nil {
+			return *attribute.NumberValue
+		}
+	case models.AttributeBool:
+		return attribute.Value == "true"
+	}
+	return attribute.Value
+}
+
+// SetAttributes - validates and stores attribute values of a product, values
+// set to nil are removed. Afterwards every required attribute of the product
+// type must have a value and no value may belong to another product type.
+func SetAttributes(tx *gorm.DB, product models.Product, values map[string]interface{}) error {
+	var definitions []models.AttributeDefinition
+	if err := tx.Find(&definitions).Error; err != nil {
+		return err
+	}
+
+	byCode := map[string]models.AttributeDefinition{}
+	for _, def := range definitions {
+		byCode[def.Code] = def
+	}
+
+	var current []models.ProductAttribute
+	if err := tx.Preload("AttributeDefinition").Where("product_id = ?", product.ID).Find(&current).Error; err != nil {
+		return err
+	}
+
+	kept := map[string]models.ProductAttribute{}
+	for _, attribute := range current {
+		kept[attribute.AttributeDefinition.Code] = attribute
+	}
+
+	invalid := map[string]string{}
+	changed := []models.ProductAttribute{}
+	removed := []uint{}
+
+	for code, raw := range values {
+		def, ok := byCode[code]
+		if !ok {
+			invalid[code] = "is not a known attribute"
+			continue
+		}
+
+		attribute, exists := kept[code]
+		delete(kept, code)
+
+		if raw == nil {
+			if exists {
+				removed = append(removed, attribute.ID)
+			}
+			continue
+		}
+
+		if !Applies(def, product.ProductType) {
+			invalid[code] = "only applies to product type " + def.ProductType
+			continue
+		}
+
+		value, number, err := parseValue(def, raw)
+		if err != nil {
+			invalid[code] = err.Error()
+			continue
+		}
+
+		attribute.ProductID = product.ID
+		attribute.AttributeDefinitionID = def.ID
+		attribute.Value = value
+		attribute.NumberValue = number
+		changed = append(changed, attribute)
+	}
+
+	// Values left untouched still have to fit the product type, which may
+	// have changed
+	for code, attribute := range kept {
+		if !Applies(attribute.AttributeDefinition, product.ProductType) {
+			invalid[code] = "only applies to product type " + attribute.AttributeDefinition.ProductType
+		}
+	}
+
+	for _, def := range definitions {
+		if !def.Required || !Applies(def, product.ProductType) || invalid[def.Code] != "" {
+			continue
+		}
+		if _, ok := kept[def.Code]; ok {
+			continue
+		}
+		if raw, ok := values[def.Code]; ok && raw != nil {
+			continue
+		}
+		invalid[def.Code] = "is required"
+	}
+
+	if len(invalid) > 0 {
+		return &AttributeError{Errors: invalid}
+	}
+
+	if len(removed) > 0 {
+		if err := tx.Unscoped().Delete(&models.ProductAttribute{}, removed).Error; err != nil {
+			return err
+		}
+	}
+
+	for i := range changed {
+		if err := tx.Omit(clause.Associations).Save(&changed[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ProductAttributes - the attribute values of a product by code
+func ProductAttributes(db *gorm.DB, productID uint) map[string]interface{} {
+	var attributes []models.ProductAttribute
+	db.Preload("AttributeDefinition").Where("product_id = ?", productID).Find(&attributes)
+
+	values := map[string]interface{}{}
+	for _, attribute := range attributes {
+		values[attribute.AttributeDefinition.Code] = typedValue(attribute.AttributeDefinition, attribute)
+	}
+	return values
+}
+
+// FilterByAttributes - narrows a product query to products whose attributes
+// match. Keys are attribute codes for an exact match, number attributes also
+// take code.min and code.max for a range.
+func FilterByAttributes(db *gorm.DB, query *gorm.DB, filters map[string]string) (*gorm.DB, error) {
+	for key, raw := range filters {
+		code, bound := key, ""
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			code, bound = key[:i], key[i+1:]
+		}
+
+		var def models.AttributeDefinition
+		db.Where("code = ?", code).Limit(1).Find(&def)
+		if def.ID == 0 {
+			return nil, errors.New("unknown attribute " + code)
+		}
+
+		matches := db.Model(&models.ProductAttribute{}).Select("product_id").Where("attribute_definition_id = ?", def.ID)
+
+		switch {
+		case bound == "" && def.Type == models.AttributeNumber:
+			number, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, errors.New("attribute " + code + " must be a number")
+			}
+			matches = matches.Where("number_value = ?", number)
+
+		case bound == "" && def.Type == models.AttributeBool:
+			flag, err := strconv.ParseBool(raw)
+			if err != nil {
+				return nil, errors.New("attribute " + code + " must be true or false")
+			}
+			matches = matches.Where("value = ?", strconv.FormatBool(flag))
+
+		case bound == "":
+			matches = matches.Where("value = ?", raw)
+
+		case (bound == "min" || bound == "max") && def.Type == models.AttributeNumber:
+			number, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, errors.New("attribute " + key + " must be a number")
+			}
+			operator := ">="
+			if bound == "max" {
+				operator = "<="
+			}
+			matches = matches.Where("number_value "+operator+" ?", number)
+
+		default:
+			return nil, errors.New("unsupported attribute filter " + key)
+		}
+
+		query = query.Where("id IN (?)", matches)
+	}
+
+	return query, nil
+}
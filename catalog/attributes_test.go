@@ -0,0 +1,115 @@
+package catalog
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// attributeDB - a weight for every product, a required size for shirts only
+// and a colour to pick from
+func attributeDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := testdb.Open(t, &models.Product{}, &models.AttributeDefinition{}, &models.ProductAttribute{})
+	db.Create(&models.AttributeDefinition{Code: "weight", Type: models.AttributeNumber})
+	db.Create(&models.AttributeDefinition{Code: "size", Type: models.AttributeString, Required: true, ProductType: "shirt"})
+	db.Create(&models.AttributeDefinition{Code: "colour", Type: models.AttributeEnum, Options: "red\nblue"})
+	return db
+}
+
+// invalidCodes - the attribute codes an attribute error lists
+func invalidCodes(err error) []string {
+	attributeErr, ok := err.(*AttributeError)
+	if !ok {
+		return nil
+	}
+
+	codes := []string{}
+	for code := range attributeErr.Errors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func TestSetAttributes(t *testing.T) {
+	tests := []struct {
+		name        string
+		productType string
+		values      map[string]interface{}
+		invalid     []string
+	}{
+		{"valid", "mug", map[string]interface{}{"weight": 0.3, "colour": "red"}, nil},
+		{"required for the type", "shirt", map[string]interface{}{"size": "M"}, nil},
+		{"required missing", "shirt", map[string]interface{}{"weight": 0.2}, []string{"size"}},
+		{"other product type", "mug", map[string]interface{}{"size": "M"}, []string{"size"}},
+		{"wrong types", "mug", map[string]interface{}{"weight": "heavy", "colour": "green"}, []string{"colour", "weight"}},
+		{"unknown", "mug", map[string]interface{}{"volume": 3.0}, []string{"volume"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := attributeDB(t)
+			product := models.Product{Name: "Product", ProductType: test.productType}
+			db.Create(&product)
+
+			err := SetAttributes(db, product, test.values)
+			if got := invalidCodes(err); !reflect.DeepEqual(got, test.invalid) {
+				t.Fatalf("SetAttributes() = %v, want invalid %v", err, test.invalid)
+			}
+
+			want := test.values
+			if test.invalid != nil {
+				want = map[string]interface{}{}
+			}
+			if got := ProductAttributes(db, product.ID); !reflect.DeepEqual(got, want) {
+				t.Errorf("ProductAttributes() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFilterByAttributes(t *testing.T) {
+	db := attributeDB(t)
+
+	weights := []float64{0.2, 0.5, 1.5}
+	for i, weight := range weights {
+		product := models.Product{Name: fmt.Sprintf("Product %d", i+1)}
+		db.Create(&product)
+		if err := SetAttributes(db, product, map[string]interface{}{"weight": weight}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filters map[string]string
+		want    []uint
+	}{
+		{map[string]string{"weight": "0.5"}, []uint{2}},
+		{map[string]string{"weight.min": "0.3"}, []uint{2, 3}},
+		{map[string]string{"weight.min": "0.3", "weight.max": "1"}, []uint{2}},
+	}
+
+	for _, test := range tests {
+		query, err := FilterByAttributes(db, db.Model(&models.Product{}), test.filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []uint
+		query.Order("id").Pluck("id", &ids)
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("FilterByAttributes(%v) = %v, want %v", test.filters, ids, test.want)
+		}
+	}
+
+	if _, err := FilterByAttributes(db, db.Model(&models.Product{}), map[string]string{"weight": "heavy"}); err == nil {
+		t.Error("FilterByAttributes() accepted a word for a number")
+	}
+}
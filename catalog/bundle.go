@@ -0,0 +1,90 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// ComponentInput - a component as sent with a bundle product
+type ComponentInput struct {
+	ProductID uint `json:"product_id"`
+	Quantity  int  `json:"quantity"`
+}
+
+// ValidateComponents - checks what a bundle is made of and returns its bundle
+// items. Components must be existing products that are not bundles
+// themselves, so stock never has to be resolved more than one level deep.
+func ValidateComponents(db *gorm.DB, product models.Product, components []ComponentInput) ([]models.BundleItem, error) {
+	if !product.IsBundle {
+		if len(components) > 0 {
+			return nil, errors.New("Only bundles can have components")
+		}
+		return []models.BundleItem{}, nil
+	}
+
+	if len(components) == 0 {
+		return nil, errors.New("A bundle needs at least one component")
+	}
+
+	if product.ID != 0 {
+		var usedIn int64
+		db.Model(&models.BundleItem{}).Where("component_id = ?", product.ID).Count(&usedIn)
+		if usedIn > 0 {
+			return nil, errors.New("A component of another bundle can not be a bundle")
+		}
+	}
+
+	bundleItems := make([]models.BundleItem, len(components))
+	seen := map[uint]bool{}
+
+	for i, component := range components {
+		if product.ID != 0 && component.ProductID == product.ID {
+			return nil, errors.New("A bundle can not contain itself")
+		}
+		if seen[component.ProductID] {
+			return nil, fmt.Errorf("Component %d is listed twice", component.ProductID)
+		}
+		seen[component.ProductID] = true
+
+		if component.Quantity <= 0 {
+			return nil, fmt.Errorf("Quantity of component %d must be greater than 0", component.ProductID)
+		}
+
+		part := models.Product{}
+		db.First(&part, component.ProductID)
+
+		if part.ID == 0 {
+			return nil, fmt.Errorf("Component not found with id %d", component.ProductID)
+		}
+		if part.IsBundle {
+			return nil, fmt.Errorf("Component %s is a bundle", part.Name)
+		}
+
+		bundleItems[i] = models.BundleItem{
+			ComponentID: part.ID,
+			Quantity:    component.Quantity,
+		}
+	}
+
+	return bundleItems, nil
+}
+
+// SetComponents - replaces the components of a bundle with validated bundle
+// items, none removes them
+func SetComponents(tx *gorm.DB, product models.Product, bundleItems []models.BundleItem) error {
+	if err := tx.Unscoped().Where("bundle_id = ?", product.ID).Delete(&models.BundleItem{}).Error; err != nil {
+		return err
+	}
+
+	if len(bundleItems) == 0 {
+		return nil
+	}
+
+	for i := range bundleItems {
+		bundleItems[i].BundleID = product.ID
+	}
+	return tx.Create(&bundleItems).Error
+}
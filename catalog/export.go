@@ -0,0 +1,56 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// exportBatch - how many products are loaded at a time while exporting
+const exportBatch = 500
+
+// ExportHeader - the columns written by ExportCSV, also accepted by the import
+var ExportHeader = []string{"id", "name", "sku", "status", "price", "quantity", "reorder_threshold"}
+
+// ExportCSV - streams the whole catalog as CSV in batches.
+func ExportCSV(db *gorm.DB, w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(ExportHeader); err != nil {
+		return err
+	}
+
+	var products []models.Product
+	err := db.FindInBatches(&products, exportBatch, func(tx *gorm.DB, batch int) error {
+		for _, product := range products {
+			sku := ""
+			if product.SKU != nil {
+				sku = *product.SKU
+			}
+
+			if err := writer.Write([]string{
+				strconv.FormatUint(uint64(product.ID), 10),
+				product.Name,
+				sku,
+				product.Status,
+				product.Price.String(),
+				strconv.Itoa(product.Quantity),
+				strconv.Itoa(product.ReorderThreshold),
+			}); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+		return writer.Error()
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,312 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"github.com/rama-kairi/fiber-api/pricing"
+	"gorm.io/gorm"
+)
+
+// progressEvery - how many rows are processed between job progress updates
+const progressEvery = 100
+
+// errDryRun - rolls back the row transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// row - one parsed line of the import, nil fields were left empty
+type row struct {
+	name      string
+	sku       string
+	status    string
+	price     *money.Amount
+	quantity  *int
+	threshold *int
+}
+
+// columns - maps the header names to their position
+func columns(header []string) (map[string]int, error) {
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	_, hasName := index["name"]
+	_, hasSKU := index["sku"]
+	if !hasName && !hasSKU {
+		return nil, errors.New("header must contain a name or sku column")
+	}
+	return index, nil
+}
+
+// parseRow - validates a record against the header
+func parseRow(index map[string]int, record []string) (row, error) {
+	value := func(column string) string {
+		if i, ok := index[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	r := row{name: value("name"), sku: value("sku")}
+	if r.name == "" && r.sku == "" {
+		return r, errors.New("name or sku is required")
+	}
+
+	if v := strings.ToLower(value("status")); v != "" {
+		if err := ValidateStatus(models.Product{Status: v}); err != nil {
+			return r, err
+		}
+		r.status = v
+	}
+
+	if v := value("price"); v != "" {
+		price, err := money.Parse(v)
+		if err != nil || price < 0 {
+			return r, fmt.Errorf("invalid price %q", v)
+		}
+		r.price = &price
+	}
+
+	if v := value("quantity"); v != "" {
+		quantity, err := strconv.Atoi(v)
+		if err != nil || quantity < 0 {
+			return r, fmt.Errorf("invalid quantity %q", v)
+		}
+		r.quantity = &quantity
+	}
+
+	if v := value("reorder_threshold"); v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil || threshold < 0 {
+			return r, fmt.Errorf("invalid reorder_threshold %q", v)
+		}
+		r.threshold = &threshold
+	}
+
+	return r, nil
+}
+
+// applyRow - creates or updates the product of a row, matching on sku first
+// and name second. Returns the product id and whether it was created.
+func applyRow(tx *gorm.DB, r row, actorID uint) (uint, bool, error) {
+	product := models.Product{}
+	if r.sku != "" {
+		tx.Where("sku = ?", r.sku).Limit(1).Find(&product)
+	}
+	if product.ID == 0 && r.name != "" {
+		tx.Where("name = ?", r.name).Limit(1).Find(&product)
+
+		if product.SKU != nil && r.sku != "" && *product.SKU != r.sku {
+			return 0, false, fmt.Errorf("name %q belongs to the product with sku %q", r.name, *product.SKU)
+		}
+	}
+
+	created := product.ID == 0
+	previousName := product.Name
+	if created && (r.name == "" || r.price == nil) {
+		return 0, false, errors.New("name and price are required for new products")
+	}
+
+	if r.name != "" {
+		product.Name = r.name
+	}
+	if r.sku != "" {
+		sku := r.sku
+		product.SKU = &sku
+	}
+	if r.threshold != nil {
+		product.ReorderThreshold = *r.threshold
+	}
+
+	// New products stay hidden until they are published, like the ones
+	// created through the API
+	if r.status != "" {
+		product.Status = r.status
+	} else if created {
+		product.Status = models.ProductDraft
+	}
+
+	// Stock and price of existing products only change through the ledger
+	// and the price history below
+	save := tx.Create
+	if !created {
+		save = tx.Omit("quantity", "price_minor", "compare_at_price_minor", "slug").Save
+	}
+	if err := save(&product).Error; err != nil {
+		return 0, false, err
+	}
+
+	if product.Name != previousName {
+		if err := AssignSlug(tx, &product); err != nil {
+			return 0, false, err
+		}
+	}
+
+	if r.price != nil && (created || *r.price != product.Price) {
+		if err := pricing.SetPrice(tx, product.ID, *r.price, actorID); err != nil {
+			return 0, false, err
+		}
+	}
+
+	if r.quantity != nil && *r.quantity != product.Quantity {
+		reason := models.MovementAdjustment
+		if created {
+			reason = models.MovementRestock
+		}
+
+		if err := inventory.Adjust(tx, product.ID, *r.quantity-product.Quantity, reason, actorID, "CSV import"); err != nil {
+			return 0, false, err
+		}
+	}
+
+	return product.ID, created, nil
+}
+
+// RunImport - processes the CSV file of the job row by row, every row in its
+// own transaction. A dry run validates and rolls every row back. The file is
+// removed once the job is done.
+func RunImport(db *gorm.DB, job models.ImportJob, path string) {
+	defer os.Remove(path)
+
+	job.Status = models.JobRunning
+	db.Save(&job)
+
+	if err := importFile(db, &job, path); err != nil {
+		job.Status = models.JobFailed
+		job.Message = err.Error()
+	} else {
+		job.Status = models.JobCompleted
+	}
+
+	if err := db.Omit("Errors").Save(&job).Error; err != nil {
+		log.Println("Failed to save import job: ", err.Error())
+	}
+}
+
+func importFile(db *gorm.DB, job *models.ImportJob, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return errors.New("file is empty")
+	}
+	if err != nil {
+		return err
+	}
+
+	index, err := columns(header)
+	if err != nil {
+		return err
+	}
+
+	// Keys of the products a dry run would have created, later rows with them
+	// update those products instead of creating them again
+	seen := map[string]bool{}
+
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		job.Rows++
+
+		if err == nil {
+			err = importRecord(db, job, index, record, seen)
+		}
+
+		if err != nil {
+			job.Failed++
+			db.Create(&models.ImportRowError{ImportJobID: job.ID, Row: line, Error: err.Error()})
+		}
+
+		if job.Rows%progressEvery == 0 {
+			db.Omit("Errors").Save(job)
+		}
+	}
+
+	return nil
+}
+
+// rowKeys - the keys a row is matched to its product on
+func rowKeys(r row) []string {
+	keys := []string{}
+	if r.sku != "" {
+		keys = append(keys, "sku:"+r.sku)
+	}
+	if r.name != "" {
+		keys = append(keys, "name:"+r.name)
+	}
+	return keys
+}
+
+func importRecord(db *gorm.DB, job *models.ImportJob, index map[string]int, record []string, seen map[string]bool) error {
+	r, err := parseRow(index, record)
+	if err != nil {
+		return err
+	}
+
+	// The rows of a dry run are rolled back, so a product an earlier row would
+	// have created is not there for the rows after it to update
+	if job.DryRun {
+		for _, key := range rowKeys(r) {
+			if seen[key] {
+				job.Updated++
+				return nil
+			}
+		}
+	}
+
+	var productID uint
+	var created bool
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		productID, created, err = applyRow(tx, r, job.UserID)
+		if err != nil {
+			return err
+		}
+
+		if job.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return err
+	}
+
+	if created {
+		job.Created++
+		if job.DryRun {
+			for _, key := range rowKeys(r) {
+				seen[key] = true
+			}
+		}
+	} else {
+		job.Updated++
+	}
+
+	if !job.DryRun {
+		inventory.Watch(productID)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// importDB - a catalog with one product, Mug with sku MUG at 5.00 and 10 in
+// stock in the main warehouse
+func importDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	testdb.Env(t)
+
+	db := testdb.Open(t, &models.Product{}, &models.StockMovement{}, &models.ProductPrice{}, &models.ProductSlug{},
+		&models.Warehouse{}, &models.WarehouseStock{}, &models.ImportJob{}, &models.ImportRowError{})
+
+	sku := "MUG"
+	db.Create(&models.Product{Name: "Mug", SKU: &sku, Price: 500, Quantity: 10})
+	if err := inventory.SeedWarehouses(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// runImport - imports the CSV and returns the finished job
+func runImport(t *testing.T, db *gorm.DB, csv string, dryRun bool) models.ImportJob {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "import.csv")
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	job := models.ImportJob{Status: models.JobPending, DryRun: dryRun, UserID: 1}
+	db.Create(&job)
+	RunImport(db, job, path)
+
+	db.Preload("Errors").First(&job, job.ID)
+	return job
+}
+
+func TestRunImport(t *testing.T) {
+	db := importDB(t)
+
+	job := runImport(t, db, "name,sku,price,quantity\n"+
+		"Mug,MUG,6.5,12\n"+
+		"Plate,PLT,9,4\n"+
+		"Bowl,,-1,\n"+
+		"Cup,,,\n", false)
+
+	if job.Status != models.JobCompleted || job.Rows != 4 || job.Created != 1 || job.Updated != 1 || job.Failed != 2 {
+		t.Fatalf("job = %+v", job)
+	}
+	if len(job.Errors) != 2 || job.Errors[0].Row != 4 || job.Errors[1].Row != 5 {
+		t.Errorf("errors = %+v, want rows 4 and 5", job.Errors)
+	}
+
+	mug := models.Product{}
+	db.Where("sku = ?", "MUG").First(&mug)
+	if mug.Price != 650 || mug.Quantity != 12 {
+		t.Errorf("mug = %v at %v, want 12 at 6.50", mug.Quantity, mug.Price)
+	}
+
+	// The stock change of the update goes through the ledger
+	var movement models.StockMovement
+	db.Where("product_id = ?", mug.ID).First(&movement)
+	if movement.Change != 2 || movement.Reason != models.MovementAdjustment {
+		t.Errorf("movement = %+v, want an adjustment of 2", movement)
+	}
+}
+
+func TestRunImportDryRun(t *testing.T) {
+	db := importDB(t)
+
+	job := runImport(t, db, "name,sku,price,quantity\n"+
+		"Plate,PLT,9,4\n"+
+		"Plate,PLT,10,5\n"+
+		"Mug,MUG,7,1\n", true)
+
+	if job.Status != models.JobCompleted || job.Created != 1 || job.Updated != 2 || job.Failed != 0 {
+		t.Fatalf("job = %+v", job)
+	}
+
+	var count int64
+	db.Model(&models.Product{}).Count(&count)
+	mug := models.Product{}
+	db.Where("sku = ?", "MUG").First(&mug)
+	if count != 1 || mug.Price != 500 || mug.Quantity != 10 {
+		t.Errorf("dry run changed the catalog: %d products, mug %v at %v", count, mug.Quantity, mug.Price)
+	}
+}
+
+func TestRunImportHeader(t *testing.T) {
+	db := importDB(t)
+
+	job := runImport(t, db, "title,cost\nMug,5\n", false)
+	if job.Status != models.JobFailed || job.Message == "" {
+		t.Errorf("job = %+v, want it failed on the header", job)
+	}
+}
+
+func TestRunImportStatus(t *testing.T) {
+	db := importDB(t)
+
+	job := runImport(t, db, "name,sku,price,status\n"+
+		"Plate,PLT,9,\n"+
+		"Bowl,BWL,7,Published\n"+
+		"Mug,MUG,5,archived\n"+
+		"Cup,CUP,3,live\n", false)
+
+	if job.Created != 2 || job.Updated != 1 || job.Failed != 1 {
+		t.Fatalf("job = %+v", job)
+	}
+
+	for sku, want := range map[string]string{
+		"PLT": models.ProductDraft,
+		"BWL": models.ProductPublished,
+		"MUG": models.ProductArchived,
+	} {
+		product := models.Product{}
+		db.Where("sku = ?", sku).First(&product)
+		if product.Status != want {
+			t.Errorf("%s status = %q, want %q", sku, product.Status, want)
+		}
+	}
+
+	// A row without a status leaves an existing product's alone
+	runImport(t, db, "sku,price\nBWL,8\n", false)
+	bowl := models.Product{}
+	db.Where("sku = ?", "BWL").First(&bowl)
+	if bowl.Status != models.ProductPublished || bowl.Price != 800 {
+		t.Errorf("bowl = %q at %v, want published at 8.00", bowl.Status, bowl.Price)
+	}
+}
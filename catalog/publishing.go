@@ -0,0 +1,60 @@
+package catalog
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// Published - scope limiting a product query to what customers may see
+func Published(db *gorm.DB) *gorm.DB {
+	return db.Where("products.status = ?", models.ProductPublished)
+}
+
+// ValidateStatus - checks the status and the scheduled times of a product
+func ValidateStatus(product models.Product) error {
+	switch product.Status {
+	case models.ProductDraft, models.ProductPublished, models.ProductArchived:
+	default:
+		return errors.New("Status must be one of draft, published or archived")
+	}
+
+	if product.PublishAt != nil && product.UnpublishAt != nil && !product.UnpublishAt.After(*product.PublishAt) {
+		return errors.New("unpublish_at must be after publish_at")
+	}
+
+	return nil
+}
+
+// RunSchedule - publishes the drafts and archives the published products whose
+// time has come. The time that fired is cleared.
+func RunSchedule(db *gorm.DB, now time.Time) error {
+	if err := db.Model(&models.Product{}).
+		Where("status = ? AND publish_at <= ?", models.ProductDraft, now).
+		Updates(map[string]interface{}{"status": models.ProductPublished, "publish_at": nil}).Error; err != nil {
+		return err
+	}
+
+	return db.Model(&models.Product{}).
+		Where("status = ? AND unpublish_at <= ?", models.ProductPublished, now).
+		Updates(map[string]interface{}{"status": models.ProductArchived, "unpublish_at": nil}).Error
+}
+
+// StartPublisher - applies the scheduled publish and unpublish times in the
+// background.
+func StartPublisher(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := RunSchedule(db, time.Now()); err != nil {
+				log.Println("Failed to apply publishing schedule: ", err.Error())
+			}
+			<-ticker.C
+		}
+	}()
+}
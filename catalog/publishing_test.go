@@ -0,0 +1,69 @@
+package catalog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+)
+
+func TestRunSchedule(t *testing.T) {
+	db := testdb.Open(t, &models.Product{})
+
+	now := time.Now()
+	earlier, later := now.Add(-time.Minute), now.Add(time.Minute)
+
+	products := []struct {
+		product models.Product
+		want    string
+	}{
+		{models.Product{Name: "Due draft", Status: models.ProductDraft, PublishAt: &earlier}, models.ProductPublished},
+		{models.Product{Name: "Later draft", Status: models.ProductDraft, PublishAt: &later}, models.ProductDraft},
+		{models.Product{Name: "Withdrawn", Status: models.ProductPublished, UnpublishAt: &earlier}, models.ProductArchived},
+		{models.Product{Name: "Still listed", Status: models.ProductPublished, UnpublishAt: &later}, models.ProductPublished},
+		{models.Product{Name: "Archived", Status: models.ProductArchived, PublishAt: &earlier}, models.ProductArchived},
+	}
+	for i := range products {
+		db.Create(&products[i].product)
+	}
+
+	if err := RunSchedule(db, now); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range products {
+		product := models.Product{}
+		db.First(&product, p.product.ID)
+		if product.Status != p.want {
+			t.Errorf("%s status = %q, want %q", product.Name, product.Status, p.want)
+		}
+	}
+
+	var published int64
+	Published(db.Model(&models.Product{})).Count(&published)
+	if published != 2 {
+		t.Errorf("%d products published, want 2", published)
+	}
+}
+
+func TestValidateStatus(t *testing.T) {
+	earlier, later := time.Now(), time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		product models.Product
+		valid   bool
+	}{
+		{"draft", models.Product{Status: models.ProductDraft}, true},
+		{"unknown status", models.Product{Status: "live"}, false},
+		{"window", models.Product{Status: models.ProductDraft, PublishAt: &earlier, UnpublishAt: &later}, true},
+		{"window backwards", models.Product{Status: models.ProductDraft, PublishAt: &later, UnpublishAt: &earlier}, false},
+	}
+
+	for _, test := range tests {
+		if err := ValidateStatus(test.product); (err == nil) != test.valid {
+			t.Errorf("%s: ValidateStatus() = %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package catalog
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// ReservedSlugs - path segments under /products taken by other routes, a
+// product with one of them as its slug could not be reached by it
+var ReservedSlugs = []string{"export", "import", "low-stock", "sku"}
+
+// latinFolds - accented Latin letters written the plain way in slugs, other
+// letters are kept as they are so names in any script get a readable slug
+var latinFolds = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ł': "l", 'ľ': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o",
+	'œ': "oe", 'ř': "r", 'ß': "ss", 'ś': "s", 'š': "s", 'ť': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// Slugify - lowercases the name and joins its letters and digits with dashes,
+// accented Latin letters lose their accents. Slugs made only of digits or
+// taken by other routes get a prefix so they never look like an id or route.
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(name) {
+		if fold, ok := latinFolds[r]; ok {
+			b.WriteString(fold)
+			dash = false
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return "product"
+	}
+	if _, err := strconv.ParseUint(slug, 10, 64); err == nil || reserved(slug) {
+		return "product-" + slug
+	}
+	return slug
+}
+
+// reserved - whether the slug is one of the ReservedSlugs
+func reserved(slug string) bool {
+	for _, taken := range ReservedSlugs {
+		if taken == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// AssignSlug - gives the product a slug generated from its name, adding a
+// counter when another product has or had it. Old slugs stay reserved for the
+// product so they keep redirecting.
+func AssignSlug(tx *gorm.DB, product *models.Product) error {
+	base := Slugify(product.Name)
+	slug := base
+
+	for i := 2; ; i++ {
+		var owner models.ProductSlug
+		tx.Unscoped().Where("slug = ?", slug).Limit(1).Find(&owner)
+
+		if owner.ID == 0 || owner.ProductID == product.ID {
+			if owner.ID == 0 {
+				if err := tx.Create(&models.ProductSlug{ProductID: product.ID, Slug: slug}).Error; err != nil {
+					return err
+				}
+			}
+			break
+		}
+
+		slug = base + "-" + strconv.Itoa(i)
+	}
+
+	if product.Slug == slug {
+		return nil
+	}
+
+	product.Slug = slug
+	return tx.Model(product).UpdateColumn("slug", slug).Error
+}
+
+// FindProduct - looks a product up by id or by any slug it ever had
+func FindProduct(db *gorm.DB, idOrSlug string) models.Product {
+	product := models.Product{}
+
+	if id, err := strconv.ParseUint(idOrSlug, 10, 64); err == nil {
+		db.First(&product, id)
+		return product
+	}
+
+	db.Where("slug = ?", idOrSlug).Limit(1).Find(&product)
+	if product.ID != 0 {
+		return product
+	}
+
+	var old models.ProductSlug
+	db.Where("slug = ?", idOrSlug).Limit(1).Find(&old)
+	if old.ID != 0 {
+		db.First(&product, old.ProductID)
+	}
+	return product
+}
+
+// BackfillSlugs - gives products from before slugs existed their slug, and
+// products whose slug became reserved a new one
+func BackfillSlugs(db *gorm.DB) error {
+	var products []models.Product
+	if err := db.Where("slug = '' OR slug IS NULL OR slug IN ?", ReservedSlugs).Find(&products).Error; err != nil {
+		return err
+	}
+
+	for i := range products {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return AssignSlug(tx, &products[i])
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Blue Mug", "blue-mug"},
+		{"  Crème Brûlée -- 2 pack! ", "creme-brulee-2-pack"},
+		{"Straße", "strasse"},
+		{"Чашка синяя", "чашка-синяя"},
+		{"2024", "product-2024"},
+		{"Export", "product-export"},
+		{"!!!", "product"},
+	}
+
+	for _, test := range tests {
+		if got := Slugify(test.name); got != test.want {
+			t.Errorf("Slugify(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAssignSlug(t *testing.T) {
+	db := testdb.Open(t, &models.Product{}, &models.ProductSlug{})
+
+	mug := models.Product{Name: "Blue Mug"}
+	other := models.Product{Name: "Blue mug!"}
+	db.Create(&mug)
+	db.Create(&other)
+
+	for _, product := range []*models.Product{&mug, &other} {
+		if err := AssignSlug(db, product); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if mug.Slug != "blue-mug" || other.Slug != "blue-mug-2" {
+		t.Fatalf("slugs = %q and %q, want blue-mug and blue-mug-2", mug.Slug, other.Slug)
+	}
+
+	// Renamed, the old slug still finds the product and stays taken
+	mug.Name = "Red Mug"
+	if err := AssignSlug(db, &mug); err != nil {
+		t.Fatal(err)
+	}
+	if mug.Slug != "red-mug" {
+		t.Errorf("renamed slug = %q, want red-mug", mug.Slug)
+	}
+	if found := FindProduct(db, "blue-mug"); found.ID != mug.ID {
+		t.Errorf("old slug finds product %d, want %d", found.ID, mug.ID)
+	}
+
+	third := models.Product{Name: "Blue-Mug"}
+	db.Create(&third)
+	if err := AssignSlug(db, &third); err != nil {
+		t.Fatal(err)
+	}
+	if third.Slug != "blue-mug-3" {
+		t.Errorf("slug = %q, want blue-mug-3", third.Slug)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
+)
+
+// Grants or revokes admin access for a user.
+//
+//	go run ./cmd/promote -email someone@example.com [-revoke]
+func main() {
+	email := flag.String("email", "", "email of the user")
+	revoke := flag.Bool("revoke", false, "revoke admin access instead of granting it")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("-email is required")
+	}
+
+	database.ConnectDB()
+	db := database.Database.Db
+
+	var user models.User
+	db.Where("email = ?", *email).First(&user)
+
+	if user.ID == 0 {
+		log.Fatal("User not found with email ", *email)
+	}
+
+	if err := db.Model(&user).Update("is_admin", !*revoke).Error; err != nil {
+		log.Fatal("Failed to update user: ", err.Error())
+	}
+
+	if *revoke {
+		fmt.Printf("%s is no longer an admin\n", user.Email)
+		return
+	}
+	fmt.Printf("%s is now an admin\n", user.Email)
+}
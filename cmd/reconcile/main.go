@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/inventory"
+)
+
+// Recomputes the stock of every product in every warehouse from the movement
+// ledger and reports the products whose quantity drifted from it.
+//
+//	go run ./cmd/reconcile [-fix]
+func main() {
+	fix := flag.Bool("fix", false, "reset drifted warehouse stock and product quantities to the ledger values")
+	flag.Parse()
+
+	database.ConnectDB()
+
+	drifts, err := inventory.Reconcile(database.Database.Db, *fix)
+	if err != nil {
+		log.Fatal("Failed to reconcile stock: ", err.Error())
+	}
+
+	if len(drifts) == 0 {
+		fmt.Println("Stock matches the ledger")
+		return
+	}
+
+	fmt.Printf("%-8s %-32s %10s %10s %10s\n", "ID", "NAME", "QUANTITY", "LEDGER", "DRIFT")
+	for _, d := range drifts {
+		fmt.Printf("%-8d %-32s %10d %10d %+10d\n", d.ProductID, d.Name, d.Quantity, d.Ledger, d.Drift)
+		for _, w := range d.Warehouses {
+			fmt.Printf("%-8s %-32s %10d %10d %+10d\n", "", fmt.Sprintf("  warehouse %d", w.WarehouseID), w.Quantity, w.Ledger, w.Drift)
+		}
+	}
+
+	if *fix {
+		fmt.Printf("Reset %d products to the ledger\n", len(drifts))
+		return
+	}
+	os.Exit(1)
+}
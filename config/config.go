@@ -1,6 +1,10 @@
 package config
 
-import "github.com/joho/godotenv"
+import (
+	"strings"
+
+	"github.com/joho/godotenv"
+)
 
 func GetConfig() *Config {
 	if err := godotenv.Load(); err != nil {
@@ -25,6 +29,46 @@ func GetConfig() *Config {
 			AccessExpireMin:  GetEnvInt("JWT_ACCESS_EXPIRE_MIN", 15),
 			RefreshExpireMin: GetEnvInt("JWT_REFRESH_EXPIRE_MIN", 60*24*3),
 		},
+		Currency: Currency{
+			Base:      strings.ToUpper(GetEnvStr("BASE_CURRENCY", "USD")),
+			Supported: strings.Split(strings.ToUpper(GetEnvStr("CURRENCIES", "USD,EUR,GBP")), ","),
+		},
+		Notify: Notify{
+			Channels:    GetEnvStr("NOTIFY_CHANNELS", "log"),
+			MailSinkDir: GetEnvStr("MAIL_SINK_DIR", "mail"),
+			MailFrom:    GetEnvStr("MAIL_FROM", "shop@localhost"),
+			MailTo:      GetEnvStr("MAIL_TO", "inventory@localhost"),
+			WebhookURL:  GetEnvStr("NOTIFY_WEBHOOK_URL", ""),
+		},
+		Restock: Restock{
+			Batch:       GetEnvInt("RESTOCK_NOTIFY_BATCH", 50),
+			IntervalMin: GetEnvInt("RESTOCK_NOTIFY_INTERVAL_MIN", 1),
+		},
+		Recommend: Recommend{
+			TopN:        GetEnvInt("RECOMMEND_TOP_N", 10),
+			MinSupport:  GetEnvInt("RECOMMEND_MIN_SUPPORT", 2),
+			IntervalMin: GetEnvInt("RECOMMEND_INTERVAL_MIN", 60),
+		},
+		Locale: Locale{
+			Default:   NormalizeLocale(GetEnvStr("DEFAULT_LOCALE", "en")),
+			Supported: strings.Split(NormalizeLocale(GetEnvStr("LOCALES", "en,de,fr,es")), ","),
+		},
+		Warehouse: Warehouse{
+			Strategy:   strings.ToLower(GetEnvStr("WAREHOUSE_STRATEGY", "priority")),
+			AllowSplit: GetEnvBool("WAREHOUSE_ALLOW_SPLIT", true),
+		},
+		Tax: Tax{
+			Country: strings.ToUpper(GetEnvStr("TAX_COUNTRY", "")),
+		},
+		Shipping: Shipping{
+			VolumetricDivisor: GetEnvInt("SHIPPING_VOLUMETRIC_DIVISOR", 5000),
+		},
+		Downloads: Downloads{
+			Dir:           GetEnvStr("DOWNLOAD_DIR", "files"),
+			Limit:         GetEnvInt("DOWNLOAD_LIMIT", 5),
+			AccessDays:    GetEnvInt("DOWNLOAD_ACCESS_DAYS", 30),
+			LinkExpireMin: GetEnvInt("DOWNLOAD_LINK_EXPIRE_MIN", 15),
+		},
 	}
 }
 
@@ -48,8 +92,80 @@ type Jwt struct {
 	RefreshExpireMin int
 }
 
+type Currency struct {
+	Base      string
+	Supported []string
+}
+
+type Notify struct {
+	Channels    string
+	MailSinkDir string
+	MailFrom    string
+	MailTo      string
+	WebhookURL  string
+}
+
+// Restock - at most Batch back-in-stock notices go out per product every
+// IntervalMin minutes
+type Restock struct {
+	Batch       int
+	IntervalMin int
+}
+
+type Recommend struct {
+	TopN        int
+	MinSupport  int
+	IntervalMin int
+}
+
+type Locale struct {
+	Default   string
+	Supported []string
+}
+
+// NormalizeLocale - lower case with dashes and no spaces, so en_US and en-us
+// are the same
+func NormalizeLocale(locale string) string {
+	return strings.NewReplacer("_", "-", " ", "").Replace(strings.ToLower(locale))
+}
+
+// Warehouse - Strategy is priority or nearest, nearest prefers warehouses in
+// the region of the order. AllowSplit lets one order ship from several.
+type Warehouse struct {
+	Strategy   string
+	AllowSplit bool
+}
+
+type Downloads struct {
+	Dir           string
+	Limit         int
+	AccessDays    int
+	LinkExpireMin int
+}
+
+// Tax - Country is taxed for orders that give no country of their own
+type Tax struct {
+	Country string
+}
+
+// Shipping - parcels are charged on their volumetric weight when it is more
+// than their actual weight, VolumetricDivisor cubic centimetres to the
+// kilogram. 0 charges on the actual weight only.
+type Shipping struct {
+	VolumetricDivisor int
+}
+
 type Config struct {
 	App
 	Database
 	Jwt
+	Currency
+	Notify
+	Restock
+	Recommend
+	Locale
+	Warehouse
+	Downloads
+	Tax
+	Shipping
 }
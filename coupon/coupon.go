@@ -0,0 +1,240 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound - no coupon has the code
+	ErrNotFound = errors.New("Coupon not found")
+	// ErrNotStarted - the coupon can not be used yet
+	ErrNotStarted = errors.New("Coupon is not valid yet")
+	// ErrExpired - the coupon can not be used anymore
+	ErrExpired = errors.New("Coupon has expired")
+	// ErrUsedUp - the coupon reached its usage limit
+	ErrUsedUp = errors.New("Coupon has been used up")
+	// ErrCustomerLimit - the customer used the coupon as often as allowed
+	ErrCustomerLimit = errors.New("Coupon has already been used the allowed number of times")
+	// ErrNotApplicable - none of the items can be discounted by the coupon
+	ErrNotApplicable = errors.New("Coupon does not apply to any item of the order")
+)
+
+// MinimumError - the order is too small for the coupon
+type MinimumError struct {
+	Minimum  money.Amount
+	Currency string
+}
+
+func (e *MinimumError) Error() string {
+	return fmt.Sprintf("Coupon needs an order of at least %s %s", e.Minimum, e.Currency)
+}
+
+// Normalize - codes are kept upper case without surrounding spaces
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Find - the coupon with the code
+func Find(db *gorm.DB, code string) (models.Coupon, error) {
+	coupon := models.Coupon{}
+	db.Preload("Products").Preload("Categories").Where("code = ?", Normalize(code)).Limit(1).Find(&coupon)
+
+	if coupon.ID == 0 {
+		return coupon, ErrNotFound
+	}
+	return coupon, nil
+}
+
+// Check - whether the customer can use the coupon at now
+func Check(db *gorm.DB, coupon models.Coupon, userID uint, now time.Time) error {
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return ErrNotStarted
+	}
+
+	if coupon.EndsAt != nil && !now.Before(*coupon.EndsAt) {
+		return ErrExpired
+	}
+
+	if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+		return ErrUsedUp
+	}
+
+	return checkCustomer(db, coupon, userID)
+}
+
+// checkCustomer - whether the customer has uses of the coupon left
+func checkCustomer(db *gorm.DB, coupon models.Coupon, userID uint) error {
+	if coupon.PerCustomerLimit == 0 {
+		return nil
+	}
+
+	var used int64
+	db.Model(&models.CouponRedemption{}).
+		Where("coupon_id = ? AND user_id = ?", coupon.ID, userID).
+		Count(&used)
+
+	if int(used) >= coupon.PerCustomerLimit {
+		return ErrCustomerLimit
+	}
+	return nil
+}
+
+// applies - whether the coupon discounts the product
+func applies(coupon models.Coupon, product models.Product) bool {
+	if len(coupon.Products) == 0 && len(coupon.Categories) == 0 {
+		return true
+	}
+
+	for _, restriction := range coupon.Products {
+		if restriction.ProductID == product.ID {
+			return true
+		}
+	}
+
+	for _, restriction := range coupon.Categories {
+		if strings.EqualFold(restriction.Category, product.ProductType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply - adds the discount of the coupon to the items it applies to and
+// returns the total discount. Amounts are converted at the order's exchange
+// rate, and every discount is taken from what is left of an item after
+// earlier discounts. Percent discounts are rounded per item, a fixed amount
+// is split over the items in proportion to what is left of them.
+func Apply(db *gorm.DB, coupon models.Coupon, order models.Order, items []models.OrderItem) (money.Amount, error) {
+	var subtotal money.Amount
+	for _, item := range items {
+		subtotal += item.Price
+	}
+
+	if minimum := coupon.MinOrder.Convert(order.ExchangeRate); subtotal < minimum {
+		return 0, &MinimumError{Minimum: minimum, Currency: order.Currency}
+	}
+
+	eligible := []int{}
+	remaining := []money.Amount{}
+	var eligibleTotal money.Amount
+
+	for i, item := range items {
+		product := models.Product{}
+		db.Unscoped().First(&product, item.ProductID)
+
+		if left := item.Price - item.Discount; left > 0 && applies(coupon, product) {
+			eligible = append(eligible, i)
+			remaining = append(remaining, left)
+			eligibleTotal += left
+		}
+	}
+
+	if len(eligible) == 0 {
+		return 0, ErrNotApplicable
+	}
+
+	var shares []money.Amount
+	if coupon.Kind == models.CouponPercent {
+		shares = make([]money.Amount, len(remaining))
+		for i, left := range remaining {
+			shares[i] = left.Percent(coupon.Percent)
+		}
+	} else {
+		amount := coupon.Amount.Convert(order.ExchangeRate)
+		if amount > eligibleTotal {
+			amount = eligibleTotal
+		}
+		shares = amount.Allocate(remaining)
+	}
+
+	var discount money.Amount
+	for i, index := range eligible {
+		items[index].Discount += shares[i]
+		discount += shares[i]
+	}
+	return discount, nil
+}
+
+// Redeem - counts the use of the coupon by the order, failing when the coupon
+// or the customer has no uses left. An order that already redeemed it only
+// gets its discount updated. It runs in the transaction of the order, which
+// rolls the count back when the redemption is refused.
+func Redeem(tx *gorm.DB, coupon models.Coupon, order models.Order, discount money.Amount) error {
+	redemption := models.CouponRedemption{}
+	tx.Where("order_id = ?", order.ID).Limit(1).Find(&redemption)
+
+	if redemption.ID != 0 {
+		return tx.Model(&redemption).Update("discount_minor", discount).Error
+	}
+
+	// Counted with a conditional update so concurrent orders can not go over
+	// the limit
+	result := tx.Model(&models.Coupon{}).
+		Where("id = ? AND (usage_limit = 0 OR used_count < usage_limit)", coupon.ID).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUsedUp
+	}
+
+	if coupon.PerCustomerLimit == 0 {
+		return tx.Create(&models.CouponRedemption{
+			CouponID: coupon.ID,
+			OrderID:  order.ID,
+			UserID:   uint(order.UserID),
+			Discount: discount,
+		}).Error
+	}
+
+	// The customer's uses are counted by the insert itself, so concurrent
+	// orders of one customer can not go over the limit either
+	now := time.Now()
+	result = tx.Exec(`INSERT INTO coupon_redemptions (created_at, updated_at, coupon_id, order_id, user_id, discount_minor)
+		SELECT ?, ?, ?, ?, ?, ?
+		WHERE (SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = ? AND user_id = ? AND deleted_at IS NULL) < ?`,
+		now, now, coupon.ID, order.ID, order.UserID, discount,
+		coupon.ID, order.UserID, coupon.PerCustomerLimit)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCustomerLimit
+	}
+	return nil
+}
+
+// Release - gives back the use of a coupon by the order
+func Release(tx *gorm.DB, orderID uint) error {
+	redemption := models.CouponRedemption{}
+	tx.Where("order_id = ?", orderID).Limit(1).Find(&redemption)
+
+	if redemption.ID == 0 {
+		return nil
+	}
+
+	if err := tx.Model(&models.Coupon{}).Where("id = ? AND used_count > 0", redemption.CouponID).
+		UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+		return err
+	}
+
+	return tx.Unscoped().Delete(&redemption).Error
+}
+
+// BackfillDiscounts - fills in the coupon discount of orders priced before it
+// was kept apart from the promotions, from their redemptions
+func BackfillDiscounts(db *gorm.DB) error {
+	return db.Exec(`UPDATE orders SET coupon_discount_minor = (
+		SELECT discount_minor FROM coupon_redemptions WHERE coupon_redemptions.order_id = orders.id
+	) WHERE coupon_discount_minor = 0 AND EXISTS (
+		SELECT 1 FROM coupon_redemptions WHERE coupon_redemptions.order_id = orders.id
+	)`).Error
+}
@@ -0,0 +1,259 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// couponDB - an empty database with the tables coupons need
+func couponDB(t *testing.T) *gorm.DB {
+	return testdb.Open(t, &models.Product{}, &models.Coupon{}, &models.CouponProduct{},
+		&models.CouponCategory{}, &models.CouponRedemption{})
+}
+
+func TestApply(t *testing.T) {
+	type item struct {
+		productType string
+		price       money.Amount
+		discount    money.Amount
+	}
+
+	tests := []struct {
+		name   string
+		coupon models.Coupon
+		rate   float64
+		items  []item
+		// Discount of each item after the coupon
+		want  []money.Amount
+		total money.Amount
+		err   error
+	}{
+		{
+			name:   "percent rounded per item",
+			coupon: models.Coupon{Kind: models.CouponPercent, Percent: 10},
+			items:  []item{{"", 1005, 0}, {"", 995, 0}},
+			want:   []money.Amount{101, 100},
+			total:  201,
+		},
+		{
+			name:   "percent of what earlier discounts left",
+			coupon: models.Coupon{Kind: models.CouponPercent, Percent: 50},
+			items:  []item{{"", 1000, 200}},
+			want:   []money.Amount{600},
+			total:  400,
+		},
+		{
+			name:   "fixed split in proportion",
+			coupon: models.Coupon{Kind: models.CouponFixed, Amount: 1000},
+			items:  []item{{"", 1000, 0}, {"", 3000, 0}},
+			want:   []money.Amount{250, 750},
+			total:  1000,
+		},
+		{
+			name:   "fixed split exactly",
+			coupon: models.Coupon{Kind: models.CouponFixed, Amount: 100},
+			items:  []item{{"", 1000, 0}, {"", 1000, 0}, {"", 1000, 0}},
+			want:   []money.Amount{34, 33, 33},
+			total:  100,
+		},
+		{
+			name:   "fixed capped at what is left",
+			coupon: models.Coupon{Kind: models.CouponFixed, Amount: 5000},
+			items:  []item{{"", 1000, 0}, {"", 2000, 500}},
+			want:   []money.Amount{1000, 2000},
+			total:  2500,
+		},
+		{
+			name:   "fixed converted at the order rate",
+			coupon: models.Coupon{Kind: models.CouponFixed, Amount: 1000},
+			rate:   0.9,
+			items:  []item{{"", 5000, 0}},
+			want:   []money.Amount{900},
+			total:  900,
+		},
+		{
+			name:   "only the categories it is restricted to",
+			coupon: models.Coupon{Kind: models.CouponPercent, Percent: 10, Categories: []models.CouponCategory{{Category: "books"}}},
+			items:  []item{{"Books", 1000, 0}, {"toys", 1000, 0}},
+			want:   []money.Amount{100, 0},
+			total:  100,
+		},
+		{
+			name:   "only the products it is restricted to",
+			coupon: models.Coupon{Kind: models.CouponFixed, Amount: 500, Products: []models.CouponProduct{{ProductID: 2}}},
+			items:  []item{{"", 1000, 0}, {"", 1000, 0}},
+			want:   []money.Amount{0, 500},
+			total:  500,
+		},
+		{
+			name:   "nothing it applies to",
+			coupon: models.Coupon{Kind: models.CouponPercent, Percent: 10, Categories: []models.CouponCategory{{Category: "books"}}},
+			items:  []item{{"toys", 1000, 0}},
+			want:   []money.Amount{0},
+			err:    ErrNotApplicable,
+		},
+		{
+			name:   "fully discounted items are left out",
+			coupon: models.Coupon{Kind: models.CouponPercent, Percent: 10},
+			items:  []item{{"", 1000, 1000}},
+			want:   []money.Amount{1000},
+			err:    ErrNotApplicable,
+		},
+		{
+			name:   "order below the minimum",
+			coupon: models.Coupon{Kind: models.CouponPercent, Percent: 10, MinOrder: 5000},
+			items:  []item{{"", 4999, 0}},
+			want:   []money.Amount{0},
+			err:    &MinimumError{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := couponDB(t)
+
+			items := make([]models.OrderItem, len(test.items))
+			for i, line := range test.items {
+				product := models.Product{Name: fmt.Sprintf("Product %d", i), ProductType: line.productType}
+				if err := db.Create(&product).Error; err != nil {
+					t.Fatal(err)
+				}
+				items[i] = models.OrderItem{ProductID: int(product.ID), Quantity: 1, Price: line.price, Discount: line.discount}
+			}
+
+			rate := test.rate
+			if rate == 0 {
+				rate = 1
+			}
+			order := models.Order{Currency: "USD", ExchangeRate: rate}
+
+			total, err := Apply(db, test.coupon, order, items)
+
+			var minimum *MinimumError
+			switch {
+			case errors.As(test.err, &minimum):
+				if !errors.As(err, &minimum) {
+					t.Errorf("error = %v, want a minimum error", err)
+				}
+			case !errors.Is(err, test.err):
+				t.Errorf("error = %v, want %v", err, test.err)
+			}
+
+			discounts := make([]money.Amount, len(items))
+			for i := range items {
+				discounts[i] = items[i].Discount
+			}
+			if !reflect.DeepEqual(discounts, test.want) {
+				t.Errorf("discounts = %v, want %v", discounts, test.want)
+			}
+			if total != test.total {
+				t.Errorf("total = %s, want %s", total, test.total)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		coupon models.Coupon
+		// Earlier orders of the customer that used the coupon
+		used int
+		err  error
+	}{
+		{"no limits", models.Coupon{}, 0, nil},
+		{"not started", models.Coupon{StartsAt: &later}, 0, ErrNotStarted},
+		{"started", models.Coupon{StartsAt: &now}, 0, nil},
+		{"ends now", models.Coupon{EndsAt: &now}, 0, ErrExpired},
+		{"ends later", models.Coupon{EndsAt: &later}, 0, nil},
+		{"used up", models.Coupon{UsageLimit: 2, UsedCount: 2}, 0, ErrUsedUp},
+		{"uses left", models.Coupon{UsageLimit: 2, UsedCount: 1}, 0, nil},
+		{"customer used it", models.Coupon{PerCustomerLimit: 1}, 1, ErrCustomerLimit},
+		{"customer has uses left", models.Coupon{PerCustomerLimit: 2}, 1, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := couponDB(t)
+
+			test.coupon.Code = "TEST"
+			test.coupon.Kind = models.CouponPercent
+			if err := db.Create(&test.coupon).Error; err != nil {
+				t.Fatal(err)
+			}
+			for n := 0; n < test.used; n++ {
+				if err := db.Create(&models.CouponRedemption{CouponID: test.coupon.ID, OrderID: uint(n + 1), UserID: 1}).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := Check(db, test.coupon, 1, now); !errors.Is(err, test.err) {
+				t.Errorf("Check() = %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	tests := []struct {
+		name   string
+		coupon models.Coupon
+		// Orders of customer 1 redeeming the coupon, one after the other, and
+		// the error of each
+		orders []uint
+		want   []error
+		// Redemptions and uses counted in the end
+		redemptions int64
+		used        int
+	}{
+		{"no limits", models.Coupon{}, []uint{1, 2}, []error{nil, nil}, 2, 2},
+		{"customer limit", models.Coupon{PerCustomerLimit: 1}, []uint{1, 2}, []error{nil, ErrCustomerLimit}, 1, 1},
+		{"customer uses left", models.Coupon{PerCustomerLimit: 2}, []uint{1, 2, 3}, []error{nil, nil, ErrCustomerLimit}, 2, 2},
+		{"order redeems again", models.Coupon{PerCustomerLimit: 1}, []uint{1, 1}, []error{nil, nil}, 1, 1},
+		{"usage limit", models.Coupon{UsageLimit: 1}, []uint{1, 2}, []error{nil, ErrUsedUp}, 1, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := couponDB(t)
+
+			test.coupon.Code = "TEST"
+			test.coupon.Kind = models.CouponPercent
+			if err := db.Create(&test.coupon).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			for i, orderID := range test.orders {
+				order := models.Order{UserID: 1}
+				order.ID = orderID
+
+				err := db.Transaction(func(tx *gorm.DB) error {
+					return Redeem(tx, test.coupon, order, 100)
+				})
+				if !errors.Is(err, test.want[i]) {
+					t.Errorf("Redeem() of order %d = %v, want %v", orderID, err, test.want[i])
+				}
+			}
+
+			var redemptions int64
+			db.Model(&models.CouponRedemption{}).Count(&redemptions)
+			coupon := models.Coupon{}
+			db.First(&coupon, test.coupon.ID)
+
+			if redemptions != test.redemptions || coupon.UsedCount != test.used {
+				t.Errorf("%d redemptions and %d uses, want %d and %d", redemptions, coupon.UsedCount, test.redemptions, test.used)
+			}
+		})
+	}
+}
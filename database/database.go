@@ -4,7 +4,12 @@ import (
 	"log"
 	"os"
 
+	"github.com/rama-kairi/fiber-api/catalog"
+	"github.com/rama-kairi/fiber-api/coupon"
+	"github.com/rama-kairi/fiber-api/inventory"
 	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/orderstatus"
+	"github.com/rama-kairi/fiber-api/pricing"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -27,7 +32,55 @@ func ConnectDB() {
 	db.Logger = logger.Default.LogMode(logger.Info)
 	log.Println("Running Migrations")
 
-	db.AutoMigrate(&models.User{}, &models.Product{}, &models.Order{}, &models.OrderItem{})
+	db.AutoMigrate(
+		&models.User{}, &models.Product{}, &models.Order{}, &models.OrderItem{},
+		&models.StockMovement{},
+		&models.ImportJob{}, &models.ImportRowError{},
+		&models.ProductPrice{},
+		&models.ExchangeRate{}, &models.ProductCurrencyPrice{},
+		&models.Review{}, &models.ReviewVote{},
+		&models.ProductSlug{},
+		&models.AttributeDefinition{}, &models.ProductAttribute{},
+		&models.RelatedProduct{},
+		&models.BundleItem{}, &models.OrderItemComponent{},
+		&models.ProductFile{}, &models.DownloadGrant{}, &models.Download{},
+		&models.ProductTranslation{}, &models.CategoryTranslation{},
+		&models.Warehouse{}, &models.WarehouseStock{}, &models.StockAllocation{}, &models.StockTransfer{},
+		&models.Wishlist{}, &models.WishlistItem{},
+		&models.StockSubscription{},
+		&models.TaxRate{}, &models.OrderTax{},
+		&models.Coupon{}, &models.CouponProduct{}, &models.CouponCategory{}, &models.CouponRedemption{},
+		&models.Promotion{}, &models.PromotionTarget{}, &models.PromotionCondition{}, &models.PromotionAction{},
+		&models.OrderItemPromotion{},
+		&models.ShippingZone{}, &models.ShippingZoneRegion{}, &models.ShippingMethod{}, &models.ShippingRateTier{},
+		&models.GiftCard{}, &models.GiftCardTransaction{}, &models.StoreCredit{}, &models.OrderPayment{},
+		&models.OrderStatusChange{},
+	)
+
+	if err := pricing.MigrateMinorUnits(db); err != nil {
+		log.Println("Failed to migrate prices to minor units: ", err.Error())
+	}
+
+	// Opening balances go into the default warehouse, so it comes first
+	if err := inventory.SeedWarehouses(db); err != nil {
+		log.Println("Failed to seed warehouse stock: ", err.Error())
+	}
+
+	if err := inventory.SeedOpeningBalances(db); err != nil {
+		log.Println("Failed to seed opening stock balances: ", err.Error())
+	}
+
+	if err := catalog.BackfillSlugs(db); err != nil {
+		log.Println("Failed to backfill product slugs: ", err.Error())
+	}
+
+	if err := coupon.BackfillDiscounts(db); err != nil {
+		log.Println("Failed to backfill coupon discounts: ", err.Error())
+	}
+
+	if err := orderstatus.Backfill(db); err != nil {
+		log.Println("Failed to backfill order statuses: ", err.Error())
+	}
 
 	Database = DBInstance{Db: db}
 }
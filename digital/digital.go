@@ -0,0 +1,139 @@
+package digital
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/config"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrLimitReached - the grant has no downloads left
+	ErrLimitReached = errors.New("Download limit reached")
+	// ErrAccessExpired - the grant is past its access period
+	ErrAccessExpired = errors.New("Download access has expired")
+)
+
+// FilePath - where an uploaded file of a product is stored. A random prefix
+// keeps uploads with the same name apart.
+func FilePath(productID uint, fileName string) (string, error) {
+	prefix := make([]byte, 8)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", err
+	}
+
+	name := hex.EncodeToString(prefix) + "-" + filepath.Base(fileName)
+	return filepath.Join(config.GetConfig().Downloads.Dir, strconv.FormatUint(uint64(productID), 10), name), nil
+}
+
+// Sign - the signature of a download link for a grant valid until expires
+func Sign(grantID uint, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(config.GetConfig().Jwt.Secret))
+	fmt.Fprintf(mac, "download:%d:%d", grantID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify - whether the signature belongs to the grant and expiry
+func Verify(grantID uint, expires int64, signature string) bool {
+	return hmac.Equal([]byte(Sign(grantID, expires)), []byte(signature))
+}
+
+// Link - a signed download path for the grant and when it stops working
+func Link(grant models.DownloadGrant) (string, time.Time) {
+	expires := time.Now().Add(time.Duration(config.GetConfig().Downloads.LinkExpireMin) * time.Minute)
+	if grant.ExpiresAt.Before(expires) {
+		expires = grant.ExpiresAt
+	}
+
+	path := fmt.Sprintf("/api/downloads/%d?expires=%d&signature=%s", grant.ID, expires.Unix(), Sign(grant.ID, expires.Unix()))
+	return path, expires
+}
+
+// Grant - gives a paid order access to the files of its digital products,
+// bundles included. Grants the order already has are kept as they are.
+func Grant(tx *gorm.DB, order models.Order) error {
+	var orderItems []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Find(&orderItems).Error; err != nil {
+		return err
+	}
+
+	productIDs := []uint{}
+	for _, orderItem := range orderItems {
+		productIDs = append(productIDs, uint(orderItem.ProductID))
+		for _, component := range inventory.ItemComponents(tx, orderItem) {
+			productIDs = append(productIDs, component.ProductID)
+		}
+	}
+
+	var files []models.ProductFile
+	if err := tx.Joins("JOIN products ON products.id = product_files.product_id").
+		Where("products.is_digital = ? AND product_files.product_id IN ?", true, productIDs).
+		Find(&files).Error; err != nil {
+		return err
+	}
+
+	cfg := config.GetConfig().Downloads
+	expiresAt := time.Now().AddDate(0, 0, cfg.AccessDays)
+
+	for _, file := range files {
+		var existing int64
+		tx.Model(&models.DownloadGrant{}).Where("order_id = ? AND product_file_id = ?", order.ID, file.ID).Count(&existing)
+		if existing > 0 {
+			continue
+		}
+
+		if err := tx.Create(&models.DownloadGrant{
+			OrderID:       order.ID,
+			ProductFileID: file.ID,
+			UserID:        uint(order.UserID),
+			DownloadLimit: cfg.Limit,
+			ExpiresAt:     expiresAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Revoke - takes away the downloads of an order that was refunded or
+// cancelled
+func Revoke(tx *gorm.DB, orderID uint) error {
+	return tx.Where("order_id = ?", orderID).Delete(&models.DownloadGrant{}).Error
+}
+
+// Consume - counts a download against the grant, refusing it once the limit
+// is reached or the access period is over
+func Consume(db *gorm.DB, grant models.DownloadGrant, ip string, userAgent string) error {
+	if time.Now().After(grant.ExpiresAt) {
+		return ErrAccessExpired
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.DownloadGrant{}).
+			Where("id = ? AND downloads < download_limit", grant.ID).
+			UpdateColumn("downloads", gorm.Expr("downloads + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrLimitReached
+		}
+
+		return tx.Create(&models.Download{
+			DownloadGrantID: grant.ID,
+			IP:              ip,
+			UserAgent:       userAgent,
+		}).Error
+	})
+}
@@ -0,0 +1,108 @@
+package digital
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+func TestVerify(t *testing.T) {
+	testdb.Env(t)
+
+	expires := time.Now().Add(time.Minute).Unix()
+	signature := Sign(7, expires)
+
+	tests := []struct {
+		name      string
+		grantID   uint
+		expires   int64
+		signature string
+		want      bool
+	}{
+		{"signed link", 7, expires, signature, true},
+		{"other grant", 8, expires, signature, false},
+		{"extended expiry", 7, expires + 3600, signature, false},
+		{"no signature", 7, expires, "", false},
+	}
+
+	for _, test := range tests {
+		if got := Verify(test.grantID, test.expires, test.signature); got != test.want {
+			t.Errorf("%s: Verify() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// digitalDB - an order of an ebook, a mug and a bundle holding a second ebook.
+// Every product has a file.
+func digitalDB(t *testing.T) (*gorm.DB, models.Order) {
+	t.Helper()
+	testdb.Env(t)
+
+	db := testdb.Open(t, &models.Product{}, &models.Order{}, &models.OrderItem{},
+		&models.BundleItem{}, &models.OrderItemComponent{},
+		&models.ProductFile{}, &models.DownloadGrant{}, &models.Download{})
+
+	db.Create(&models.Product{Name: "Ebook", IsDigital: true})
+	db.Create(&models.Product{Name: "Mug"})
+	db.Create(&models.Product{Name: "Second Ebook", IsDigital: true})
+	db.Create(&models.Product{Name: "Bundle", IsBundle: true})
+	db.Create(&models.BundleItem{BundleID: 4, ComponentID: 3, Quantity: 1})
+	for productID := uint(1); productID <= 4; productID++ {
+		db.Create(&models.ProductFile{ProductID: productID, FileName: "file.pdf", Path: "file.pdf"})
+	}
+
+	order := models.Order{UserID: 1}
+	db.Create(&order)
+	for _, productID := range []int{1, 2, 4} {
+		db.Create(&models.OrderItem{OrderID: order.ID, ProductID: productID, Quantity: 1})
+	}
+	return db, order
+}
+
+func TestGrant(t *testing.T) {
+	db, order := digitalDB(t)
+
+	// Granting twice, as a payment retried would
+	for i := 0; i < 2; i++ {
+		if err := Grant(db, order); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var files []uint
+	db.Model(&models.DownloadGrant{}).Order("product_file_id").Pluck("product_file_id", &files)
+	if len(files) != 2 || files[0] != 1 || files[1] != 3 {
+		t.Errorf("granted files = %v, want [1 3]", files)
+	}
+}
+
+func TestConsume(t *testing.T) {
+	db, order := digitalDB(t)
+
+	grant := models.DownloadGrant{OrderID: order.ID, ProductFileID: 1, UserID: 1, DownloadLimit: 2, ExpiresAt: time.Now().Add(time.Hour)}
+	db.Create(&grant)
+
+	for i := 0; i < 2; i++ {
+		if err := Consume(db, grant, "127.0.0.1", "test"); err != nil {
+			t.Fatalf("download %d = %v", i+1, err)
+		}
+	}
+	if err := Consume(db, grant, "127.0.0.1", "test"); !errors.Is(err, ErrLimitReached) {
+		t.Errorf("download past the limit = %v, want ErrLimitReached", err)
+	}
+
+	var downloads int64
+	db.Model(&models.Download{}).Count(&downloads)
+	if downloads != 2 {
+		t.Errorf("%d downloads recorded, want 2", downloads)
+	}
+
+	grant.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := Consume(db, grant, "127.0.0.1", "test"); !errors.Is(err, ErrAccessExpired) {
+		t.Errorf("download after expiry = %v, want ErrAccessExpired", err)
+	}
+}
@@ -0,0 +1,101 @@
+package giftcard
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// ErrRefund - the refund is more than what is left to refund of the order
+var ErrRefund = errors.New("Refund is more than what is left to refund of the order")
+
+// CreditBalance - the store credit of the user in the currency
+func CreditBalance(db *gorm.DB, userID uint, currency string) money.Amount {
+	var balance money.Amount
+	db.Model(&models.StoreCredit{}).Where("user_id = ? AND currency = ?", userID, currency).
+		Select("COALESCE(SUM(amount_minor), 0)").Scan(&balance)
+	return balance
+}
+
+// CreditBalances - the store credit of the user by currency, currencies the
+// user never had credit in are left out
+func CreditBalances(db *gorm.DB, userID uint) map[string]money.Amount {
+	var rows []struct {
+		Currency string
+		Balance  money.Amount
+	}
+	db.Model(&models.StoreCredit{}).Where("user_id = ?", userID).
+		Select("currency, SUM(amount_minor) AS balance").Group("currency").Scan(&rows)
+
+	balances := map[string]money.Amount{}
+	for _, row := range rows {
+		balances[row.Currency] = row.Balance
+	}
+	return balances
+}
+
+// CreditHistory - the changes to the store credit of the user, newest first
+func CreditHistory(db *gorm.DB, userID uint) []models.StoreCredit {
+	history := []models.StoreCredit{}
+	db.Where("user_id = ?", userID).Order("id desc").Find(&history)
+	return history
+}
+
+// Credit - adds the amount to the store credit of the user and records it,
+// failing with ErrBalance when the credit would go below zero
+func Credit(tx *gorm.DB, userID uint, currency string, orderID *uint, kind string, amount money.Amount, note string) (models.StoreCredit, error) {
+	// The balance is summed in the insert itself, so concurrent orders can not
+	// both spend the same credit
+	now := time.Now()
+	result := tx.Exec(`INSERT INTO store_credits
+		(created_at, updated_at, user_id, currency, order_id, kind, amount_minor, balance_minor, note)
+		SELECT ?, ?, ?, ?, ?, ?, ?, account.balance + ?, ?
+		FROM (SELECT COALESCE(SUM(amount_minor), 0) AS balance FROM store_credits
+			WHERE user_id = ? AND currency = ? AND deleted_at IS NULL) AS account
+		WHERE account.balance + ? >= 0`,
+		now, now, userID, currency, orderID, kind, amount, amount, note,
+		userID, currency, amount)
+	if result.Error != nil {
+		return models.StoreCredit{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.StoreCredit{}, ErrBalance
+	}
+
+	entry := models.StoreCredit{}
+	err := tx.Where("user_id = ? AND currency = ?", userID, currency).Order("id desc").First(&entry).Error
+	return entry, err
+}
+
+// Refunded - what was refunded of the order as store credit
+func Refunded(db *gorm.DB, orderID uint) money.Amount {
+	var refunded money.Amount
+	db.Model(&models.StoreCredit{}).Where("order_id = ? AND kind = ?", orderID, models.CreditRefund).
+		Select("COALESCE(SUM(amount_minor), 0)").Scan(&refunded)
+	return refunded
+}
+
+// Refundable - what is left to refund of the order, less what the gift cards
+// it bought are still worth
+func Refundable(db *gorm.DB, order models.Order) money.Amount {
+	return order.Price - Refunded(db, order.ID) - Kept(db, order.ID)
+}
+
+// Refund - gives the buyer of the order store credit for the amount in the
+// currency of the order, all that is left to refund when the amount is 0
+func Refund(tx *gorm.DB, order models.Order, amount money.Amount, note string) (models.StoreCredit, error) {
+	left := Refundable(tx, order)
+	if amount == 0 {
+		amount = left
+	}
+
+	if amount <= 0 || amount > left {
+		return models.StoreCredit{}, ErrRefund
+	}
+
+	orderID := order.ID
+	return Credit(tx, uint(order.UserID), order.Currency, &orderID, models.CreditRefund, amount, note)
+}
@@ -0,0 +1,260 @@
+package giftcard
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound - no gift card has the code
+	ErrNotFound = errors.New("Gift card not found")
+	// ErrExpired - the gift card can not be used anymore
+	ErrExpired = errors.New("Gift card has expired")
+	// ErrEmpty - the gift card has nothing left to spend
+	ErrEmpty = errors.New("Gift card has no balance left")
+	// ErrCurrency - the gift card can not pay in the currency of the order
+	ErrCurrency = errors.New("Gift card is in another currency than the order")
+	// ErrBalance - a change would take the balance below zero
+	ErrBalance = errors.New("Balance can not go below zero")
+)
+
+// codeAlphabet - letters and digits that can not be mistaken for each other
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// codeLength - characters in a code, shown in groups of four
+const codeLength = 16
+
+// Normalize - codes are kept upper case in dashed groups of four, whatever
+// spaces or dashes they were typed with
+func Normalize(code string) string {
+	var plain strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			plain.WriteRune(r)
+		}
+	}
+
+	var grouped strings.Builder
+	for i, r := range plain.String() {
+		if i > 0 && i%4 == 0 {
+			grouped.WriteByte('-')
+		}
+		grouped.WriteRune(r)
+	}
+	return grouped.String()
+}
+
+// Mask - the code with all but its last group hidden
+func Mask(code string) string {
+	masked := []byte(code)
+	for i := 0; i < strings.LastIndexByte(code, '-'); i++ {
+		if masked[i] != '-' {
+			masked[i] = '*'
+		}
+	}
+	return string(masked)
+}
+
+// NewCode - a random code
+func NewCode() (string, error) {
+	code := make([]byte, codeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return Normalize(string(code)), nil
+}
+
+// Find - the gift card with the code
+func Find(db *gorm.DB, code string) (models.GiftCard, error) {
+	card := models.GiftCard{}
+	if code = Normalize(code); code != "" {
+		db.Where("code = ?", code).Limit(1).Find(&card)
+	}
+
+	if card.ID == 0 {
+		return card, ErrNotFound
+	}
+	return card, nil
+}
+
+// Usable - whether the card can pay in the currency at now
+func Usable(card models.GiftCard, currency string, now time.Time) error {
+	switch {
+	case card.ExpiresAt != nil && !now.Before(*card.ExpiresAt):
+		return ErrExpired
+	case card.Currency != currency:
+		return ErrCurrency
+	case card.Balance <= 0:
+		return ErrEmpty
+	}
+	return nil
+}
+
+// Issue - saves a new card with its initial balance, giving it a random code
+// when it has none
+func Issue(tx *gorm.DB, card *models.GiftCard) error {
+	card.Code = Normalize(card.Code)
+
+	if card.Code == "" {
+		// Codes are random enough that a second try is already unlikely
+		for tries := 0; tries < 5; tries++ {
+			code, err := NewCode()
+			if err != nil {
+				return err
+			}
+
+			var taken int64
+			tx.Unscoped().Model(&models.GiftCard{}).Where("code = ?", code).Count(&taken)
+			if taken == 0 {
+				card.Code = code
+				break
+			}
+		}
+		if card.Code == "" {
+			return errors.New("Could not find a free gift card code")
+		}
+	}
+
+	card.Balance = card.Initial
+	if err := tx.Create(card).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.GiftCardTransaction{
+		GiftCardID: card.ID,
+		Kind:       models.CreditIssue,
+		Amount:     card.Initial,
+		Balance:    card.Balance,
+		Note:       card.Note,
+	}).Error
+}
+
+// Change - adds the amount to the balance of the card and records it, failing
+// with ErrBalance when the balance would go below zero
+func Change(tx *gorm.DB, card *models.GiftCard, orderID *uint, kind string, amount money.Amount, note string) error {
+	// A conditional update so concurrent orders can not overspend the card
+	result := tx.Model(&models.GiftCard{}).
+		Where("id = ? AND balance_minor + ? >= 0", card.ID, amount).
+		UpdateColumn("balance_minor", gorm.Expr("balance_minor + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrBalance
+	}
+
+	if err := tx.Select("balance_minor").First(card, card.ID).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.GiftCardTransaction{
+		GiftCardID: card.ID,
+		OrderID:    orderID,
+		Kind:       kind,
+		Amount:     amount,
+		Balance:    card.Balance,
+		Note:       note,
+	}).Error
+}
+
+// Transactions - the changes to the balance of a card, oldest first
+func Transactions(db *gorm.DB, cardID uint) []models.GiftCardTransaction {
+	transactions := []models.GiftCardTransaction{}
+	db.Where("gift_card_id = ?", cardID).Order("id").Find(&transactions)
+	return transactions
+}
+
+// IssuePurchased - issues the gift cards a paid order bought, one per unit
+// worth what was paid for it after discounts. Items that already have their
+// cards are left alone.
+func IssuePurchased(tx *gorm.DB, order models.Order) error {
+	var orderItems []models.OrderItem
+	if err := tx.Joins("JOIN products ON products.id = order_items.product_id").
+		Where("order_items.order_id = ? AND products.is_gift_card = ?", order.ID, true).
+		Find(&orderItems).Error; err != nil {
+		return err
+	}
+
+	for _, orderItem := range orderItems {
+		var issued int64
+		tx.Model(&models.GiftCard{}).Where("order_item_id = ?", orderItem.ID).Count(&issued)
+		if issued > 0 || orderItem.Quantity <= 0 {
+			continue
+		}
+
+		// The cents the discount leaves over go to the first cards
+		units := make([]money.Amount, orderItem.Quantity)
+		for n := range units {
+			units[n] = 1
+		}
+		values := (orderItem.Price - orderItem.Discount).Allocate(units)
+
+		userID := uint(order.UserID)
+		itemID := orderItem.ID
+		for _, value := range values {
+			if value <= 0 {
+				continue
+			}
+
+			card := models.GiftCard{
+				Currency:    order.Currency,
+				Initial:     value,
+				UserID:      &userID,
+				OrderItemID: &itemID,
+				Note:        fmt.Sprintf("Bought with order %d", order.ID),
+			}
+			if err := Issue(tx, &card); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// VoidPurchased - takes what is left on the gift cards an order bought off
+// them, for orders that are refunded or cancelled
+func VoidPurchased(tx *gorm.DB, order models.Order, note string) error {
+	orderID := order.ID
+	for _, card := range Purchased(tx, order.ID) {
+		if card.Balance <= 0 {
+			continue
+		}
+		if err := Change(tx, &card, &orderID, models.CreditVoid, -card.Balance, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Kept - what the gift cards an order bought are still worth to the buyer,
+// what they were issued with less what was voided. Spent or not, it is not
+// refunded.
+func Kept(db *gorm.DB, orderID uint) money.Amount {
+	var issued, voided money.Amount
+	db.Model(&models.GiftCard{}).Joins("JOIN order_items ON order_items.id = gift_cards.order_item_id").
+		Where("order_items.order_id = ?", orderID).
+		Select("COALESCE(SUM(gift_cards.initial_minor), 0)").Scan(&issued)
+	db.Model(&models.GiftCardTransaction{}).Where("order_id = ? AND kind = ?", orderID, models.CreditVoid).
+		Select("COALESCE(SUM(amount_minor), 0)").Scan(&voided)
+	return issued + voided
+}
+
+// Purchased - the gift cards an order bought
+func Purchased(db *gorm.DB, orderID uint) []models.GiftCard {
+	cards := []models.GiftCard{}
+	db.Joins("JOIN order_items ON order_items.id = gift_cards.order_item_id").
+		Where("order_items.order_id = ?", orderID).Order("gift_cards.id").Find(&cards)
+	return cards
+}
@@ -0,0 +1,155 @@
+package giftcard
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// FindUsable - the cards with the codes, each once, checked to pay an order
+// in the currency at now
+func FindUsable(db *gorm.DB, codes []string, currency string, now time.Time) ([]models.GiftCard, error) {
+	cards := []models.GiftCard{}
+	seen := map[uint]bool{}
+
+	for _, code := range codes {
+		card, err := Find(db, code)
+		if err != nil {
+			return nil, err
+		}
+		if seen[card.ID] {
+			continue
+		}
+		seen[card.ID] = true
+
+		if err := Usable(card, currency, now); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, Mask(card.Code))
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// Pay - takes what is due of the order from the cards in turn, then from the
+// store credit of the buyer when useCredit is set, as far as they go. The
+// order's Redeemed adds up what they paid, the rest is paid the usual way.
+func Pay(tx *gorm.DB, order *models.Order, cards []models.GiftCard, useCredit bool) error {
+	orderID := order.ID
+	note := fmt.Sprintf("Order %d", order.ID)
+
+	pay := func(payment models.OrderPayment) error {
+		order.Redeemed += payment.Amount
+		return tx.Create(&payment).Error
+	}
+
+	for i := range cards {
+		due := order.Price - order.Redeemed
+		if due <= 0 {
+			break
+		}
+
+		// The balance is read again, an earlier order may have spent some
+		card := cards[i]
+		tx.First(&card, card.ID)
+
+		take := card.Balance
+		if card.Currency != order.Currency {
+			take = 0
+		}
+		if take > due {
+			take = due
+		}
+		if take <= 0 {
+			continue
+		}
+
+		if err := Change(tx, &card, &orderID, models.CreditRedeem, -take, note); err != nil {
+			return err
+		}
+
+		cardID := card.ID
+		if err := pay(models.OrderPayment{OrderID: order.ID, Method: models.PaymentGiftCard, GiftCardID: &cardID, Amount: take}); err != nil {
+			return err
+		}
+	}
+
+	if due := order.Price - order.Redeemed; useCredit && due > 0 {
+		take := CreditBalance(tx, uint(order.UserID), order.Currency)
+		if take > due {
+			take = due
+		}
+
+		if take > 0 {
+			if _, err := Credit(tx, uint(order.UserID), order.Currency, &orderID, models.CreditRedeem, -take, note); err != nil {
+				return err
+			}
+			if err := pay(models.OrderPayment{OrderID: order.ID, Method: models.PaymentStoreCredit, Amount: take}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Model(&models.Order{}).Where("id = ?", order.ID).UpdateColumn("redeemed_minor", order.Redeemed).Error
+}
+
+// Release - gives what the order took from gift cards and store credit back,
+// returning the cards and whether store credit was used so the order can be
+// paid again the same way
+func Release(tx *gorm.DB, order *models.Order) ([]models.GiftCard, bool, error) {
+	orderID := order.ID
+	note := fmt.Sprintf("Order %d", order.ID)
+
+	cards := []models.GiftCard{}
+	usedCredit := false
+
+	for _, payment := range Payments(tx, order.ID) {
+		switch payment.Method {
+		case models.PaymentGiftCard:
+			card := models.GiftCard{}
+			tx.Unscoped().First(&card, payment.GiftCardID)
+
+			if card.ID != 0 {
+				if err := Change(tx, &card, &orderID, models.CreditRelease, payment.Amount, note); err != nil {
+					return nil, false, err
+				}
+				cards = append(cards, card)
+			}
+
+		case models.PaymentStoreCredit:
+			if _, err := Credit(tx, uint(order.UserID), order.Currency, &orderID, models.CreditRelease, payment.Amount, note); err != nil {
+				return nil, false, err
+			}
+			usedCredit = true
+		}
+	}
+
+	if err := tx.Unscoped().Where("order_id = ?", order.ID).Delete(&models.OrderPayment{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	order.Redeemed = 0
+	return cards, usedCredit, tx.Model(&models.Order{}).Where("id = ?", order.ID).UpdateColumn("redeemed_minor", money.Amount(0)).Error
+}
+
+// Payments - what gift cards and store credit paid of an order
+func Payments(db *gorm.DB, orderID uint) []models.OrderPayment {
+	payments := []models.OrderPayment{}
+	db.Where("order_id = ?", orderID).Order("id").Find(&payments)
+	return payments
+}
+
+// Released - whether the order paid with gift cards or store credit and gave
+// it all back
+func Released(db *gorm.DB, orderID uint) bool {
+	if len(Payments(db, orderID)) > 0 {
+		return false
+	}
+
+	var redeemed, credited int64
+	db.Model(&models.GiftCardTransaction{}).Where("order_id = ? AND kind = ?", orderID, models.CreditRedeem).Count(&redeemed)
+	db.Model(&models.StoreCredit{}).Where("order_id = ? AND kind = ?", orderID, models.CreditRedeem).Count(&credited)
+	return redeemed+credited > 0
+}
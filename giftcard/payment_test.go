@@ -0,0 +1,254 @@
+package giftcard
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// giftCardDB - an empty database with the tables gift cards need
+func giftCardDB(t *testing.T) *gorm.DB {
+	return testdb.Open(t, &models.Order{}, &models.GiftCard{}, &models.GiftCardTransaction{},
+		&models.StoreCredit{}, &models.OrderPayment{})
+}
+
+// card - a gift card for the tests
+type card struct {
+	currency string
+	balance  money.Amount
+}
+
+// setup - an order for the price with the cards and the store credit of its
+// buyer
+func setup(t *testing.T, db *gorm.DB, price money.Amount, cards []card, credit money.Amount) (models.Order, []models.GiftCard) {
+	t.Helper()
+
+	order := models.Order{UserID: 1, Currency: "USD", ExchangeRate: 1, Price: price}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	issued := make([]models.GiftCard, len(cards))
+	for i, c := range cards {
+		issued[i] = models.GiftCard{Code: fmt.Sprintf("TEST-%04d", i), Currency: c.currency, Initial: c.balance}
+		if err := Issue(db, &issued[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if credit > 0 {
+		if _, err := Credit(db, 1, "USD", nil, models.CreditAdjust, credit, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return order, issued
+}
+
+// balances - what is left on the cards
+func balances(db *gorm.DB, cards []models.GiftCard) []money.Amount {
+	left := make([]money.Amount, len(cards))
+	for i := range cards {
+		current := models.GiftCard{}
+		db.First(&current, cards[i].ID)
+		left[i] = current.Balance
+	}
+	return left
+}
+
+var payTests = []struct {
+	name      string
+	price     money.Amount
+	cards     []card
+	credit    money.Amount
+	useCredit bool
+	// What the cards and store credit paid, and what is left on them
+	redeemed money.Amount
+	left     []money.Amount
+	creditOf money.Amount
+	payments int
+}{
+	{
+		name:     "one card covers the order",
+		price:    3000,
+		cards:    []card{{"USD", 5000}},
+		redeemed: 3000,
+		left:     []money.Amount{2000},
+		payments: 1,
+	},
+	{
+		name:     "cards in turn",
+		price:    3000,
+		cards:    []card{{"USD", 1000}, {"USD", 5000}},
+		redeemed: 3000,
+		left:     []money.Amount{0, 3000},
+		payments: 2,
+	},
+	{
+		name:     "cards pay part",
+		price:    3000,
+		cards:    []card{{"USD", 1000}},
+		redeemed: 1000,
+		left:     []money.Amount{0},
+		payments: 1,
+	},
+	{
+		name:     "cards in another currency are skipped",
+		price:    3000,
+		cards:    []card{{"EUR", 5000}, {"USD", 500}},
+		redeemed: 500,
+		left:     []money.Amount{5000, 0},
+		payments: 1,
+	},
+	{
+		name:      "store credit after the cards",
+		price:     3000,
+		cards:     []card{{"USD", 1000}},
+		credit:    5000,
+		useCredit: true,
+		redeemed:  3000,
+		left:      []money.Amount{0},
+		creditOf:  3000,
+		payments:  2,
+	},
+	{
+		name:      "store credit pays part",
+		price:     3000,
+		credit:    1000,
+		useCredit: true,
+		redeemed:  1000,
+		left:      []money.Amount{},
+		creditOf:  0,
+		payments:  1,
+	},
+	{
+		name:     "store credit only when asked",
+		price:    3000,
+		credit:   5000,
+		redeemed: 0,
+		left:     []money.Amount{},
+		creditOf: 5000,
+	},
+}
+
+func TestPay(t *testing.T) {
+	for _, test := range payTests {
+		t.Run(test.name, func(t *testing.T) {
+			db := giftCardDB(t)
+			order, cards := setup(t, db, test.price, test.cards, test.credit)
+
+			if err := Pay(db, &order, cards, test.useCredit); err != nil {
+				t.Fatal(err)
+			}
+
+			if order.Redeemed != test.redeemed {
+				t.Errorf("redeemed = %s, want %s", order.Redeemed, test.redeemed)
+			}
+			stored := models.Order{}
+			db.First(&stored, order.ID)
+			if stored.Redeemed != test.redeemed {
+				t.Errorf("stored redeemed = %s, want %s", stored.Redeemed, test.redeemed)
+			}
+			if left := balances(db, cards); !reflect.DeepEqual(left, test.left) {
+				t.Errorf("cards left = %v, want %v", left, test.left)
+			}
+			if credit := CreditBalance(db, 1, "USD"); credit != test.creditOf {
+				t.Errorf("store credit = %s, want %s", credit, test.creditOf)
+			}
+			if payments := Payments(db, order.ID); len(payments) != test.payments {
+				t.Errorf("payments = %d, want %d", len(payments), test.payments)
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	for _, test := range payTests {
+		t.Run(test.name, func(t *testing.T) {
+			db := giftCardDB(t)
+			order, cards := setup(t, db, test.price, test.cards, test.credit)
+
+			if err := Pay(db, &order, cards, test.useCredit); err != nil {
+				t.Fatal(err)
+			}
+
+			released, usedCredit, err := Release(db, &order)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := make([]money.Amount, len(test.cards))
+			paidWithCards := 0
+			for i, c := range test.cards {
+				want[i] = c.balance
+				if c.balance != test.left[i] {
+					paidWithCards++
+				}
+			}
+			if left := balances(db, cards); !reflect.DeepEqual(left, want) {
+				t.Errorf("cards left = %v, want %v", left, want)
+			}
+			if credit := CreditBalance(db, 1, "USD"); credit != test.credit {
+				t.Errorf("store credit = %s, want %s", credit, test.credit)
+			}
+			if len(released) != paidWithCards {
+				t.Errorf("released cards = %d, want %d", len(released), paidWithCards)
+			}
+			if wantCredit := test.useCredit && test.creditOf != test.credit; usedCredit != wantCredit {
+				t.Errorf("used credit = %v, want %v", usedCredit, wantCredit)
+			}
+			if order.Redeemed != 0 || len(Payments(db, order.ID)) != 0 {
+				t.Errorf("order still has payments, redeemed %s", order.Redeemed)
+			}
+			if test.redeemed > 0 && !Released(db, order.ID) {
+				t.Errorf("order is not released")
+			}
+		})
+	}
+}
+
+func TestCredit(t *testing.T) {
+	tests := []struct {
+		name    string
+		amounts []money.Amount
+		balance money.Amount
+		err     error
+	}{
+		{"credit", []money.Amount{1000}, 1000, nil},
+		{"debit what is there", []money.Amount{1000, -1000}, 0, nil},
+		{"debit more than there is", []money.Amount{1000, -1001}, 1000, ErrBalance},
+		{"debit without credit", []money.Amount{-1}, 0, ErrBalance},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := giftCardDB(t)
+
+			var err error
+			var entry models.StoreCredit
+			for _, amount := range test.amounts {
+				if entry, err = Credit(db, 1, "USD", nil, models.CreditAdjust, amount, ""); err != nil {
+					break
+				}
+				if entry.ID == 0 || entry.Amount != amount {
+					t.Errorf("entry = %+v, want one for %s", entry, amount)
+				}
+			}
+
+			if !errors.Is(err, test.err) {
+				t.Errorf("error = %v, want %v", err, test.err)
+			}
+			if balance := CreditBalance(db, 1, "USD"); balance != test.balance {
+				t.Errorf("balance = %s, want %s", balance, test.balance)
+			}
+			if err == nil && entry.Balance != test.balance {
+				t.Errorf("entry balance = %s, want %s", entry.Balance, test.balance)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package i18n
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rama-kairi/fiber-api/config"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// IsSupported - whether content can be kept in the locale
+func IsSupported(locale string) bool {
+	for _, supported := range config.GetConfig().Locale.Supported {
+		if supported == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate - the locales to look content up in, most wanted first and ending
+// with the default locale. A locale asked for explicitly has to be supported,
+// from Accept-Language the unsupported ones are skipped. Regional locales fall
+// back to their language, de-at to de.
+func Negotiate(requested string, acceptLanguage string) ([]string, error) {
+	cfg := config.GetConfig().Locale
+
+	tags := acceptedLocales(acceptLanguage)
+	if requested != "" {
+		tags = []string{config.NormalizeLocale(requested)}
+	}
+
+	seen := map[string]bool{}
+	chain := []string{}
+	for _, tag := range tags {
+		for _, locale := range []string{tag, strings.SplitN(tag, "-", 2)[0]} {
+			if !seen[locale] && IsSupported(locale) {
+				seen[locale] = true
+				chain = append(chain, locale)
+			}
+		}
+	}
+
+	if requested != "" && len(chain) == 0 {
+		return nil, errors.New("Unsupported locale " + requested)
+	}
+
+	if !seen[cfg.Default] {
+		chain = append(chain, cfg.Default)
+	}
+	return chain, nil
+}
+
+// acceptedLocales - the locales of an Accept-Language header by preference
+func acceptedLocales(header string) []string {
+	type accepted struct {
+		locale  string
+		quality float64
+	}
+
+	locales := []accepted{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		locale := config.NormalizeLocale(fields[0])
+		if locale == "" || locale == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			if value := strings.TrimPrefix(strings.TrimSpace(param), "q="); value != strings.TrimSpace(param) {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		locales = append(locales, accepted{locale, quality})
+	}
+
+	sort.SliceStable(locales, func(i, j int) bool {
+		return locales[i].quality > locales[j].quality
+	})
+
+	tags := make([]string, len(locales))
+	for i, l := range locales {
+		tags[i] = l.locale
+	}
+	return tags
+}
+
+// Product - the name and description of a product in the first locale of the
+// chain that has them. Each falls back on its own, so a missing description
+// does not hide a translated name.
+func Product(db *gorm.DB, product models.Product, chain []string) (string, string) {
+	var translations []models.ProductTranslation
+	db.Where("product_id = ? AND locale IN ?", product.ID, chain).Find(&translations)
+
+	byLocale := map[string]models.ProductTranslation{}
+	for _, translation := range translations {
+		byLocale[translation.Locale] = translation
+	}
+	byLocale[config.GetConfig().Locale.Default] = models.ProductTranslation{
+		Name:        product.Name,
+		Description: product.Description,
+	}
+
+	name, description := "", ""
+	for _, locale := range chain {
+		translation := byLocale[locale]
+		if name == "" {
+			name = translation.Name
+		}
+		if description == "" {
+			description = translation.Description
+		}
+	}
+	return name, description
+}
+
+// Category - the display name of a product type in the first locale of the
+// chain that has one, the product type itself otherwise
+func Category(db *gorm.DB, productType string, chain []string) string {
+	if productType == "" {
+		return ""
+	}
+
+	var translations []models.CategoryTranslation
+	db.Where("product_type = ? AND locale IN ?", productType, chain).Find(&translations)
+
+	byLocale := map[string]string{}
+	for _, translation := range translations {
+		byLocale[translation.Locale] = translation.Name
+	}
+
+	for _, locale := range chain {
+		if name := byLocale[locale]; name != "" {
+			return name
+		}
+	}
+	return productType
+}
@@ -0,0 +1,75 @@
+package i18n
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+)
+
+// The default settings are in use: en by default, en, de, fr and es supported
+
+func TestNegotiate(t *testing.T) {
+	testdb.Env(t)
+
+	tests := []struct {
+		name           string
+		requested      string
+		acceptLanguage string
+		want           []string
+		fails          bool
+	}{
+		{"nothing asked", "", "", []string{"en"}, false},
+		{"requested", "fr", "de", []string{"fr", "en"}, false},
+		{"requested region", "de_AT", "", []string{"de", "en"}, false},
+		{"requested unsupported", "it", "", nil, true},
+		{"by quality", "", "fr;q=0.5, de-CH, it;q=0.9", []string{"de", "fr", "en"}, false},
+		{"refused language", "", "es;q=0, *", []string{"en"}, false},
+	}
+
+	for _, test := range tests {
+		got, err := Negotiate(test.requested, test.acceptLanguage)
+		if (err != nil) != test.fails {
+			t.Errorf("%s: Negotiate() error = %v, want failure %v", test.name, err, test.fails)
+			continue
+		}
+		if !test.fails && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Negotiate() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestProduct(t *testing.T) {
+	testdb.Env(t)
+
+	db := testdb.Open(t, &models.Product{}, &models.ProductTranslation{}, &models.CategoryTranslation{})
+	product := models.Product{Name: "Mug", Description: "A mug", ProductType: "kitchen"}
+	db.Create(&product)
+	db.Create(&models.ProductTranslation{ProductID: product.ID, Locale: "de", Name: "Becher"})
+	db.Create(&models.ProductTranslation{ProductID: product.ID, Locale: "fr", Name: "Tasse", Description: "Une tasse"})
+	db.Create(&models.CategoryTranslation{ProductType: "kitchen", Locale: "fr", Name: "Cuisine"})
+
+	tests := []struct {
+		chain       []string
+		name        string
+		description string
+		category    string
+	}{
+		{[]string{"en"}, "Mug", "A mug", "kitchen"},
+		{[]string{"fr", "en"}, "Tasse", "Une tasse", "Cuisine"},
+		// Each field falls back on its own
+		{[]string{"de", "fr", "en"}, "Becher", "Une tasse", "Cuisine"},
+		{[]string{"de", "en"}, "Becher", "A mug", "kitchen"},
+	}
+
+	for _, test := range tests {
+		name, description := Product(db, product, test.chain)
+		if name != test.name || description != test.description {
+			t.Errorf("Product(%v) = %q, %q, want %q, %q", test.chain, name, description, test.name, test.description)
+		}
+		if got := Category(db, product.ProductType, test.chain); got != test.category {
+			t.Errorf("Category(%v) = %q, want %q", test.chain, got, test.category)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Package testdb - throwaway databases and settings for package tests
+package testdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open - an empty in-memory database with the tables of the models, gone
+// once the test is over
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection to :memory: is a database of its own, so there is one
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Env - runs the test from a directory with an empty .env, the config refuses
+// to load without one and falls back to its defaults with it
+func Env(t testing.TB) {
+	t.Helper()
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := t.TempDir()
+	if err := os.WriteFile(filepath.Join(env, ".env"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(env); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+}
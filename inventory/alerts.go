@@ -0,0 +1,116 @@
+package inventory
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/notify"
+	"gorm.io/gorm"
+)
+
+// watched - products waiting for the low-stock check
+var watched = make(chan uint, 1024)
+
+// StartAlerts - runs the low-stock check in the background and delivers the
+// alerts through the notifier. Checked products also go to the back-in-stock
+// job.
+func StartAlerts(db *gorm.DB, notifier notify.Notifier) {
+	go func() {
+		for productID := range watched {
+			for _, id := range affected(db, productID) {
+				if err := checkLowStock(db, notifier, id); err != nil {
+					log.Println("Low stock check failed: ", err.Error())
+				}
+				queueRestock(id)
+			}
+		}
+	}()
+}
+
+// Watch - queues products for the low-stock check. Call it after the
+// transaction that changed their stock has committed. Never blocks.
+func Watch(productIDs ...uint) {
+	for _, id := range productIDs {
+		select {
+		case watched <- id:
+		default:
+			log.Println("Low stock queue is full, skipping product ", id)
+		}
+	}
+}
+
+// WatchItems - queues the products of the order items for the low-stock check.
+func WatchItems(items []models.OrderItem) {
+	ids, _ := quantities(items)
+	Watch(ids...)
+}
+
+// IsLowStock - whether the product is at or below its reorder threshold.
+func IsLowStock(product models.Product) bool {
+	return product.ReorderThreshold > 0 && product.Quantity <= product.ReorderThreshold
+}
+
+// checkLowStock - alerts once when a product crosses its threshold and rearms
+// when it is restocked above it.
+func checkLowStock(db *gorm.DB, notifier notify.Notifier, productID uint) error {
+	product := models.Product{}
+	if err := db.First(&product, productID).Error; err != nil {
+		return err
+	}
+	product.Quantity = Available(db, product)
+
+	low := IsLowStock(product)
+	if low == product.LowStockAlerted {
+		return nil
+	}
+
+	if err := db.Model(&product).UpdateColumn("low_stock_alerted", low).Error; err != nil {
+		return err
+	}
+
+	if !low {
+		return nil
+	}
+
+	return notifier.Notify(notify.Message{
+		Event:   "low_stock",
+		Subject: fmt.Sprintf("Low stock: %s", product.Name),
+		Body: fmt.Sprintf("%s has %d left, reorder threshold is %d.",
+			product.Name, product.Quantity, product.ReorderThreshold),
+		Data: map[string]interface{}{
+			"product_id":        product.ID,
+			"name":              product.Name,
+			"quantity":          product.Quantity,
+			"reorder_threshold": product.ReorderThreshold,
+		},
+	})
+}
+
+// LowStock - returns the products at or below their reorder threshold, the
+// furthest below it first. Bundles are measured by their available quantity.
+func LowStock(db *gorm.DB) ([]models.Product, error) {
+	var products []models.Product
+	if err := db.Where("is_bundle = ? AND reorder_threshold > 0 AND quantity <= reorder_threshold", false).
+		Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	var bundles []models.Product
+	if err := db.Where("is_bundle = ? AND reorder_threshold > 0", true).Find(&bundles).Error; err != nil {
+		return nil, err
+	}
+
+	for _, bundle := range bundles {
+		bundle.Quantity = Available(db, bundle)
+		if IsLowStock(bundle) {
+			products = append(products, bundle)
+		}
+	}
+
+	sort.SliceStable(products, func(i, j int) bool {
+		return products[i].Quantity-products[i].ReorderThreshold < products[j].Quantity-products[j].ReorderThreshold
+	})
+	return products, nil
+}
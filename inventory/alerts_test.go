@@ -0,0 +1,74 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/notify"
+)
+
+// recorder - a notifier keeping what it was given
+type recorder struct {
+	sent []notify.Message
+}
+
+func (r *recorder) Notify(msg notify.Message) error {
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func TestCheckLowStock(t *testing.T) {
+	db := stockDB(t, 5)
+	db.Model(&models.Product{}).Where("id = ?", 1).Update("reorder_threshold", 3)
+	notifier := &recorder{}
+
+	steps := []struct {
+		name   string
+		change int
+		// Alerts sent so far
+		alerts int
+	}{
+		{"above the threshold", -1, 0},
+		{"reaches the threshold", -1, 1},
+		{"stays below", -2, 1},
+		{"restocked above", 5, 1},
+		{"crosses again", -4, 2},
+	}
+
+	for _, step := range steps {
+		if err := Adjust(db, 1, step.change, models.MovementAdjustment, 0, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkLowStock(db, notifier, 1); err != nil {
+			t.Fatal(err)
+		}
+		if len(notifier.sent) != step.alerts {
+			t.Errorf("%s: %d alerts, want %d", step.name, len(notifier.sent), step.alerts)
+		}
+	}
+
+	if notifier.sent[0].Event != "low_stock" || notifier.sent[0].Data["quantity"] != 3 {
+		t.Errorf("alert = %+v", notifier.sent[0])
+	}
+}
+
+func TestLowStock(t *testing.T) {
+	db := stockDB(t, 5, 1, 2, 0)
+	for id, threshold := range map[int]int{1: 3, 2: 2, 3: 5} {
+		db.Model(&models.Product{}).Where("id = ?", id).Update("reorder_threshold", threshold)
+	}
+
+	products, err := LowStock(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Product 4 has no threshold, product 1 is above its own
+	var got []uint
+	for _, product := range products {
+		got = append(got, product.ID)
+	}
+	if len(got) != 2 || got[0] != 3 || got[1] != 2 {
+		t.Errorf("LowStock() = %v, want [3 2]", got)
+	}
+}
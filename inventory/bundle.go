@@ -0,0 +1,259 @@
+package inventory
+
+import (
+	"errors"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// ErrBundleStock - bundles have no stock of their own to adjust
+var ErrBundleStock = errors.New("Bundle stock comes from its components and can not be adjusted")
+
+// Component - a product inside a bundle and how many of it one bundle holds
+type Component struct {
+	ProductID uint   `json:"product_id"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+}
+
+// isBundle - whether the product is made of components
+func isBundle(tx *gorm.DB, productID uint) bool {
+	var count int64
+	tx.Model(&models.BundleItem{}).Where("bundle_id = ?", productID).Count(&count)
+	return count > 0
+}
+
+// Components - the current components of a bundle
+func Components(db *gorm.DB, bundleID uint) []Component {
+	var bundleItems []models.BundleItem
+	db.Preload("Component").Where("bundle_id = ?", bundleID).Order("component_id").Find(&bundleItems)
+
+	components := make([]Component, len(bundleItems))
+	for i, bundleItem := range bundleItems {
+		components[i] = Component{
+			ProductID: bundleItem.ComponentID,
+			Name:      bundleItem.Component.Name,
+			Quantity:  bundleItem.Quantity,
+		}
+	}
+	return components
+}
+
+// ItemComponents - the components one unit of a bundle order item holds, as
+// they were when it was ordered
+func ItemComponents(db *gorm.DB, item models.OrderItem) []Component {
+	var snapshot []models.OrderItemComponent
+	db.Where("order_item_id = ?", item.ID).Order("product_id").Find(&snapshot)
+
+	if len(snapshot) == 0 {
+		return Components(db, uint(item.ProductID))
+	}
+
+	components := make([]Component, len(snapshot))
+	for i, part := range snapshot {
+		product := models.Product{}
+		db.Unscoped().First(&product, part.ProductID)
+
+		components[i] = Component{
+			ProductID: part.ProductID,
+			Name:      product.Name,
+			Quantity:  part.Quantity,
+		}
+	}
+	return components
+}
+
+// Available - the stock of a product, for a bundle how many can be put
+// together from the component stock
+func Available(db *gorm.DB, product models.Product) int {
+	if !product.IsBundle {
+		return product.Quantity
+	}
+
+	var bundleItems []models.BundleItem
+	db.Preload("Component").Where("bundle_id = ?", product.ID).Find(&bundleItems)
+
+	available := -1
+	for _, bundleItem := range bundleItems {
+		// A deleted component can not be sold
+		if bundleItem.Component.ID == 0 || bundleItem.Quantity <= 0 {
+			return 0
+		}
+		// Digital components never run out
+		if bundleItem.Component.IsDigital {
+			continue
+		}
+
+		count := bundleItem.Component.Quantity / bundleItem.Quantity
+		if available == -1 || count < available {
+			available = count
+		}
+	}
+
+	if available < 0 {
+		return 0
+	}
+	return available
+}
+
+// expand - replaces bundle items by their components so stock is taken from
+// and given back to the components, and leaves out digital products. With
+// useSnapshot the components recorded when the item was ordered are used
+// instead of the current ones.
+func expand(tx *gorm.DB, items []models.OrderItem, useSnapshot bool) ([]models.OrderItem, error) {
+	productIDs := make([]uint, len(items))
+	itemIDs := []uint{}
+	for i, item := range items {
+		productIDs[i] = uint(item.ProductID)
+		if item.ID != 0 {
+			itemIDs = append(itemIDs, item.ID)
+		}
+	}
+
+	var bundleItems []models.BundleItem
+	if err := tx.Where("bundle_id IN ?", productIDs).Find(&bundleItems).Error; err != nil {
+		return nil, err
+	}
+
+	current := map[uint][]models.BundleItem{}
+	for _, bundleItem := range bundleItems {
+		current[bundleItem.BundleID] = append(current[bundleItem.BundleID], bundleItem)
+	}
+
+	snapshots := map[uint][]models.OrderItemComponent{}
+	if useSnapshot && len(itemIDs) > 0 {
+		var parts []models.OrderItemComponent
+		if err := tx.Where("order_item_id IN ?", itemIDs).Find(&parts).Error; err != nil {
+			return nil, err
+		}
+		for _, part := range parts {
+			snapshots[part.OrderItemID] = append(snapshots[part.OrderItemID], part)
+		}
+	}
+
+	part := func(item models.OrderItem, productID uint, quantity int) models.OrderItem {
+		return models.OrderItem{
+			OrderID:   item.OrderID,
+			ProductID: int(productID),
+			Quantity:  item.Quantity * quantity,
+		}
+	}
+
+	expanded := []models.OrderItem{}
+	for _, item := range items {
+		if snapshot, ok := snapshots[item.ID]; ok && item.ID != 0 {
+			for _, p := range snapshot {
+				expanded = append(expanded, part(item, p.ProductID, p.Quantity))
+			}
+			continue
+		}
+
+		components, ok := current[uint(item.ProductID)]
+		if !ok {
+			expanded = append(expanded, item)
+			continue
+		}
+		for _, component := range components {
+			expanded = append(expanded, part(item, component.ComponentID, component.Quantity))
+		}
+	}
+
+	// Digital products are delivered as downloads and never run out
+	stockIDs := make([]uint, len(expanded))
+	for i, item := range expanded {
+		stockIDs[i] = uint(item.ProductID)
+	}
+
+	var digitalIDs []uint
+	if err := tx.Model(&models.Product{}).Where("id IN ? AND is_digital = ?", stockIDs, true).Pluck("id", &digitalIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(digitalIDs) == 0 {
+		return expanded, nil
+	}
+
+	digital := map[uint]bool{}
+	for _, id := range digitalIDs {
+		digital[id] = true
+	}
+
+	stocked := []models.OrderItem{}
+	for _, item := range expanded {
+		if !digital[uint(item.ProductID)] {
+			stocked = append(stocked, item)
+		}
+	}
+	return stocked, nil
+}
+
+// snapshot - records the current components of the bundle items, replacing
+// what was recorded before
+func snapshot(tx *gorm.DB, items []models.OrderItem) error {
+	if err := clearSnapshots(tx, items); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if item.ID == 0 {
+			continue
+		}
+
+		var bundleItems []models.BundleItem
+		if err := tx.Where("bundle_id = ?", item.ProductID).Find(&bundleItems).Error; err != nil {
+			return err
+		}
+
+		for _, bundleItem := range bundleItems {
+			if err := tx.Create(&models.OrderItemComponent{
+				OrderItemID: item.ID,
+				ProductID:   bundleItem.ComponentID,
+				Quantity:    bundleItem.Quantity,
+			}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clearSnapshots - forgets the components recorded for the items
+func clearSnapshots(tx *gorm.DB, items []models.OrderItem) error {
+	itemIDs := []uint{}
+	for _, item := range items {
+		if item.ID != 0 {
+			itemIDs = append(itemIDs, item.ID)
+		}
+	}
+	if len(itemIDs) == 0 {
+		return nil
+	}
+
+	return tx.Unscoped().Where("order_item_id IN ?", itemIDs).Delete(&models.OrderItemComponent{}).Error
+}
+
+// affected - the products whose low-stock state may change with the stock of
+// a product: the product, the components of a bundle and the bundles the
+// components are part of
+func affected(db *gorm.DB, productID uint) []uint {
+	var componentIDs []uint
+	db.Model(&models.BundleItem{}).Where("bundle_id = ?", productID).Pluck("component_id", &componentIDs)
+
+	stocked := componentIDs
+	if len(stocked) == 0 {
+		stocked = []uint{productID}
+	}
+
+	var bundleIDs []uint
+	db.Model(&models.BundleItem{}).Where("component_id IN ?", stocked).Distinct().Pluck("bundle_id", &bundleIDs)
+
+	seen := map[uint]bool{}
+	ids := []uint{}
+	for _, id := range append(append([]uint{productID}, componentIDs...), bundleIDs...) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,75 @@
+package inventory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// bundleDB - products 1 and 2 with 5 and 3 in stock and product 3, a bundle of
+// two of product 1 and one of product 2
+func bundleDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := stockDB(t, 5, 3)
+	db.Create(&models.Product{Name: "Bundle", IsBundle: true})
+	db.Create(&models.BundleItem{BundleID: 3, ComponentID: 1, Quantity: 2})
+	db.Create(&models.BundleItem{BundleID: 3, ComponentID: 2, Quantity: 1})
+	return db
+}
+
+// available - what can be sold of the product
+func available(db *gorm.DB, productID uint) int {
+	product := models.Product{}
+	db.First(&product, productID)
+	return Available(db, product)
+}
+
+func TestBundleStock(t *testing.T) {
+	db := bundleDB(t)
+
+	if got := available(db, 3); got != 2 {
+		t.Fatalf("bundles available = %d, want 2", got)
+	}
+
+	ordered := models.OrderItem{OrderID: 1, ProductID: 3, Quantity: 2}
+	db.Create(&ordered)
+	if err := Decrement(db, []models.OrderItem{ordered}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := stockOf(db); !reflect.DeepEqual(got, []int{1, 1, 0}) {
+		t.Errorf("stock = %v, want [1 1 0]", got)
+	}
+	if got := available(db, 3); got != 0 {
+		t.Errorf("bundles available = %d, want 0", got)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return Decrement(tx, []models.OrderItem{item(3, 1)}, 1)
+	})
+	if !reflect.DeepEqual(shortOf(err), []uint{1}) {
+		t.Errorf("Decrement() = %v, want product 1 short", err)
+	}
+
+	// The bundle changes, the order gives back what it took
+	db.Unscoped().Where("bundle_id = ?", 3).Delete(&models.BundleItem{})
+	db.Create(&models.BundleItem{BundleID: 3, ComponentID: 2, Quantity: 3})
+
+	if err := Restock(db, []models.OrderItem{ordered}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := stockOf(db); !reflect.DeepEqual(got, []int{5, 3, 0}) {
+		t.Errorf("stock = %v, want it back at [5 3 0]", got)
+	}
+}
+
+func TestAdjustBundle(t *testing.T) {
+	db := bundleDB(t)
+
+	if err := Adjust(db, 3, 5, models.MovementRestock, 1, ""); !errors.Is(err, ErrBundleStock) {
+		t.Errorf("Adjust() = %v, want ErrBundleStock", err)
+	}
+}
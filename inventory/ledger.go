@@ -0,0 +1,258 @@
+package inventory
+
+import (
+	"sort"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// Drift - a product whose stock disagrees with its movement ledger, in total
+// or in some of its warehouses.
+type Drift struct {
+	ProductID  uint             `json:"product_id"`
+	Name       string           `json:"name"`
+	Quantity   int              `json:"quantity"`
+	Ledger     int              `json:"ledger"`
+	Drift      int              `json:"drift"`
+	Warehouses []WarehouseDrift `json:"warehouses,omitempty"`
+}
+
+// WarehouseDrift - the stock of a product in a warehouse that disagrees with
+// the movements there
+type WarehouseDrift struct {
+	WarehouseID uint `json:"warehouse_id"`
+	Quantity    int  `json:"quantity"`
+	Ledger      int  `json:"ledger"`
+	Drift       int  `json:"drift"`
+}
+
+// optionalID - stores 0 as NULL
+func optionalID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// Adjust - applies a signed change to a product's stock in the default
+// warehouse and records it. See AdjustAt.
+func Adjust(tx *gorm.DB, productID uint, change int, reason string, actorID uint, note string) error {
+	return AdjustAt(tx, 0, productID, change, reason, actorID, note)
+}
+
+// AdjustAt - applies a signed change to a product's stock in a warehouse, the
+// default one for 0, and records it. A negative change never takes the stock
+// below zero. Bundles can not be adjusted, their components are.
+func AdjustAt(tx *gorm.DB, warehouseID uint, productID uint, change int, reason string, actorID uint, note string) error {
+	if isBundle(tx, productID) {
+		return ErrBundleStock
+	}
+
+	if warehouseID == 0 {
+		warehouse, err := DefaultWarehouse(tx)
+		if err != nil {
+			return err
+		}
+		warehouseID = warehouse.ID
+	}
+
+	if change < 0 {
+		taken, err := take(tx, warehouseID, productID, -change)
+		if err != nil {
+			return err
+		}
+
+		if !taken {
+			product := models.Product{}
+			tx.First(&product, productID)
+
+			return &ShortageError{Shortages: []Shortage{{
+				ProductID: productID,
+				Name:      product.Name,
+				Requested: -change,
+				Available: warehouseQuantity(tx, warehouseID, productID),
+			}}}
+		}
+	} else if err := put(tx, warehouseID, productID, change); err != nil {
+		return err
+	}
+
+	return tx.Create(&models.StockMovement{
+		ProductID:   productID,
+		WarehouseID: optionalID(warehouseID),
+		Change:      change,
+		Reason:      reason,
+		ActorID:     optionalID(actorID),
+		Note:        note,
+	}).Error
+}
+
+// History - returns the movements of a product, newest first.
+func History(db *gorm.DB, productID uint) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+	err := db.Where("product_id = ?", productID).Order("id desc").Find(&movements).Error
+	return movements, err
+}
+
+// ledgerTotals - sums the movements of every product by warehouse, movements
+// without a warehouse count for the default one
+func ledgerTotals(db *gorm.DB, defaultID uint) (map[uint]map[uint]int, error) {
+	type total struct {
+		ProductID   uint
+		WarehouseID *uint
+		Total       int
+	}
+
+	var rows []total
+	if err := db.Model(&models.StockMovement{}).
+		Select("product_id, warehouse_id, SUM(change) AS total").
+		Group("product_id, warehouse_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	totals := map[uint]map[uint]int{}
+	for _, row := range rows {
+		warehouseID := defaultID
+		if row.WarehouseID != nil {
+			warehouseID = *row.WarehouseID
+		}
+
+		if totals[row.ProductID] == nil {
+			totals[row.ProductID] = map[uint]int{}
+		}
+		totals[row.ProductID][warehouseID] += row.Total
+	}
+	return totals, nil
+}
+
+// SeedOpeningBalances - records the current stock of products that have no
+// movements yet in the default warehouse, so stock from before the ledger
+// existed reconciles. Movements from before warehouses existed are put on the
+// default warehouse too, that is where their stock went.
+func SeedOpeningBalances(db *gorm.DB) error {
+	warehouse, err := DefaultWarehouse(db)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Model(&models.StockMovement{}).Where("warehouse_id IS NULL").
+		Update("warehouse_id", warehouse.ID).Error; err != nil {
+		return err
+	}
+
+	var products []models.Product
+	if err := db.Where("quantity <> 0 AND id NOT IN (?)",
+		db.Model(&models.StockMovement{}).Select("product_id")).
+		Find(&products).Error; err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		if err := db.Create(&models.StockMovement{
+			ProductID:   product.ID,
+			WarehouseID: optionalID(warehouse.ID),
+			Change:      product.Quantity,
+			Reason:      models.MovementCorrection,
+			Note:        "Opening balance",
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reconcile - recomputes the stock of every product in every warehouse from
+// the ledger and returns the products that drifted. With fix set the
+// warehouse stock is reset to the ledger values and the product quantities
+// to their sum.
+func Reconcile(db *gorm.DB, fix bool) ([]Drift, error) {
+	warehouse, err := DefaultWarehouse(db)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := ledgerTotals(db, warehouse.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var products []models.Product
+	if err := db.Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	drifts := []Drift{}
+	for _, product := range products {
+		ledger := totals[product.ID]
+		if ledger == nil {
+			ledger = map[uint]int{}
+		}
+
+		var stocks []models.WarehouseStock
+		db.Where("product_id = ?", product.ID).Find(&stocks)
+
+		held := map[uint]int{}
+		for _, stock := range stocks {
+			held[stock.WarehouseID] = stock.Quantity
+			if _, ok := ledger[stock.WarehouseID]; !ok {
+				ledger[stock.WarehouseID] = 0
+			}
+		}
+
+		drift := Drift{ProductID: product.ID, Name: product.Name, Quantity: product.Quantity}
+		for warehouseID, quantity := range ledger {
+			drift.Ledger += quantity
+			if held[warehouseID] != quantity {
+				drift.Warehouses = append(drift.Warehouses, WarehouseDrift{
+					WarehouseID: warehouseID,
+					Quantity:    held[warehouseID],
+					Ledger:      quantity,
+					Drift:       held[warehouseID] - quantity,
+				})
+			}
+		}
+		drift.Drift = drift.Quantity - drift.Ledger
+
+		if drift.Drift == 0 && len(drift.Warehouses) == 0 {
+			continue
+		}
+		sort.Slice(drift.Warehouses, func(i, j int) bool {
+			return drift.Warehouses[i].WarehouseID < drift.Warehouses[j].WarehouseID
+		})
+		drifts = append(drifts, drift)
+
+		if fix {
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				return resetStock(tx, product.ID, drift)
+			}); err != nil {
+				return drifts, err
+			}
+		}
+	}
+	return drifts, nil
+}
+
+// resetStock - sets the warehouse stock of a product to the ledger and the
+// product quantity to the ledger total
+func resetStock(tx *gorm.DB, productID uint, drift Drift) error {
+	for _, warehouse := range drift.Warehouses {
+		stock := models.WarehouseStock{}
+		tx.Where("warehouse_id = ? AND product_id = ?", warehouse.WarehouseID, productID).Limit(1).Find(&stock)
+
+		if stock.ID == 0 {
+			stock = models.WarehouseStock{WarehouseID: warehouse.WarehouseID, ProductID: productID, Quantity: warehouse.Ledger}
+			if err := tx.Create(&stock).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := tx.Model(&stock).Update("quantity", warehouse.Ledger).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&models.Product{}).Where("id = ?", productID).Update("quantity", drift.Ledger).Error
+}
@@ -0,0 +1,83 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/models"
+)
+
+func TestAdjust(t *testing.T) {
+	tests := []struct {
+		name   string
+		change int
+		want   []int
+		// Whether the change is refused for taking the stock below zero
+		short bool
+	}{
+		{"restock", 4, []int{9, 3}, false},
+		{"write off", -2, []int{3, 3}, false},
+		{"write off everything", -5, []int{0, 3}, false},
+		{"below zero", -6, []int{5, 3}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := stockDB(t, 5, 3)
+
+			err := Adjust(db, 1, test.change, models.MovementAdjustment, 7, "count")
+			if got := shortOf(err) != nil; got != test.short {
+				t.Fatalf("Adjust() = %v, want short %v", err, test.short)
+			}
+			if got := stockOf(db); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("stock = %v, want %v", got, test.want)
+			}
+
+			movements, _ := History(db, 1)
+			if test.short {
+				if len(movements) != 0 {
+					t.Errorf("recorded %d movements for a refused change", len(movements))
+				}
+				return
+			}
+			if len(movements) != 1 {
+				t.Fatalf("recorded %d movements, want 1", len(movements))
+			}
+
+			movement := movements[0]
+			if movement.Change != test.change || movement.Reason != models.MovementAdjustment ||
+				movement.ActorID == nil || *movement.ActorID != 7 || movement.Note != "count" {
+				t.Errorf("movement = %+v", movement)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	db := stockDB(t, 5, 3)
+
+	if err := SeedOpeningBalances(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Decrement(db, []models.OrderItem{item(1, 2)}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if drifts, _ := Reconcile(db, false); len(drifts) != 0 {
+		t.Fatalf("Reconcile() = %+v, want no drift", drifts)
+	}
+
+	// A change that bypasses the ledger
+	db.Model(&models.Product{}).Where("id = ?", 2).Update("quantity", 10)
+
+	drifts, err := Reconcile(db, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Drift{{ProductID: 2, Name: "Product 2", Quantity: 10, Ledger: 3, Drift: 7}}
+	if !reflect.DeepEqual(drifts, want) {
+		t.Errorf("Reconcile() = %+v, want %+v", drifts, want)
+	}
+	if got := stockOf(db); !reflect.DeepEqual(got, []int{3, 3}) {
+		t.Errorf("stock = %v, want it fixed to [3 3]", got)
+	}
+}
@@ -0,0 +1,117 @@
+package inventory
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/config"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/notify"
+	"gorm.io/gorm"
+)
+
+// restocked - products whose stock changed, checked for waiting subscribers
+var restocked = make(chan uint, 1024)
+
+// queueRestock - hands a product to the back-in-stock job. Never blocks, the
+// periodic sweep picks up what is dropped.
+func queueRestock(productID uint) {
+	select {
+	case restocked <- productID:
+	default:
+	}
+}
+
+// StartRestockNotices - tells subscribers when a product is back in stock.
+// Products are checked as soon as their stock changes and swept every
+// interval; each product sends at most Batch notices per interval.
+func StartRestockNotices(db *gorm.DB, notifier notify.Notifier, cfg config.Restock) {
+	interval := time.Duration(cfg.IntervalMin) * time.Minute
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		lastSent := map[uint]time.Time{}
+		dispatch := func(productID uint) {
+			if time.Since(lastSent[productID]) < interval {
+				return
+			}
+
+			sent, err := notifyRestock(db, notifier, productID, cfg.Batch)
+			if err != nil {
+				log.Println("Back in stock notification failed: ", err.Error())
+			}
+			if sent > 0 {
+				lastSent[productID] = time.Now()
+			}
+		}
+
+		sweep := func() {
+			var ids []uint
+			db.Model(&models.StockSubscription{}).Where("notified_at IS NULL").Distinct().Pluck("product_id", &ids)
+			for _, id := range ids {
+				dispatch(id)
+			}
+		}
+
+		sweep()
+		for {
+			select {
+			case productID := <-restocked:
+				dispatch(productID)
+			case <-ticker.C:
+				sweep()
+			}
+		}
+	}()
+}
+
+// notifyRestock - sends up to limit waiting subscribers of a product that is
+// published and in stock their notice, oldest subscription first. Each
+// subscription is told once, as soon as one channel delivered the notice; the
+// channels that failed are only logged.
+func notifyRestock(db *gorm.DB, notifier notify.Notifier, productID uint, limit int) (int, error) {
+	product := models.Product{}
+	db.Limit(1).Find(&product, productID)
+
+	if product.ID == 0 || product.Status != models.ProductPublished || Available(db, product) <= 0 {
+		return 0, nil
+	}
+
+	var subscriptions []models.StockSubscription
+	if err := db.Where("product_id = ? AND notified_at IS NULL", productID).Order("id").Limit(limit).Find(&subscriptions).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, subscription := range subscriptions {
+		if err := notifier.Notify(notify.Message{
+			Event:   "back_in_stock",
+			To:      subscription.Email,
+			Subject: fmt.Sprintf("Back in stock: %s", product.Name),
+			Body:    fmt.Sprintf("%s is back in stock.", product.Name),
+			Data: map[string]interface{}{
+				"product_id":       product.ID,
+				"name":             product.Name,
+				"slug":             product.Slug,
+				"unsubscribe_path": "/api/stock-subscriptions/" + subscription.Token,
+			},
+		}); err != nil {
+			if !notify.Delivered(err) {
+				return sent, err
+			}
+			log.Println("Back in stock notification to "+subscription.Email+" failed on some channels: ", err.Error())
+		}
+
+		if err := db.Model(&subscription).UpdateColumn("notified_at", time.Now()).Error; err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
@@ -0,0 +1,62 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/notify"
+)
+
+// failing - a notifier whose channel is down
+type failing struct{}
+
+func (failing) Notify(msg notify.Message) error {
+	return errors.New("channel down")
+}
+
+func TestNotifyRestock(t *testing.T) {
+	tests := []struct {
+		name     string
+		notifier notify.Notifier
+		stock    int
+		limit    int
+		// Subscriptions told and whether the error is returned
+		want int
+		err  bool
+	}{
+		{"every channel delivers", notify.Multi{&recorder{}, &recorder{}}, 5, 10, 3, false},
+		{"one channel fails", notify.Multi{failing{}, &recorder{}}, 5, 10, 3, false},
+		{"every channel fails", notify.Multi{failing{}, failing{}}, 5, 10, 0, true},
+		{"no channel", notify.Multi{}, 5, 10, 3, false},
+		{"limited", &recorder{}, 5, 2, 2, false},
+		{"out of stock", &recorder{}, 0, 10, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := stockDB(t, test.stock)
+			if err := db.AutoMigrate(&models.StockSubscription{}); err != nil {
+				t.Fatal(err)
+			}
+			for n := 1; n <= 3; n++ {
+				db.Create(&models.StockSubscription{ProductID: 1, Email: fmt.Sprintf("%d@example.com", n), Token: fmt.Sprint(n)})
+			}
+
+			sent, err := notifyRestock(db, test.notifier, 1, test.limit)
+			if (err != nil) != test.err {
+				t.Errorf("notifyRestock() error = %v, want error %v", err, test.err)
+			}
+			if sent != test.want {
+				t.Errorf("notifyRestock() = %d, want %d", sent, test.want)
+			}
+
+			var notified int64
+			db.Model(&models.StockSubscription{}).Where("notified_at IS NOT NULL").Count(&notified)
+			if int(notified) != test.want {
+				t.Errorf("%d subscriptions marked notified, want %d", notified, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// Shortage - a product that cannot cover the requested quantity.
+type Shortage struct {
+	ProductID uint   `json:"product_id"`
+	Name      string `json:"name"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// ShortageError - returned when one or more products are out of stock.
+type ShortageError struct {
+	Shortages []Shortage
+}
+
+func (e *ShortageError) Error() string {
+	names := make([]string, len(e.Shortages))
+	for i, s := range e.Shortages {
+		names[i] = fmt.Sprintf("%s (requested %d, available %d)", s.Name, s.Requested, s.Available)
+	}
+	return "Insufficient stock for " + strings.Join(names, ", ")
+}
+
+// quantities - sums the requested quantity per product, ordered by product id
+// so that concurrent transactions always touch rows in the same order.
+func quantities(items []models.OrderItem) ([]uint, map[uint]int) {
+	totals := map[uint]int{}
+	for _, item := range items {
+		totals[uint(item.ProductID)] += item.Quantity
+	}
+
+	ids := make([]uint, 0, len(totals))
+	for id := range totals {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids, totals
+}
+
+// Check - verifies the warehouses can cover the items without reserving
+// anything. Bundles are checked against their components.
+func Check(db *gorm.DB, items []models.OrderItem) error {
+	stock, err := expand(db, items, false)
+	if err != nil {
+		return err
+	}
+
+	ids, totals := quantities(stock)
+
+	_, shortages, err := allocate(db, ids, totals, orderRegion(db, items))
+	if err != nil {
+		return err
+	}
+
+	if len(shortages) > 0 {
+		return &ShortageError{Shortages: shortages}
+	}
+	return nil
+}
+
+// Decrement - takes the items out of stock, from the warehouses picked by the
+// configured strategy. Each warehouse is decremented with a conditional UPDATE
+// so two orders can never both take the last unit. Must be called inside a
+// transaction; on a ShortageError the caller rolls back. A sale movement is
+// recorded for every product and warehouse, for bundles on every component.
+func Decrement(tx *gorm.DB, items []models.OrderItem, actorID uint) error {
+	stock, err := expand(tx, items, false)
+	if err != nil {
+		return err
+	}
+
+	ids, totals := quantities(stock)
+
+	allocations, shortages, err := allocate(tx, ids, totals, orderRegion(tx, items))
+	if err != nil {
+		return err
+	}
+
+	if len(shortages) > 0 {
+		return &ShortageError{Shortages: shortages}
+	}
+
+	orderID := uint(0)
+	if len(stock) > 0 {
+		orderID = stock[0].OrderID
+	}
+
+	for _, allocation := range allocations {
+		taken, err := take(tx, allocation.WarehouseID, allocation.ProductID, allocation.Quantity)
+		if err != nil {
+			return err
+		}
+
+		// Another order took the stock since it was allocated
+		if !taken {
+			product := models.Product{}
+			tx.First(&product, allocation.ProductID)
+
+			shortages = append(shortages, Shortage{
+				ProductID: allocation.ProductID,
+				Name:      product.Name,
+				Requested: totals[allocation.ProductID],
+				Available: product.Quantity,
+			})
+			continue
+		}
+
+		if err := record(tx, allocation.ProductID, allocation.WarehouseID, -allocation.Quantity, models.MovementSale, actorID, orderID); err != nil {
+			return err
+		}
+
+		if orderID != 0 {
+			allocation.OrderID = orderID
+			if err := tx.Create(&allocation).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(shortages) > 0 {
+		return &ShortageError{Shortages: shortages}
+	}
+
+	return snapshot(tx, items)
+}
+
+// Restock - puts the items back into stock, into the warehouses their order
+// took it from, recording a return movement for each. Bundles give back the
+// components they took. Stock taken before warehouses existed goes back to
+// the default warehouse.
+func Restock(tx *gorm.DB, items []models.OrderItem, actorID uint) error {
+	stock, err := expand(tx, items, true)
+	if err != nil {
+		return err
+	}
+
+	type key struct {
+		orderID   uint
+		productID uint
+	}
+
+	totals := map[key]int{}
+	keys := []key{}
+	for _, item := range stock {
+		k := key{item.OrderID, uint(item.ProductID)}
+		if _, ok := totals[k]; !ok {
+			keys = append(keys, k)
+		}
+		totals[k] += item.Quantity
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].productID < keys[j].productID
+	})
+
+	for _, k := range keys {
+		remaining := totals[k]
+
+		var allocations []models.StockAllocation
+		if err := tx.Where("order_id = ? AND product_id = ?", k.orderID, k.productID).Order("id desc").Find(&allocations).Error; err != nil {
+			return err
+		}
+
+		for _, allocation := range allocations {
+			if remaining == 0 {
+				break
+			}
+
+			quantity := allocation.Quantity
+			if quantity > remaining {
+				quantity = remaining
+			}
+
+			if quantity == allocation.Quantity {
+				err = tx.Unscoped().Delete(&allocation).Error
+			} else {
+				err = tx.Model(&allocation).Update("quantity", allocation.Quantity-quantity).Error
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := give(tx, allocation.WarehouseID, k.productID, quantity, actorID, k.orderID); err != nil {
+				return err
+			}
+			remaining -= quantity
+		}
+
+		if remaining == 0 {
+			continue
+		}
+
+		warehouse, err := DefaultWarehouse(tx)
+		if err != nil {
+			return err
+		}
+		if err := give(tx, warehouse.ID, k.productID, remaining, actorID, k.orderID); err != nil {
+			return err
+		}
+	}
+
+	return clearSnapshots(tx, items)
+}
+
+// give - returns stock of an order to a warehouse, to the default one when it
+// was deleted since
+func give(tx *gorm.DB, warehouseID uint, productID uint, quantity int, actorID uint, orderID uint) error {
+	warehouse := models.Warehouse{}
+	tx.First(&warehouse, warehouseID)
+
+	if warehouse.ID == 0 {
+		var err error
+		if warehouse, err = DefaultWarehouse(tx); err != nil {
+			return err
+		}
+		warehouseID = warehouse.ID
+	}
+
+	if err := put(tx, warehouseID, productID, quantity); err != nil {
+		return err
+	}
+	return record(tx, productID, warehouseID, quantity, models.MovementReturn, actorID, orderID)
+}
+
+// record - writes the movement of an order's stock in a warehouse
+func record(tx *gorm.DB, productID uint, warehouseID uint, change int, reason string, actorID uint, orderID uint) error {
+	return tx.Create(&models.StockMovement{
+		ProductID:   productID,
+		WarehouseID: optionalID(warehouseID),
+		Change:      change,
+		Reason:      reason,
+		ActorID:     optionalID(actorID),
+		OrderID:     optionalID(orderID),
+	}).Error
+}
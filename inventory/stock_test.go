@@ -0,0 +1,112 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// stockDB - products 1 and 2 with the stock, all of it in the main warehouse
+func stockDB(t *testing.T, stock ...int) *gorm.DB {
+	t.Helper()
+	testdb.Env(t)
+
+	db := testdb.Open(t, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.StockMovement{},
+		&models.BundleItem{}, &models.OrderItemComponent{},
+		&models.Warehouse{}, &models.WarehouseStock{}, &models.StockAllocation{}, &models.StockTransfer{})
+	for n, quantity := range stock {
+		if err := db.Create(&models.Product{Name: fmt.Sprintf("Product %d", n+1), Quantity: quantity}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SeedWarehouses(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// stockOf - what is in stock of every product
+func stockOf(db *gorm.DB) []int {
+	var stock []int
+	db.Model(&models.Product{}).Order("id").Pluck("quantity", &stock)
+	return stock
+}
+
+// item - an order item for a quantity of the product
+func item(productID int, quantity int) models.OrderItem {
+	return models.OrderItem{ProductID: productID, Quantity: quantity}
+}
+
+func TestDecrement(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []models.OrderItem
+		want  []int
+		// Products that are short, nothing is taken when one is
+		short []uint
+	}{
+		{"takes the items", []models.OrderItem{item(1, 2), item(2, 3)}, []int{3, 0}, nil},
+		{"adds up items of a product", []models.OrderItem{item(1, 2), item(1, 3)}, []int{0, 3}, nil},
+		{"last units", []models.OrderItem{item(1, 5)}, []int{0, 3}, nil},
+		{"short", []models.OrderItem{item(1, 6)}, []int{5, 3}, []uint{1}},
+		{"short together", []models.OrderItem{item(1, 3), item(1, 3)}, []int{5, 3}, []uint{1}},
+		{"every short product", []models.OrderItem{item(1, 6), item(2, 4)}, []int{5, 3}, []uint{1, 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := stockDB(t, 5, 3)
+
+			if err := Check(db, test.items); (shortOf(err) == nil) != (test.short == nil) {
+				t.Errorf("Check() = %v, want short %v", err, test.short)
+			}
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return Decrement(tx, test.items, 1)
+			})
+
+			if got := shortOf(err); !reflect.DeepEqual(got, test.short) {
+				t.Errorf("Decrement() = %v, want short %v", err, test.short)
+			}
+			if got := stockOf(db); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("stock = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRestock(t *testing.T) {
+	db := stockDB(t, 5, 3)
+
+	items := []models.OrderItem{item(1, 2), item(2, 3), item(1, 1)}
+	if err := Decrement(db, items, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restock(db, items, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := stockOf(db); !reflect.DeepEqual(got, []int{5, 3}) {
+		t.Errorf("stock = %v, want it back at [5 3]", got)
+	}
+}
+
+// shortOf - the products a shortage error lists
+func shortOf(err error) []uint {
+	var shortage *ShortageError
+	if !errors.As(err, &shortage) {
+		return nil
+	}
+
+	ids := []uint{}
+	for _, s := range shortage.Shortages {
+		ids = append(ids, s.ProductID)
+	}
+	return ids
+}
@@ -0,0 +1,343 @@
+package inventory
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/rama-kairi/fiber-api/config"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNoWarehouse - stock can not be kept without a warehouse
+	ErrNoWarehouse = errors.New("No warehouse to keep the stock in")
+	// ErrSameWarehouse - a transfer needs two different warehouses
+	ErrSameWarehouse = errors.New("Stock can only be transferred between two different warehouses")
+)
+
+// Level - the stock of a product in one warehouse
+type Level struct {
+	WarehouseID uint   `json:"warehouse_id"`
+	Code        string `json:"code"`
+	Region      string `json:"region"`
+	Quantity    int    `json:"quantity"`
+}
+
+// DefaultWarehouse - the warehouse with the lowest priority, where stock goes
+// when no warehouse is given
+func DefaultWarehouse(db *gorm.DB) (models.Warehouse, error) {
+	warehouse := models.Warehouse{}
+	db.Order("priority, id").Limit(1).Find(&warehouse)
+
+	if warehouse.ID == 0 {
+		return warehouse, ErrNoWarehouse
+	}
+	return warehouse, nil
+}
+
+// ranked - the warehouses in the order they are picked for an order in the
+// region, following the configured strategy
+func ranked(db *gorm.DB, region string) ([]models.Warehouse, error) {
+	var warehouses []models.Warehouse
+	if err := db.Order("priority, id").Find(&warehouses).Error; err != nil {
+		return nil, err
+	}
+
+	if config.GetConfig().Warehouse.Strategy == "nearest" && region != "" {
+		sort.SliceStable(warehouses, func(i, j int) bool {
+			return strings.EqualFold(warehouses[i].Region, region) && !strings.EqualFold(warehouses[j].Region, region)
+		})
+	}
+	return warehouses, nil
+}
+
+// levels - the stock of the products per warehouse
+func levels(db *gorm.DB, productIDs []uint) (map[uint]map[uint]int, error) {
+	var stocks []models.WarehouseStock
+	if err := db.Where("product_id IN ?", productIDs).Find(&stocks).Error; err != nil {
+		return nil, err
+	}
+
+	byWarehouse := map[uint]map[uint]int{}
+	for _, stock := range stocks {
+		if byWarehouse[stock.WarehouseID] == nil {
+			byWarehouse[stock.WarehouseID] = map[uint]int{}
+		}
+		byWarehouse[stock.WarehouseID][stock.ProductID] = stock.Quantity
+	}
+	return byWarehouse, nil
+}
+
+// Levels - the stock of a product in every warehouse, in priority order
+func Levels(db *gorm.DB, productID uint) []Level {
+	warehouses, _ := ranked(db, "")
+	stock, _ := levels(db, []uint{productID})
+
+	result := make([]Level, len(warehouses))
+	for i, warehouse := range warehouses {
+		result[i] = Level{
+			WarehouseID: warehouse.ID,
+			Code:        warehouse.Code,
+			Region:      warehouse.Region,
+			Quantity:    stock[warehouse.ID][productID],
+		}
+	}
+	return result
+}
+
+// allocate - picks the warehouses that ship the quantities. Without split the
+// whole order comes from the first warehouse that has all of it; when none
+// does, the shortages are those of the warehouse that covers the most.
+func allocate(db *gorm.DB, ids []uint, totals map[uint]int, region string) ([]models.StockAllocation, []Shortage, error) {
+	warehouses, err := ranked(db, region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stock, err := levels(db, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shortage := func(id uint, available int) Shortage {
+		product := models.Product{}
+		db.First(&product, id)
+
+		return Shortage{ProductID: id, Name: product.Name, Requested: totals[id], Available: available}
+	}
+
+	allocations := []models.StockAllocation{}
+	shortages := []Shortage{}
+
+	if config.GetConfig().Warehouse.AllowSplit {
+		for _, id := range ids {
+			remaining, available := totals[id], 0
+			for _, warehouse := range warehouses {
+				available += stock[warehouse.ID][id]
+
+				quantity := stock[warehouse.ID][id]
+				if quantity > remaining {
+					quantity = remaining
+				}
+				if quantity <= 0 {
+					continue
+				}
+
+				allocations = append(allocations, models.StockAllocation{ProductID: id, WarehouseID: warehouse.ID, Quantity: quantity})
+				remaining -= quantity
+			}
+
+			if remaining > 0 {
+				shortages = append(shortages, shortage(id, available))
+			}
+		}
+		return allocations, shortages, nil
+	}
+
+	var best *models.Warehouse
+	bestCovered := -1
+	for i, warehouse := range warehouses {
+		covered := 0
+		for _, id := range ids {
+			if stock[warehouse.ID][id] >= totals[id] {
+				covered++
+			}
+		}
+		if covered > bestCovered {
+			best, bestCovered = &warehouses[i], covered
+		}
+		if covered == len(ids) {
+			break
+		}
+	}
+
+	if best == nil {
+		for _, id := range ids {
+			shortages = append(shortages, shortage(id, 0))
+		}
+		return nil, shortages, nil
+	}
+
+	for _, id := range ids {
+		if stock[best.ID][id] < totals[id] {
+			shortages = append(shortages, shortage(id, stock[best.ID][id]))
+			continue
+		}
+		allocations = append(allocations, models.StockAllocation{ProductID: id, WarehouseID: best.ID, Quantity: totals[id]})
+	}
+	return allocations, shortages, nil
+}
+
+// take - removes stock from a warehouse and the product total, failing when
+// the warehouse does not have it
+func take(tx *gorm.DB, warehouseID uint, productID uint, quantity int) (bool, error) {
+	result := tx.Model(&models.WarehouseStock{}).
+		Where("warehouse_id = ? AND product_id = ? AND quantity >= ?", warehouseID, productID, quantity).
+		Update("quantity", gorm.Expr("quantity - ?", quantity))
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	return true, tx.Model(&models.Product{}).Where("id = ?", productID).
+		Update("quantity", gorm.Expr("quantity - ?", quantity)).Error
+}
+
+// put - adds stock to a warehouse and the product total
+func put(tx *gorm.DB, warehouseID uint, productID uint, quantity int) error {
+	stock := models.WarehouseStock{}
+	tx.Where("warehouse_id = ? AND product_id = ?", warehouseID, productID).Limit(1).Find(&stock)
+
+	if stock.ID == 0 {
+		stock = models.WarehouseStock{WarehouseID: warehouseID, ProductID: productID}
+		if err := tx.Create(&stock).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&stock).Update("quantity", gorm.Expr("quantity + ?", quantity)).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Product{}).Where("id = ?", productID).
+		Update("quantity", gorm.Expr("quantity + ?", quantity)).Error
+}
+
+// warehouseQuantity - the stock of a product in a warehouse
+func warehouseQuantity(tx *gorm.DB, warehouseID uint, productID uint) int {
+	stock := models.WarehouseStock{}
+	tx.Where("warehouse_id = ? AND product_id = ?", warehouseID, productID).Limit(1).Find(&stock)
+	return stock.Quantity
+}
+
+// Transfer - moves stock of a product from one warehouse to another, recording
+// a transfer movement on both sides
+func Transfer(tx *gorm.DB, transfer *models.StockTransfer) error {
+	if transfer.FromWarehouseID == transfer.ToWarehouseID {
+		return ErrSameWarehouse
+	}
+
+	if isBundle(tx, transfer.ProductID) {
+		return ErrBundleStock
+	}
+
+	taken, err := take(tx, transfer.FromWarehouseID, transfer.ProductID, transfer.Quantity)
+	if err != nil {
+		return err
+	}
+
+	if !taken {
+		product := models.Product{}
+		tx.First(&product, transfer.ProductID)
+
+		return &ShortageError{Shortages: []Shortage{{
+			ProductID: transfer.ProductID,
+			Name:      product.Name,
+			Requested: transfer.Quantity,
+			Available: warehouseQuantity(tx, transfer.FromWarehouseID, transfer.ProductID),
+		}}}
+	}
+
+	if err := put(tx, transfer.ToWarehouseID, transfer.ProductID, transfer.Quantity); err != nil {
+		return err
+	}
+
+	for _, side := range []struct {
+		warehouseID uint
+		change      int
+	}{
+		{transfer.FromWarehouseID, -transfer.Quantity},
+		{transfer.ToWarehouseID, transfer.Quantity},
+	} {
+		if err := tx.Create(&models.StockMovement{
+			ProductID:   transfer.ProductID,
+			WarehouseID: optionalID(side.warehouseID),
+			Change:      side.change,
+			Reason:      models.MovementTransfer,
+			ActorID:     transfer.ActorID,
+			Note:        transfer.Note,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(transfer).Error
+}
+
+// orderRegion - the shipping region of the order the items belong to
+func orderRegion(tx *gorm.DB, items []models.OrderItem) string {
+	for _, item := range items {
+		if item.OrderID != 0 {
+			order := models.Order{}
+			tx.Unscoped().First(&order, item.OrderID)
+			return order.Region
+		}
+	}
+	return ""
+}
+
+// SeedWarehouses - creates the main warehouse when there is none and puts the
+// stock of products without warehouse stock into the default warehouse, so
+// stock from before warehouses existed can be allocated.
+func SeedWarehouses(db *gorm.DB) error {
+	var count int64
+	db.Model(&models.Warehouse{}).Count(&count)
+
+	if count == 0 {
+		if err := db.Create(&models.Warehouse{Code: "main", Name: "Main warehouse"}).Error; err != nil {
+			return err
+		}
+	}
+
+	warehouse, err := DefaultWarehouse(db)
+	if err != nil {
+		return err
+	}
+
+	var products []models.Product
+	if err := db.Unscoped().Where("quantity <> 0 AND id NOT IN (?)",
+		db.Model(&models.WarehouseStock{}).Select("product_id")).
+		Find(&products).Error; err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		if err := db.Create(&models.WarehouseStock{
+			WarehouseID: warehouse.ID,
+			ProductID:   product.ID,
+			Quantity:    product.Quantity,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Allocation - where an order's stock of a product came from
+type Allocation struct {
+	ProductID   uint   `json:"product_id"`
+	WarehouseID uint   `json:"warehouse_id"`
+	Code        string `json:"code"`
+	Quantity    int    `json:"quantity"`
+}
+
+// Allocations - the warehouses an order ships from
+func Allocations(db *gorm.DB, orderID uint) []Allocation {
+	var allocations []models.StockAllocation
+	db.Where("order_id = ?", orderID).Order("product_id, warehouse_id").Find(&allocations)
+
+	result := make([]Allocation, len(allocations))
+	for i, allocation := range allocations {
+		warehouse := models.Warehouse{}
+		db.Unscoped().First(&warehouse, allocation.WarehouseID)
+
+		result[i] = Allocation{
+			ProductID:   allocation.ProductID,
+			WarehouseID: allocation.WarehouseID,
+			Code:        warehouse.Code,
+			Quantity:    allocation.Quantity,
+		}
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+package inventory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// warehouseDB - products 1 and 2 with 5 and 3 in the main warehouse and an
+// empty second warehouse, east, that ships after it
+func warehouseDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := stockDB(t, 5, 3)
+	if err := db.Create(&models.Warehouse{Code: "east", Priority: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// levelsOf - the stock of a product in every warehouse by code
+func levelsOf(db *gorm.DB, productID uint) map[string]int {
+	result := map[string]int{}
+	for _, level := range Levels(db, productID) {
+		result[level.Code] = level.Quantity
+	}
+	return result
+}
+
+func TestTransfer(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer models.StockTransfer
+		want     map[string]int
+		err      bool
+	}{
+		{"moves the stock", models.StockTransfer{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 2},
+			map[string]int{"main": 3, "east": 2}, false},
+		{"all of it", models.StockTransfer{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 5},
+			map[string]int{"main": 0, "east": 5}, false},
+		{"more than the warehouse has", models.StockTransfer{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 6},
+			map[string]int{"main": 5, "east": 0}, true},
+		{"same warehouse", models.StockTransfer{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 1, Quantity: 1},
+			map[string]int{"main": 5, "east": 0}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := warehouseDB(t)
+
+			transfer := test.transfer
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return Transfer(tx, &transfer)
+			})
+
+			if (err != nil) != test.err {
+				t.Fatalf("Transfer() = %v, want error %v", err, test.err)
+			}
+			if got := levelsOf(db, 1); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("levels = %v, want %v", got, test.want)
+			}
+			if got := stockOf(db); got[0] != 5 {
+				t.Errorf("product stock = %d, a transfer keeps it at 5", got[0])
+			}
+		})
+	}
+
+	t.Run("same warehouse error", func(t *testing.T) {
+		db := warehouseDB(t)
+
+		err := Transfer(db, &models.StockTransfer{ProductID: 1, FromWarehouseID: 2, ToWarehouseID: 2, Quantity: 1})
+		if !errors.Is(err, ErrSameWarehouse) {
+			t.Errorf("Transfer() = %v, want %v", err, ErrSameWarehouse)
+		}
+	})
+}
+
+func TestAllocation(t *testing.T) {
+	db := warehouseDB(t)
+
+	if err := Transfer(db, &models.StockTransfer{ProductID: 1, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	order := models.Order{}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	items := []models.OrderItem{{OrderID: order.ID, ProductID: 1, Quantity: 4}}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return Decrement(tx, items, 1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Main ships first and east covers the rest
+	want := []Allocation{
+		{ProductID: 1, WarehouseID: 1, Code: "main", Quantity: 2},
+		{ProductID: 1, WarehouseID: 2, Code: "east", Quantity: 2},
+	}
+	if got := Allocations(db, order.ID); !reflect.DeepEqual(got, want) {
+		t.Errorf("Allocations() = %v, want %v", got, want)
+	}
+
+	if err := Restock(db, items, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := levelsOf(db, 1); !reflect.DeepEqual(got, map[string]int{"main": 2, "east": 3}) {
+		t.Errorf("levels after the return = %v, want the stock back where it came from", got)
+	}
+	if got := Allocations(db, order.ID); len(got) != 0 {
+		t.Errorf("Allocations() after the return = %v, want none", got)
+	}
+}
@@ -2,16 +2,31 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/catalog"
 	"github.com/rama-kairi/fiber-api/config"
 	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/notify"
+	"github.com/rama-kairi/fiber-api/pricing"
+	"github.com/rama-kairi/fiber-api/recommend"
 	"github.com/rama-kairi/fiber-api/routes"
+	"github.com/rama-kairi/fiber-api/wishlist"
 )
 
 func main() {
 	database.ConnectDB()
 
+	notifier := notify.FromConfig(config.GetConfig().Notify)
+	inventory.StartAlerts(database.Database.Db, notifier)
+	inventory.StartRestockNotices(database.Database.Db, notifier, config.GetConfig().Restock)
+	wishlist.StartPriceAlerts(database.Database.Db, notifier, time.Minute)
+	pricing.StartScheduler(database.Database.Db, time.Minute)
+	recommend.StartScheduler(database.Database.Db, config.GetConfig().Recommend)
+	catalog.StartPublisher(database.Database.Db, time.Minute)
+
 	app := fiber.New(
 		fiber.Config{
 			Prefork:       false,
@@ -4,6 +4,8 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
 	"github.com/rama-kairi/fiber-api/routes/utils"
 )
 
@@ -50,3 +52,32 @@ func IsAuthenticatedRefresh(c *fiber.Ctx) error {
 
 	return c.Next()
 }
+
+// IsAdmin - only lets admins through, must run after IsAuthenticated
+func IsAdmin(c *fiber.Ctx) error {
+	var user models.User
+	database.Database.Db.First(&user, utils.CurrentUserID(c))
+
+	if user.ID == 0 || !user.IsAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Admin access required",
+		})
+	}
+
+	return c.Next()
+}
+
+// OptionalAuthentication - identifies the user when a valid access token is
+// sent, but lets anonymous requests through
+func OptionalAuthentication(c *fiber.Ctx) error {
+	parts := strings.Split(c.Get("Authorization"), " ")
+	if len(parts) != 2 {
+		return c.Next()
+	}
+
+	if claims, err := utils.DecodeToken(parts[1], "access"); err == nil {
+		c.Locals("user", claims)
+	}
+
+	return c.Next()
+}
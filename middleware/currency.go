@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/pricing"
+)
+
+// Currency - picks the currency of the request from the currency query
+// parameter or the X-Currency header. The base currency is used when none is
+// asked for or the one asked for has no exchange rate yet.
+func Currency(c *fiber.Ctx) error {
+	currency := strings.ToUpper(c.Query("currency", c.Get("X-Currency")))
+	if currency == "" {
+		currency = pricing.Base()
+	}
+
+	if !pricing.IsSupported(currency) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported currency " + currency,
+		})
+	}
+
+	if _, err := pricing.Rate(database.Database.Db, currency); err != nil {
+		currency = pricing.Base()
+	}
+
+	c.Locals("currency", currency)
+
+	return c.Next()
+}
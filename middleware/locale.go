@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/i18n"
+)
+
+// Locale - picks the locales of the request from the locale query parameter
+// or the Accept-Language header, falling back to the default locale
+func Locale(c *fiber.Ctx) error {
+	chain, err := i18n.Negotiate(c.Query("locale"), c.Get(fiber.HeaderAcceptLanguage))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	c.Locals("locales", chain)
+	c.Set(fiber.HeaderContentLanguage, chain[0])
+	c.Vary(fiber.HeaderAcceptLanguage)
+
+	return c.Next()
+}
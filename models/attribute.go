@@ -0,0 +1,41 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Attribute value types
+const (
+	AttributeString = "string"
+	AttributeNumber = "number"
+	AttributeBool   = "bool"
+	AttributeEnum   = "enum"
+)
+
+// AttributeDefinition - an admin-defined product field. Definitions with a
+// product type only apply to products of that type, the others to every
+// product.
+type AttributeDefinition struct {
+	gorm.Model
+	Code        string `json:"code" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Name        string `json:"name"`
+	Type        string `json:"type" gorm:"type:varchar(16);not null"`
+	Required    bool   `json:"required" gorm:"not null;default:false"`
+	Unit        string `json:"unit" gorm:"type:varchar(16)"`
+	ProductType string `json:"product_type" gorm:"type:varchar(64);index"`
+
+	// Allowed values of an enum, one per line
+	Options string `json:"-"`
+}
+
+// ProductAttribute - the value of an attribute on a product. Value holds the
+// text form of every type, numbers are also kept in NumberValue so they can be
+// compared as numbers.
+type ProductAttribute struct {
+	gorm.Model
+	ProductID             uint                `json:"product_id" gorm:"uniqueIndex:idx_product_attribute;not null"`
+	AttributeDefinitionID uint                `json:"attribute_definition_id" gorm:"uniqueIndex:idx_product_attribute;index;not null"`
+	AttributeDefinition   AttributeDefinition `json:"-"`
+	Value                 string              `json:"value"`
+	NumberValue           *float64            `json:"-" gorm:"index"`
+}
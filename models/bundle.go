@@ -0,0 +1,24 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// BundleItem - a component of a bundle product and how many of it one bundle
+// holds
+type BundleItem struct {
+	gorm.Model
+	BundleID    uint    `json:"bundle_id" gorm:"uniqueIndex:idx_bundle_component;not null"`
+	ComponentID uint    `json:"component_id" gorm:"uniqueIndex:idx_bundle_component;index;not null"`
+	Component   Product `json:"-" gorm:"foreignKey:ComponentID"`
+	Quantity    int     `json:"quantity" gorm:"not null"`
+}
+
+// OrderItemComponent - what one unit of a bundle order item took out of
+// stock, kept so the same components are given back if the bundle changes
+type OrderItemComponent struct {
+	gorm.Model
+	OrderItemID uint `json:"order_item_id" gorm:"index;not null"`
+	ProductID   uint `json:"product_id" gorm:"index;not null"`
+	Quantity    int  `json:"quantity" gorm:"not null"`
+}
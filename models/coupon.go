@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// Coupon discount kinds
+const (
+	CouponPercent = "percent"
+	CouponFixed   = "fixed"
+)
+
+// Coupon - a discount code. Percent coupons take Percent off the items they
+// apply to, fixed ones take Amount off them together. Amounts are in the base
+// currency. Limits of 0 are unlimited, and a coupon with products or
+// categories only applies to the items matching one of them.
+type Coupon struct {
+	gorm.Model
+	Code             string       `json:"code" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Kind             string       `json:"kind" gorm:"type:varchar(16);not null"`
+	Percent          float64      `json:"percent"`
+	Amount           money.Amount `json:"amount" gorm:"column:amount_minor;not null;default:0"`
+	MinOrder         money.Amount `json:"min_order" gorm:"column:min_order_minor;not null;default:0"`
+	StartsAt         *time.Time   `json:"starts_at"`
+	EndsAt           *time.Time   `json:"ends_at"`
+	UsageLimit       int          `json:"usage_limit" gorm:"not null;default:0"`
+	PerCustomerLimit int          `json:"per_customer_limit" gorm:"not null;default:0"`
+	UsedCount        int          `json:"used_count" gorm:"not null;default:0"`
+
+	Products   []CouponProduct  `json:"-"`
+	Categories []CouponCategory `json:"-"`
+}
+
+// CouponProduct - a product a coupon is restricted to
+type CouponProduct struct {
+	gorm.Model
+	CouponID  uint `gorm:"uniqueIndex:idx_coupon_product;not null"`
+	ProductID uint `gorm:"uniqueIndex:idx_coupon_product;not null"`
+}
+
+// CouponCategory - a category (product type) a coupon is restricted to
+type CouponCategory struct {
+	gorm.Model
+	CouponID uint   `gorm:"uniqueIndex:idx_coupon_category;not null"`
+	Category string `gorm:"type:varchar(64);uniqueIndex:idx_coupon_category;not null"`
+}
+
+// CouponRedemption - a use of a coupon by an order, one per order
+type CouponRedemption struct {
+	gorm.Model
+	CouponID uint         `json:"coupon_id" gorm:"index;not null"`
+	OrderID  uint         `json:"order_id" gorm:"uniqueIndex;not null"`
+	UserID   uint         `json:"user_id" gorm:"index;not null"`
+	Discount money.Amount `json:"discount" gorm:"column:discount_minor;not null;default:0"`
+}
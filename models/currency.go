@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// ExchangeRate - how many units of Currency one unit of the base currency buys
+type ExchangeRate struct {
+	gorm.Model
+	Currency string  `json:"currency" gorm:"type:varchar(3);unique;not null"`
+	Rate     float64 `json:"rate"`
+}
+
+// ProductCurrencyPrice - an explicit price of a product in one currency,
+// used instead of converting the base price
+type ProductCurrencyPrice struct {
+	gorm.Model
+	ProductID uint         `json:"product_id" gorm:"uniqueIndex:idx_product_currency;not null"`
+	Currency  string       `json:"currency" gorm:"type:varchar(3);uniqueIndex:idx_product_currency;not null"`
+	Price     money.Amount `json:"price" gorm:"column:price_minor;not null;default:0"`
+}
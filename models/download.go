@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductFile - a file delivered to buyers of a digital product, stored under
+// the download directory
+type ProductFile struct {
+	gorm.Model
+	ProductID   uint   `json:"product_id" gorm:"index;not null"`
+	FileName    string `json:"file_name" gorm:"not null"`
+	Path        string `json:"-" gorm:"not null"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// DownloadGrant - the right of a paid order to download a file, up to
+// DownloadLimit times until ExpiresAt
+type DownloadGrant struct {
+	gorm.Model
+	OrderID       uint        `json:"order_id" gorm:"uniqueIndex:idx_grant_order_file;not null"`
+	ProductFileID uint        `json:"product_file_id" gorm:"uniqueIndex:idx_grant_order_file;not null"`
+	ProductFile   ProductFile `json:"-"`
+	UserID        uint        `json:"user_id" gorm:"index;not null"`
+	DownloadLimit int         `json:"download_limit" gorm:"not null"`
+	Downloads     int         `json:"downloads" gorm:"not null;default:0"`
+	ExpiresAt     time.Time   `json:"expires_at"`
+}
+
+// Download - one served download of a grant
+type Download struct {
+	gorm.Model
+	DownloadGrantID uint   `json:"download_grant_id" gorm:"index;not null"`
+	IP              string `json:"ip"`
+	UserAgent       string `json:"user_agent"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// Gift card and store credit transaction kinds
+const (
+	CreditIssue   = "issue"
+	CreditRedeem  = "redeem"
+	CreditRelease = "release"
+	CreditRefund  = "refund"
+	CreditAdjust  = "adjust"
+	CreditVoid    = "void"
+)
+
+// Order payment methods besides the one the customer pays the rest with
+const (
+	PaymentGiftCard    = "gift_card"
+	PaymentStoreCredit = "store_credit"
+)
+
+// GiftCard - a prepaid balance anyone with the code can spend on orders in
+// its currency. Cards sold as products point at the order item that bought
+// them, UserID is the buyer or whoever an admin issued the card to.
+type GiftCard struct {
+	gorm.Model
+	Code        string       `json:"code" gorm:"type:varchar(32);uniqueIndex;not null"`
+	Currency    string       `json:"currency" gorm:"type:varchar(3);not null"`
+	Initial     money.Amount `json:"initial" gorm:"column:initial_minor;not null;default:0"`
+	Balance     money.Amount `json:"balance" gorm:"column:balance_minor;not null;default:0"`
+	ExpiresAt   *time.Time   `json:"expires_at"`
+	UserID      *uint        `json:"user_id" gorm:"index"`
+	OrderItemID *uint        `json:"order_item_id" gorm:"index"`
+	Note        string       `json:"note"`
+}
+
+// GiftCardTransaction - a change to the balance of a gift card, Balance is
+// what was left after it
+type GiftCardTransaction struct {
+	gorm.Model
+	GiftCardID uint         `json:"gift_card_id" gorm:"index;not null"`
+	OrderID    *uint        `json:"order_id" gorm:"index"`
+	Kind       string       `json:"kind" gorm:"type:varchar(16);not null"`
+	Amount     money.Amount `json:"amount" gorm:"column:amount_minor;not null;default:0"`
+	Balance    money.Amount `json:"balance" gorm:"column:balance_minor;not null;default:0"`
+	Note       string       `json:"note"`
+}
+
+// StoreCredit - a change to the store credit of a user in one currency, the
+// credit is what the changes add up to. Balance is what was left after it.
+type StoreCredit struct {
+	gorm.Model
+	UserID   uint         `json:"user_id" gorm:"index:idx_store_credit_account;not null"`
+	Currency string       `json:"currency" gorm:"type:varchar(3);index:idx_store_credit_account;not null"`
+	OrderID  *uint        `json:"order_id" gorm:"index"`
+	Kind     string       `json:"kind" gorm:"type:varchar(16);not null"`
+	Amount   money.Amount `json:"amount" gorm:"column:amount_minor;not null;default:0"`
+	Balance  money.Amount `json:"balance" gorm:"column:balance_minor;not null;default:0"`
+	Note     string       `json:"note"`
+}
+
+// OrderPayment - part of an order paid with a gift card or store credit, the
+// rest is paid the usual way
+type OrderPayment struct {
+	gorm.Model
+	OrderID    uint         `json:"order_id" gorm:"index;not null"`
+	Method     string       `json:"method" gorm:"type:varchar(16);not null"`
+	GiftCardID *uint        `json:"gift_card_id"`
+	Amount     money.Amount `json:"amount" gorm:"column:amount_minor;not null;default:0"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Import job states
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+type ImportJob struct {
+	gorm.Model
+	Status   string           `json:"status" gorm:"type:varchar(16);not null"`
+	DryRun   bool             `json:"dry_run"`
+	FileName string           `json:"file_name"`
+	UserID   uint             `json:"user_id"`
+	Rows     int              `json:"rows"`
+	Created  int              `json:"created"`
+	Updated  int              `json:"updated"`
+	Failed   int              `json:"failed"`
+	Message  string           `json:"message"`
+	Errors   []ImportRowError `json:"errors" gorm:"foreignkey:ImportJobID"`
+}
+
+type ImportRowError struct {
+	gorm.Model
+	ImportJobID uint   `json:"import_job_id" gorm:"index"`
+	Row         int    `json:"row"`
+	Error       string `json:"error"`
+}
@@ -1,23 +1,85 @@
 package models
 
 import (
+	"time"
+
+	"github.com/rama-kairi/fiber-api/money"
 	"gorm.io/gorm"
 )
 
+// Order statuses, the orderstatus package says which can follow which
+const (
+	OrderPending         = "pending"
+	OrderAwaitingPayment = "awaiting_payment"
+	OrderPaid            = "paid"
+	OrderFulfilling      = "fulfilling"
+	OrderShipped         = "shipped"
+	OrderDelivered       = "delivered"
+	OrderCancelled       = "cancelled"
+	OrderRefunded        = "refunded"
+)
+
 type OrderItem struct {
 	gorm.Model
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
-	OrderID   uint    `json:"order_id"`
-	ProductID int     `json:"product_id"`
-	Product   Product
+	Quantity     int          `json:"quantity"`
+	Price        money.Amount `json:"price" gorm:"column:price_minor;not null;default:0"`
+	Currency     string       `json:"currency" gorm:"type:varchar(3)"`
+	ExchangeRate float64      `json:"exchange_rate"`
+	OrderID      uint         `json:"order_id"`
+	ProductID    int          `json:"product_id"`
+	Product      Product
+
+	// Taken off Price, tax is worked out on what is left. Part of Price for
+	// inclusive rates, added to the order for the others.
+	Discount money.Amount `json:"discount" gorm:"column:discount_minor;not null;default:0"`
+	Tax      money.Amount `json:"tax" gorm:"column:tax_minor;not null;default:0"`
+	TaxRate  float64      `json:"tax_rate"`
 }
 
 type Order struct {
 	gorm.Model
-	Quantity   int         `json:"quantity"`
-	Price      float64     `json:"price"`
+	Quantity     int          `json:"quantity"`
+	Price        money.Amount `json:"price" gorm:"column:price_minor;not null;default:0"`
+	Currency     string       `json:"currency" gorm:"type:varchar(3)"`
+	ExchangeRate float64      `json:"exchange_rate"`
+	Status       string       `json:"status" gorm:"type:varchar(16);index;not null;default:pending"`
+	PaidAt       *time.Time   `json:"paid_at"`
+	DeliveredAt  *time.Time   `json:"delivered_at"`
+	// Shipping region, the nearest warehouse strategy prefers warehouses in it
+	Region     string      `json:"region" gorm:"type:varchar(64)"`
 	UserID     int         `json:"user_id"`
 	User       User        `gorm:"foreignkey:UserID"`
 	OrderItems []OrderItem `gorm:"foreignkey:OrderID"`
+
+	// Taxed jurisdiction together with Region. Price is what the customer pays,
+	// the items less the discount plus the tax not included in their prices
+	// and the shipping. Discount covers promotions and the coupon,
+	// CouponDiscount the coupon alone.
+	Country        string       `json:"country" gorm:"type:varchar(2)"`
+	Discount       money.Amount `json:"discount" gorm:"column:discount_minor;not null;default:0"`
+	Tax            money.Amount `json:"tax" gorm:"column:tax_minor;not null;default:0"`
+	CouponID       *uint        `json:"coupon_id"`
+	CouponDiscount money.Amount `json:"coupon_discount" gorm:"column:coupon_discount_minor;not null;default:0"`
+
+	// Shipping is charged on top of the items, the method name is copied so
+	// later changes to it leave the order alone
+	ShippingMethodID *uint        `json:"shipping_method_id"`
+	ShippingMethod   string       `json:"shipping_method"`
+	Shipping         money.Amount `json:"shipping" gorm:"column:shipping_minor;not null;default:0"`
+
+	// Paid with gift cards and store credit, the rest of Price is paid the
+	// usual way
+	Redeemed money.Amount `json:"redeemed" gorm:"column:redeemed_minor;not null;default:0"`
+}
+
+// OrderStatusChange - a move of an order from one status to the next. UserID
+// is who moved it, nil when it moved by itself, such as when gift cards paid
+// all of it. From is empty for the status the order was placed with.
+type OrderStatusChange struct {
+	gorm.Model
+	OrderID uint   `json:"order_id" gorm:"index;not null"`
+	From    string `json:"from" gorm:"type:varchar(16)"`
+	To      string `json:"to" gorm:"type:varchar(16);not null"`
+	UserID  *uint  `json:"user_id"`
+	Note    string `json:"note"`
 }
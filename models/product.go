@@ -1,12 +1,63 @@
 package models
 
 import (
+	"time"
+
+	"github.com/rama-kairi/fiber-api/money"
 	"gorm.io/gorm"
 )
 
+// Product publishing states, only published products are shown to customers
+const (
+	ProductDraft     = "draft"
+	ProductPublished = "published"
+	ProductArchived  = "archived"
+)
+
 type Product struct {
 	gorm.Model
-	Name     string  `json:"name" gorm:"unique"`
-	Price    float64 `json:"price"`
-	Quantity int     `json:"quantity"`
+	Name     string       `json:"name" gorm:"unique"`
+	Slug     string       `json:"slug" gorm:"index"`
+	SKU      *string      `json:"sku" gorm:"uniqueIndex"`
+	Price    money.Amount `json:"price" gorm:"column:price_minor;not null;default:0"`
+	Quantity int          `json:"quantity"`
+
+	// In the default locale, translations are kept in ProductTranslation
+	Description string `json:"description"`
+
+	// Scheduled times move a draft to published and a published product to
+	// archived once they pass
+	Status      string     `json:"status" gorm:"type:varchar(16);index;not null;default:published"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+
+	CompareAtPrice *money.Amount `json:"compare_at_price" gorm:"column:compare_at_price_minor"`
+
+	// Picks the attribute definitions that apply to the product
+	ProductType string `json:"product_type" gorm:"type:varchar(64);index"`
+
+	// Picks the tax rate, products without one are taxed as standard
+	TaxCategory string `json:"tax_category" gorm:"type:varchar(32);index"`
+
+	// Bundles hold no stock of their own, it comes from their components
+	IsBundle bool `json:"is_bundle" gorm:"not null;default:false"`
+
+	// Digital products are delivered as downloads and hold no stock
+	IsDigital bool `json:"is_digital" gorm:"not null;default:false"`
+
+	// Gift card products issue a card worth their price for every unit paid
+	IsGiftCard bool `json:"is_gift_card" gorm:"not null;default:false"`
+
+	// Weight in grams and size in millimetres, what shipping is charged on
+	Weight int `json:"weight" gorm:"not null;default:0"`
+	Length int `json:"length" gorm:"not null;default:0"`
+	Width  int `json:"width" gorm:"not null;default:0"`
+	Height int `json:"height" gorm:"not null;default:0"`
+
+	ReorderThreshold int  `json:"reorder_threshold"`
+	LowStockAlerted  bool `json:"-"`
+
+	// Approved reviews only, kept up to date as reviews are moderated
+	RatingCount int `json:"-" gorm:"not null;default:0"`
+	RatingSum   int `json:"-" gorm:"not null;default:0"`
 }
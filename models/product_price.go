@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// ProductPrice - an entry of a product's price history. Entries without an end
+// are regular prices, entries with one are temporary sales. Entries starting
+// in the future are applied by the price scheduler.
+type ProductPrice struct {
+	gorm.Model
+	ProductID uint         `json:"product_id" gorm:"index;not null"`
+	Price     money.Amount `json:"price" gorm:"column:price_minor;not null;default:0"`
+	StartsAt  time.Time    `json:"starts_at" gorm:"index;not null"`
+	EndsAt    *time.Time   `json:"ends_at" gorm:"index"`
+	Applied   bool         `json:"applied"`
+	Ended     bool         `json:"ended"`
+	ActorID   *uint        `json:"actor_id"`
+}
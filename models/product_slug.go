@@ -0,0 +1,13 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ProductSlug - every slug a product has had. The current one is also kept on
+// Product, the others redirect to it.
+type ProductSlug struct {
+	gorm.Model
+	ProductID uint   `json:"product_id" gorm:"index;not null"`
+	Slug      string `json:"slug" gorm:"uniqueIndex;not null"`
+}
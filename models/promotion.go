@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// Promotion condition kinds, every condition of a promotion must hold for the
+// items it targets
+const (
+	ConditionMinSubtotal = "min_subtotal"
+	ConditionMinQuantity = "min_quantity"
+)
+
+// Promotion action kinds
+const (
+	ActionPercentOff = "percent_off"
+	ActionAmountOff  = "amount_off"
+	ActionBuyGet     = "buy_get"
+	ActionTier       = "tier"
+)
+
+// Promotion - a discount applied to orders automatically. It targets the items
+// of its products and categories, or every item without targets. Promotions
+// run by Priority, lowest first, each on what earlier ones left of an item.
+// An exclusive promotion skips items an earlier promotion discounted, and no
+// later promotion discounts the items it did.
+type Promotion struct {
+	gorm.Model
+	Name      string     `json:"name" gorm:"not null"`
+	Priority  int        `json:"priority" gorm:"not null;default:0"`
+	Exclusive bool       `json:"exclusive" gorm:"not null;default:false"`
+	StartsAt  *time.Time `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+
+	Targets    []PromotionTarget    `json:"-"`
+	Conditions []PromotionCondition `json:"-"`
+	Actions    []PromotionAction    `json:"-"`
+}
+
+// PromotionTarget - a product or a category (product type) a promotion targets
+type PromotionTarget struct {
+	gorm.Model
+	PromotionID uint   `json:"-" gorm:"index;not null"`
+	ProductID   uint   `json:"product_id"`
+	Category    string `json:"category" gorm:"type:varchar(64)"`
+}
+
+// PromotionCondition - min_subtotal needs the targeted items to add up to
+// Amount in the base currency, min_quantity needs Quantity units of them
+type PromotionCondition struct {
+	gorm.Model
+	PromotionID uint         `json:"-" gorm:"index;not null"`
+	Kind        string       `json:"kind" gorm:"type:varchar(32);not null"`
+	Amount      money.Amount `json:"amount" gorm:"column:amount_minor;not null;default:0"`
+	Quantity    int          `json:"quantity" gorm:"not null;default:0"`
+}
+
+// PromotionAction - percent_off takes Percent off the targeted items and
+// amount_off takes Amount (base currency) off them together. buy_get makes Get
+// of every Buy plus Get units free, the cheapest first. The tier actions of a
+// promotion form a volume price table: an item takes the Percent of the
+// highest tier whose Quantity its own quantity reaches.
+type PromotionAction struct {
+	gorm.Model
+	PromotionID uint         `json:"-" gorm:"index;not null"`
+	Kind        string       `json:"kind" gorm:"type:varchar(32);not null"`
+	Percent     float64      `json:"percent"`
+	Amount      money.Amount `json:"amount" gorm:"column:amount_minor;not null;default:0"`
+	Buy         int          `json:"buy" gorm:"not null;default:0"`
+	Get         int          `json:"get" gorm:"not null;default:0"`
+	Quantity    int          `json:"quantity" gorm:"not null;default:0"`
+}
+
+// OrderItemPromotion - the discount a promotion gave an order item, explaining
+// its price. Name is copied so later changes leave the order alone.
+type OrderItemPromotion struct {
+	gorm.Model
+	OrderItemID uint         `json:"order_item_id" gorm:"index;not null"`
+	PromotionID uint         `json:"promotion_id"`
+	Name        string       `json:"name"`
+	Discount    money.Amount `json:"discount" gorm:"column:discount_minor;not null;default:0"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// RelatedProduct - a product often bought together with ProductID. Score is
+// the number of orders containing both, Position 1 is the strongest. Rows are
+// rebuilt by the recommendation job.
+type RelatedProduct struct {
+	gorm.Model
+	ProductID uint `json:"product_id" gorm:"uniqueIndex:idx_related_product;not null"`
+	RelatedID uint `json:"related_id" gorm:"uniqueIndex:idx_related_product;not null"`
+	Score     int  `json:"score" gorm:"not null"`
+	Position  int  `json:"position" gorm:"not null"`
+}
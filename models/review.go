@@ -0,0 +1,30 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Review moderation states
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+type Review struct {
+	gorm.Model
+	ProductID    uint   `json:"product_id" gorm:"uniqueIndex:idx_review_product_user;not null"`
+	UserID       uint   `json:"user_id" gorm:"uniqueIndex:idx_review_product_user;not null"`
+	User         User   `json:"-"`
+	Rating       int    `json:"rating" gorm:"not null"`
+	Title        string `json:"title" gorm:"type:varchar(128)"`
+	Body         string `json:"body"`
+	Status       string `json:"status" gorm:"type:varchar(16);index;not null"`
+	HelpfulCount int    `json:"helpful_count" gorm:"not null;default:0"`
+}
+
+type ReviewVote struct {
+	gorm.Model
+	ReviewID uint `json:"review_id" gorm:"uniqueIndex:idx_vote_review_user;not null"`
+	UserID   uint `json:"user_id" gorm:"uniqueIndex:idx_vote_review_user;not null"`
+}
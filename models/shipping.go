@@ -0,0 +1,59 @@
+package models
+
+import (
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// Shipping method kinds
+const (
+	ShippingFlat     = "flat"
+	ShippingWeight   = "weight"
+	ShippingPrice    = "price"
+	ShippingFreeOver = "free_over"
+)
+
+// ShippingZone - the places a set of shipping methods delivers to
+type ShippingZone struct {
+	gorm.Model
+	Name string `json:"name" gorm:"not null"`
+
+	Regions []ShippingZoneRegion `json:"-" gorm:"foreignKey:ZoneID"`
+	Methods []ShippingMethod     `json:"-" gorm:"foreignKey:ZoneID"`
+}
+
+// ShippingZoneRegion - a country, or a region of it, in a zone. Without a
+// region it covers the rest of the country, regions are kept upper case. A
+// place belongs to one zone at most.
+type ShippingZoneRegion struct {
+	gorm.Model
+	ZoneID  uint   `json:"-" gorm:"index;not null"`
+	Country string `json:"country" gorm:"type:varchar(2);uniqueIndex:idx_shipping_place;not null"`
+	Region  string `json:"region" gorm:"type:varchar(64);uniqueIndex:idx_shipping_place;not null;default:''"`
+}
+
+// ShippingMethod - a way to ship to a zone. flat always costs Amount. weight
+// and price cost what the highest of their tiers the parcel weight or the
+// order subtotal reaches says, and are unavailable below the first tier.
+// free_over costs Amount unless the subtotal reaches Threshold. Amounts are
+// in the base currency.
+type ShippingMethod struct {
+	gorm.Model
+	ZoneID    uint         `json:"zone_id" gorm:"index;not null"`
+	Name      string       `json:"name" gorm:"not null"`
+	Kind      string       `json:"kind" gorm:"type:varchar(16);not null"`
+	Amount    money.Amount `json:"amount" gorm:"column:amount_minor;not null;default:0"`
+	Threshold money.Amount `json:"threshold" gorm:"column:threshold_minor;not null;default:0"`
+
+	Tiers []ShippingRateTier `json:"-" gorm:"foreignKey:MethodID"`
+}
+
+// ShippingRateTier - the cost of a weight or price method from MinWeight grams
+// or from a subtotal of MinSubtotal on
+type ShippingRateTier struct {
+	gorm.Model
+	MethodID    uint         `json:"-" gorm:"index;not null"`
+	MinWeight   int          `json:"min_weight" gorm:"not null;default:0"`
+	MinSubtotal money.Amount `json:"min_subtotal" gorm:"column:min_subtotal_minor;not null;default:0"`
+	Cost        money.Amount `json:"cost" gorm:"column:cost_minor;not null;default:0"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Reasons a product's stock can change
+const (
+	MovementSale       = "sale"
+	MovementRestock    = "restock"
+	MovementAdjustment = "adjustment"
+	MovementReturn     = "return"
+	MovementCorrection = "correction"
+	MovementTransfer   = "transfer"
+)
+
+type StockMovement struct {
+	gorm.Model
+	ProductID uint `json:"product_id" gorm:"index;not null"`
+	// Movements from before warehouses existed have none
+	WarehouseID *uint  `json:"warehouse_id" gorm:"index"`
+	Change      int    `json:"change"`
+	Reason      string `json:"reason" gorm:"type:varchar(32);not null"`
+	ActorID     *uint  `json:"actor_id"`
+	OrderID     *uint  `json:"order_id" gorm:"index"`
+	Note        string `json:"note"`
+}
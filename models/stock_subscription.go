@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockSubscription - a request to be told when a product is back in stock.
+// Guests subscribe with an email only. NotifiedAt is set once the notice went
+// out, subscribing again rearms it.
+type StockSubscription struct {
+	gorm.Model
+	ProductID  uint       `json:"product_id" gorm:"uniqueIndex:idx_subscription_product_email;not null"`
+	Email      string     `json:"email" gorm:"type:varchar(128);uniqueIndex:idx_subscription_product_email;not null"`
+	UserID     *uint      `json:"user_id" gorm:"index"`
+	Token      string     `json:"-" gorm:"uniqueIndex;not null"`
+	NotifiedAt *time.Time `json:"notified_at" gorm:"index"`
+}
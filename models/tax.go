@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// TaxRate - the tax of a jurisdiction for one tax category, or without a
+// category for every category that has no rate of its own. Without a region
+// it covers the whole country, regions are kept upper case. Inclusive rates
+// are already part of the prices, the others are added on top. Rate is a
+// percentage.
+type TaxRate struct {
+	gorm.Model
+	Country   string  `json:"country" gorm:"type:varchar(2);uniqueIndex:idx_tax_jurisdiction;not null"`
+	Region    string  `json:"region" gorm:"type:varchar(64);uniqueIndex:idx_tax_jurisdiction;not null;default:''"`
+	Category  string  `json:"category" gorm:"type:varchar(32);uniqueIndex:idx_tax_jurisdiction;not null;default:''"`
+	Name      string  `json:"name"`
+	Rate      float64 `json:"rate"`
+	Inclusive bool    `json:"inclusive" gorm:"not null;default:false"`
+}
+
+// OrderTax - a line of the tax breakdown of an order, the items taxed at one
+// rate. The rate is copied so later changes to it leave the order alone.
+type OrderTax struct {
+	gorm.Model
+	OrderID   uint         `json:"order_id" gorm:"index;not null"`
+	TaxRateID uint         `json:"tax_rate_id"`
+	Name      string       `json:"name"`
+	Country   string       `json:"country" gorm:"type:varchar(2)"`
+	Region    string       `json:"region" gorm:"type:varchar(64)"`
+	Category  string       `json:"category" gorm:"type:varchar(32)"`
+	Rate      float64      `json:"rate"`
+	Inclusive bool         `json:"inclusive"`
+	Taxable   money.Amount `json:"taxable" gorm:"column:taxable_minor;not null;default:0"`
+	Amount    money.Amount `json:"amount" gorm:"column:amount_minor;not null;default:0"`
+}
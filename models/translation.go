@@ -0,0 +1,23 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ProductTranslation - the name and description of a product in a locale
+// other than the default one, which is kept on Product itself
+type ProductTranslation struct {
+	gorm.Model
+	ProductID   uint   `json:"product_id" gorm:"uniqueIndex:idx_product_locale;not null"`
+	Locale      string `json:"locale" gorm:"type:varchar(16);uniqueIndex:idx_product_locale;not null"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// CategoryTranslation - the display name of a product type in a locale
+type CategoryTranslation struct {
+	gorm.Model
+	ProductType string `json:"product_type" gorm:"type:varchar(64);uniqueIndex:idx_category_locale;not null"`
+	Locale      string `json:"locale" gorm:"type:varchar(16);uniqueIndex:idx_category_locale;not null"`
+	Name        string `json:"name"`
+}
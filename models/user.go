@@ -10,4 +10,5 @@ type User struct {
 	LastName  string `json:"last_name" gorm:"type:varchar(128);not null"`
 	Email     string `json:"email" gorm:"type:varchar(128);not null;unique"`
 	Password  string `json:"password"`
+	IsAdmin   bool   `json:"-" gorm:"not null;default:false"`
 }
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Warehouse - a location stock is kept and shipped from. Lower priorities
+// ship first.
+type Warehouse struct {
+	gorm.Model
+	Code     string `json:"code" gorm:"uniqueIndex;not null"`
+	Name     string `json:"name"`
+	Region   string `json:"region" gorm:"type:varchar(64);index"`
+	Priority int    `json:"priority" gorm:"not null;default:0"`
+}
+
+// WarehouseStock - the stock of a product in a warehouse. Product.Quantity is
+// the sum over all warehouses.
+type WarehouseStock struct {
+	gorm.Model
+	WarehouseID uint `json:"warehouse_id" gorm:"uniqueIndex:idx_warehouse_product;not null"`
+	ProductID   uint `json:"product_id" gorm:"uniqueIndex:idx_warehouse_product;index;not null"`
+	Quantity    int  `json:"quantity" gorm:"not null;default:0"`
+}
+
+// StockAllocation - how much of a product an order took from a warehouse, so
+// returns go back where they came from
+type StockAllocation struct {
+	gorm.Model
+	OrderID     uint `json:"order_id" gorm:"index;not null"`
+	ProductID   uint `json:"product_id" gorm:"not null"`
+	WarehouseID uint `json:"warehouse_id" gorm:"not null"`
+	Quantity    int  `json:"quantity" gorm:"not null"`
+}
+
+// StockTransfer - stock moved between two warehouses
+type StockTransfer struct {
+	gorm.Model
+	ProductID       uint   `json:"product_id" gorm:"index;not null"`
+	FromWarehouseID uint   `json:"from_warehouse_id" gorm:"not null"`
+	ToWarehouseID   uint   `json:"to_warehouse_id" gorm:"not null"`
+	Quantity        int    `json:"quantity" gorm:"not null"`
+	ActorID         *uint  `json:"actor_id"`
+	Note            string `json:"note"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// Wishlist - products a user saved for later. Anyone with the share token can
+// see it, it has none while private.
+type Wishlist struct {
+	gorm.Model
+	UserID     uint           `json:"user_id" gorm:"uniqueIndex:idx_wishlist_user_name;not null"`
+	Name       string         `json:"name" gorm:"uniqueIndex:idx_wishlist_user_name;not null"`
+	ShareToken *string        `json:"-" gorm:"uniqueIndex"`
+	Items      []WishlistItem `json:"items"`
+}
+
+// WishlistItem - a product on a wishlist. Price is the last price the owner
+// was told about, a lower product price triggers a price-drop notification.
+type WishlistItem struct {
+	gorm.Model
+	WishlistID uint         `json:"wishlist_id" gorm:"uniqueIndex:idx_wishlist_product;not null"`
+	ProductID  uint         `json:"product_id" gorm:"uniqueIndex:idx_wishlist_product;index;not null"`
+	Product    Product      `json:"-"`
+	Price      money.Amount `json:"price" gorm:"column:price_minor;not null;default:0"`
+}
@@ -0,0 +1,198 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Digits - the decimal places of every supported currency
+const Digits = 2
+
+// unit - minor units in one major unit
+const unit = 100
+
+// ErrInvalid - the text is not an amount with at most Digits decimal places
+var ErrInvalid = errors.New("invalid amount")
+
+// Amount - an exact amount of money in minor units (cents). The currency is
+// kept next to it: the base currency for catalog prices, the order currency
+// for order totals. Sums and multiples by a quantity are exact, anything
+// involving a fraction is rounded half away from zero to the minor unit.
+type Amount int64
+
+// Parse - reads a decimal amount such as "12", "12.5" or "-0.99". Amounts with
+// more decimal places than the currency has are rejected instead of rounded.
+func Parse(text string) (Amount, error) {
+	text = strings.TrimSpace(text)
+
+	negative := strings.HasPrefix(text, "-")
+	if negative {
+		text = text[1:]
+	}
+
+	whole, fraction := text, ""
+	if dot := strings.IndexByte(text, '.'); dot >= 0 {
+		whole, fraction = text[:dot], text[dot+1:]
+		if fraction == "" {
+			return 0, ErrInvalid
+		}
+	}
+
+	if whole == "" || len(whole) > 15 || len(fraction) > Digits || !digits(whole) || !digits(fraction) {
+		return 0, ErrInvalid
+	}
+
+	fraction += strings.Repeat("0", Digits-len(fraction))
+	value, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+
+	if negative {
+		value = -value
+	}
+	return Amount(value), nil
+}
+
+func digits(text string) bool {
+	for _, r := range text {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Times - the amount for a quantity
+func (a Amount) Times(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+// Convert - the amount at an exchange rate, rounded half away from zero
+func (a Amount) Convert(rate float64) Amount {
+	return a.scale(decimal(rate), big.NewRat(1, 1))
+}
+
+// Percent - percent of the amount, rounded half away from zero
+func (a Amount) Percent(percent float64) Amount {
+	return a.scale(decimal(percent), big.NewRat(100, 1))
+}
+
+// Proportion - the amount times part / whole, rounded half away from zero,
+// e.g. a price cut by the same share as another
+func (a Amount) Proportion(part, whole Amount) Amount {
+	return a.scale(big.NewRat(int64(part), 1), big.NewRat(int64(whole), 1))
+}
+
+// PercentIncluded - the part of the amount that is percent added on top of a
+// smaller amount, like the tax in a price that includes it. Rounded half away
+// from zero.
+func (a Amount) PercentIncluded(percent float64) Amount {
+	rate := decimal(percent)
+	return a.scale(rate, new(big.Rat).Add(rate, big.NewRat(100, 1)))
+}
+
+// Allocate - splits a positive amount over parts in proportion to their
+// weights, exactly: the cents left over by rounding down go to the parts with
+// the largest remainders, the earlier part on a tie.
+func (a Amount) Allocate(weights []Amount) []Amount {
+	shares := make([]Amount, len(weights))
+
+	total := new(big.Int)
+	for _, weight := range weights {
+		total.Add(total, big.NewInt(int64(weight)))
+	}
+	if total.Sign() <= 0 {
+		return shares
+	}
+
+	remainders := make([]*big.Int, len(weights))
+	left := a
+	for i, weight := range weights {
+		share, remainder := new(big.Int).QuoRem(
+			new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(weight))), total, new(big.Int))
+		shares[i] = Amount(share.Int64())
+		remainders[i] = remainder
+		left -= shares[i]
+	}
+
+	for ; left > 0; left-- {
+		largest := 0
+		for i := range remainders {
+			if remainders[i].Cmp(remainders[largest]) > 0 {
+				largest = i
+			}
+		}
+		shares[largest]++
+		remainders[largest] = new(big.Int)
+	}
+	return shares
+}
+
+// decimal - the number as the decimal it prints as, so 1.1 is exactly 1.1
+func decimal(number float64) *big.Rat {
+	exact, ok := new(big.Rat).SetString(strconv.FormatFloat(number, 'g', -1, 64))
+	if !ok {
+		return new(big.Rat)
+	}
+	return exact
+}
+
+// scale - the amount times numerator / denominator, rounded
+func (a Amount) scale(numerator, denominator *big.Rat) Amount {
+	if denominator.Sign() == 0 {
+		return 0
+	}
+
+	value := new(big.Rat).SetInt64(int64(a))
+	value.Mul(value, numerator).Quo(value, denominator)
+	return round(value)
+}
+
+// round - the nearest whole minor unit, halves away from zero
+func round(value *big.Rat) Amount {
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+
+	remainder.Abs(remainder).Lsh(remainder, 1)
+	if remainder.Cmp(value.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+	}
+	return Amount(quotient.Int64())
+}
+
+// String - the amount as a decimal with Digits places, e.g. "-0.50"
+func (a Amount) String() string {
+	sign, value := "", int64(a)
+	if value < 0 {
+		sign, value = "-", -value
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, value/unit, value%unit)
+}
+
+// MarshalJSON - a decimal string, so clients never see a float
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(a.String())), nil
+}
+
+// UnmarshalJSON - accepts a decimal string or a plain JSON number
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+
+	value, err := Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s, use a decimal with at most %d places", data, Digits)
+	}
+
+	*a = value
+	return nil
+}
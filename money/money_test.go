@@ -0,0 +1,193 @@
+package money
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want Amount
+		err  bool
+	}{
+		{"12", 1200, false},
+		{"12.5", 1250, false},
+		{"12.05", 1205, false},
+		{" 0.99 ", 99, false},
+		{"-0.99", -99, false},
+		{"0", 0, false},
+		{"12.345", 0, true},
+		{"12.", 0, true},
+		{".5", 0, true},
+		{"", 0, true},
+		{"-", 0, true},
+		{"1e3", 0, true},
+		{"1,50", 0, true},
+		{"+1", 0, true},
+		{"1234567890123456", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := Parse(test.text)
+		if (err != nil) != test.err {
+			t.Errorf("Parse(%q) error = %v, want error %v", test.text, err, test.err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Parse(%q) = %d, want %d", test.text, got, test.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1250, "12.50"},
+		{-50, "-0.50"},
+		{-1205, "-12.05"},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.String(); got != test.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", test.amount, got, test.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		rate   float64
+		want   Amount
+	}{
+		{1000, 0.9, 900},
+		{1, 1.5, 2},
+		{-1, 1.5, -2},
+		{333, 1.1, 366},
+		{1000, 0, 0},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.Convert(test.rate); got != test.want {
+			t.Errorf("Amount(%d).Convert(%v) = %d, want %d", test.amount, test.rate, got, test.want)
+		}
+	}
+}
+
+func TestProportion(t *testing.T) {
+	tests := []struct {
+		amount      Amount
+		part, whole Amount
+		want        Amount
+	}{
+		{2000, 1000, 2000, 1000},
+		{1000, 1, 3, 333},
+		{1000, 2, 3, 667},
+		{1000, 5, 0, 0},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.Proportion(test.part, test.whole); got != test.want {
+			t.Errorf("Amount(%d).Proportion(%d, %d) = %d, want %d", test.amount, test.part, test.whole, got, test.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want Amount
+		err  bool
+	}{
+		{`"12.50"`, 1250, false},
+		{`12.5`, 1250, false},
+		{`"-3"`, -300, false},
+		{`null`, 0, false},
+		{`"12.505"`, 0, true},
+		{`"abc"`, 0, true},
+	}
+
+	for _, test := range tests {
+		var got Amount
+		err := json.Unmarshal([]byte(test.data), &got)
+		if (err != nil) != test.err {
+			t.Errorf("Unmarshal(%s) error = %v, want error %v", test.data, err, test.err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", test.data, got, test.want)
+		}
+	}
+
+	data, err := json.Marshal(Amount(1250))
+	if err != nil || string(data) != `"12.50"` {
+		t.Errorf("Marshal(1250) = %s, %v, want \"12.50\"", data, err)
+	}
+}
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		amount  Amount
+		percent float64
+		want    Amount
+	}{
+		{1000, 10, 100},
+		{999, 10, 100},
+		{5, 10, 1},
+		{4, 10, 0},
+		{-5, 10, -1},
+		{1999, 7.5, 150},
+		{1000, 0, 0},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.Percent(test.percent); got != test.want {
+			t.Errorf("Amount(%d).Percent(%v) = %d, want %d", test.amount, test.percent, got, test.want)
+		}
+	}
+}
+
+func TestPercentIncluded(t *testing.T) {
+	tests := []struct {
+		amount  Amount
+		percent float64
+		want    Amount
+	}{
+		{1200, 20, 200},
+		{1000, 20, 167},
+		{1190, 19, 190},
+		{1000, 0, 0},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.PercentIncluded(test.percent); got != test.want {
+			t.Errorf("Amount(%d).PercentIncluded(%v) = %d, want %d", test.amount, test.percent, got, test.want)
+		}
+	}
+}
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		amount  Amount
+		weights []Amount
+		want    []Amount
+	}{
+		{100, []Amount{1, 1, 1}, []Amount{34, 33, 33}},
+		{1000, []Amount{1, 3}, []Amount{250, 750}},
+		{101, []Amount{1, 2}, []Amount{34, 67}},
+		{5, []Amount{0, 1}, []Amount{0, 5}},
+		{100, []Amount{0, 0}, []Amount{0, 0}},
+		{100, []Amount{}, []Amount{}},
+	}
+
+	for _, test := range tests {
+		got := test.amount.Allocate(test.weights)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Amount(%d).Allocate(%v) = %v, want %v", test.amount, test.weights, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/config"
+)
+
+// Message - a notification, delivered as is by every notifier.
+type Message struct {
+	Event   string                 `json:"event"`
+	To      string                 `json:"to"`
+	Subject string                 `json:"subject"`
+	Body    string                 `json:"body"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// Notifier - delivers messages to one channel.
+type Notifier interface {
+	Notify(msg Message) error
+}
+
+// Log - writes messages to the application log.
+type Log struct{}
+
+func (Log) Notify(msg Message) error {
+	log.Printf("[%s] %s: %s", msg.Event, msg.Subject, msg.Body)
+	return nil
+}
+
+// MailSink - writes messages as .eml files into a local directory instead of
+// talking to an SMTP server.
+type MailSink struct {
+	Dir  string
+	From string
+	To   string
+}
+
+func (m MailSink) Notify(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	to := msg.To
+	if to == "" {
+		to = m.To
+	}
+
+	now := time.Now()
+	mail := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		m.From, to, msg.Subject, now.Format(time.RFC1123Z), msg.Body)
+
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), msg.Event)
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(mail), 0o644)
+}
+
+// Webhook - posts messages as JSON to an outbound URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w Webhook) Notify(msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded with %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// Multi - delivers every message through all of its notifiers.
+type Multi []Notifier
+
+func (m Multi) Notify(msg Message) error {
+	failed := &DeliveryError{}
+	for _, n := range m {
+		if err := n.Notify(msg); err != nil {
+			failed.Errors = append(failed.Errors, err.Error())
+		} else {
+			failed.Delivered = true
+		}
+	}
+
+	if len(failed.Errors) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// DeliveryError - the channels of a Multi that failed. Delivered is set when
+// another channel got the message anyway.
+type DeliveryError struct {
+	Errors    []string
+	Delivered bool
+}
+
+func (e *DeliveryError) Error() string {
+	return strings.Join(e.Errors, "; ")
+}
+
+// Delivered - whether a message reached at least one channel, given the error
+// its notifier returned
+func Delivered(err error) bool {
+	var failed *DeliveryError
+	if errors.As(err, &failed) {
+		return failed.Delivered
+	}
+	return err == nil
+}
+
+// FromConfig - builds the notifier for the channels listed in the config.
+func FromConfig(cfg config.Notify) Notifier {
+	notifiers := Multi{}
+
+	for _, channel := range strings.Split(cfg.Channels, ",") {
+		switch strings.TrimSpace(channel) {
+		case "log":
+			notifiers = append(notifiers, Log{})
+		case "email":
+			notifiers = append(notifiers, MailSink{Dir: cfg.MailSinkDir, From: cfg.MailFrom, To: cfg.MailTo})
+		case "webhook":
+			if cfg.WebhookURL != "" {
+				notifiers = append(notifiers, Webhook{URL: cfg.WebhookURL})
+			}
+		case "":
+		default:
+			log.Println("Unknown notification channel: ", channel)
+		}
+	}
+
+	return notifiers
+}
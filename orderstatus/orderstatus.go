@@ -0,0 +1,181 @@
+package orderstatus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// ErrForbidden - the status is one only an admin can move an order to
+var ErrForbidden = errors.New("Only an admin can move the order to that status")
+
+// TransitionError - the order can not go from its status to the next
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("Order can not go from %s to %s", e.From, e.To)
+}
+
+// transitions - the statuses an order in each status can move to. Orders are
+// cancelled before they are paid and refunded after, cancelled and refunded
+// orders stay as they are.
+var transitions = map[string][]string{
+	models.OrderPending:         {models.OrderAwaitingPayment, models.OrderPaid, models.OrderCancelled},
+	models.OrderAwaitingPayment: {models.OrderPending, models.OrderPaid, models.OrderCancelled},
+	// Orders with nothing to ship are delivered once they are paid
+	models.OrderPaid:       {models.OrderFulfilling, models.OrderDelivered, models.OrderRefunded},
+	models.OrderFulfilling: {models.OrderShipped, models.OrderRefunded},
+	models.OrderShipped:    {models.OrderDelivered, models.OrderRefunded},
+	models.OrderDelivered:  {models.OrderRefunded},
+}
+
+// customerMoves - the statuses the buyer may move their own order to, every
+// other move is left to admins
+var customerMoves = map[string]bool{
+	models.OrderPending:         true,
+	models.OrderAwaitingPayment: true,
+	models.OrderCancelled:       true,
+}
+
+// Statuses - every status an order can be in, in the order they follow
+var Statuses = []string{
+	models.OrderPending, models.OrderAwaitingPayment, models.OrderPaid, models.OrderFulfilling,
+	models.OrderShipped, models.OrderDelivered, models.OrderCancelled, models.OrderRefunded,
+}
+
+// Valid - whether the status is one of the order statuses
+func Valid(status string) bool {
+	for _, known := range Statuses {
+		if known == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Next - the statuses an order in the status can move to
+func Next(status string) []string {
+	next := transitions[status]
+	if next == nil {
+		return []string{}
+	}
+	return next
+}
+
+// Allowed - whether an order can go from one status to the other
+func Allowed(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Check - whether the order can move to the status, by an admin or otherwise
+// by its buyer
+func Check(order models.Order, to string, admin bool) error {
+	if !Allowed(order.Status, to) {
+		return &TransitionError{From: order.Status, To: to}
+	}
+	if !admin && !customerMoves[to] {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Editable - whether the items and shipping of an order in the status can
+// still change
+func Editable(status string) bool {
+	return status == models.OrderPending
+}
+
+// Paid - whether an order in the status was paid and not refunded yet
+func Paid(status string) bool {
+	switch status {
+	case models.OrderPaid, models.OrderFulfilling, models.OrderShipped, models.OrderDelivered:
+		return true
+	}
+	return false
+}
+
+// Start - records the status a new order was placed with
+func Start(tx *gorm.DB, order models.Order, userID *uint) error {
+	return tx.Create(&models.OrderStatusChange{
+		OrderID: order.ID,
+		To:      order.Status,
+		UserID:  userID,
+	}).Error
+}
+
+// Move - moves the order to the status and records it with who moved it.
+// Orders already in the status are left alone, paid and delivered orders
+// keep when that first happened.
+func Move(tx *gorm.DB, order *models.Order, to string, userID *uint, note string) error {
+	from := order.Status
+	if from == to {
+		return nil
+	}
+	if !Allowed(from, to) {
+		return &TransitionError{From: from, To: to}
+	}
+
+	now := time.Now()
+	columns := map[string]interface{}{"status": to}
+	if to == models.OrderPaid && order.PaidAt == nil {
+		columns["paid_at"] = now
+		order.PaidAt = &now
+	}
+	if to == models.OrderDelivered && order.DeliveredAt == nil {
+		columns["delivered_at"] = now
+		order.DeliveredAt = &now
+	}
+
+	// Only moving from the status it was read with, so two requests can not
+	// both move the order
+	result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, from).UpdateColumns(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		current := models.Order{}
+		tx.Select("status").First(&current, order.ID)
+		return &TransitionError{From: current.Status, To: to}
+	}
+	order.Status = to
+
+	return tx.Create(&models.OrderStatusChange{
+		OrderID: order.ID,
+		From:    from,
+		To:      to,
+		UserID:  userID,
+		Note:    note,
+	}).Error
+}
+
+// History - the statuses the order went through, oldest first
+func History(db *gorm.DB, orderID uint) []models.OrderStatusChange {
+	history := []models.OrderStatusChange{}
+	db.Where("order_id = ?", orderID).Order("id").Find(&history)
+	return history
+}
+
+// Backfill - gives orders from before statuses existed the status their paid
+// and delivered times tell
+func Backfill(db *gorm.DB) error {
+	if err := db.Model(&models.Order{}).Unscoped().
+		Where("status = ? AND delivered_at IS NOT NULL", models.OrderPending).
+		UpdateColumn("status", models.OrderDelivered).Error; err != nil {
+		return err
+	}
+
+	return db.Model(&models.Order{}).Unscoped().
+		Where("status = ? AND paid_at IS NOT NULL", models.OrderPending).
+		UpdateColumn("status", models.OrderPaid).Error
+}
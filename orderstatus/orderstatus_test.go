@@ -0,0 +1,140 @@
+package orderstatus
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		from  string
+		to    string
+		admin bool
+		// Whether it is refused as a transition or as a move only admins make
+		transition bool
+		forbidden  bool
+	}{
+		{models.OrderPending, models.OrderAwaitingPayment, false, false, false},
+		{models.OrderAwaitingPayment, models.OrderPending, false, false, false},
+		{models.OrderPending, models.OrderCancelled, false, false, false},
+		{models.OrderAwaitingPayment, models.OrderCancelled, false, false, false},
+		{models.OrderPending, models.OrderPaid, false, false, true},
+		{models.OrderPending, models.OrderPaid, true, false, false},
+		{models.OrderPaid, models.OrderFulfilling, false, false, true},
+		{models.OrderPaid, models.OrderFulfilling, true, false, false},
+		{models.OrderPaid, models.OrderDelivered, true, false, false},
+		{models.OrderFulfilling, models.OrderShipped, true, false, false},
+		{models.OrderShipped, models.OrderDelivered, true, false, false},
+		{models.OrderDelivered, models.OrderRefunded, true, false, false},
+		{models.OrderDelivered, models.OrderRefunded, false, false, true},
+		{models.OrderPaid, models.OrderCancelled, false, true, false},
+		{models.OrderPaid, models.OrderCancelled, true, true, false},
+		{models.OrderPending, models.OrderRefunded, true, true, false},
+		{models.OrderPending, models.OrderShipped, true, true, false},
+		{models.OrderShipped, models.OrderPending, true, true, false},
+		{models.OrderCancelled, models.OrderPending, false, true, false},
+		{models.OrderCancelled, models.OrderPaid, true, true, false},
+		{models.OrderRefunded, models.OrderPaid, true, true, false},
+		{models.OrderPending, models.OrderPending, true, true, false},
+		{models.OrderPending, "lost", true, true, false},
+	}
+
+	for _, test := range tests {
+		err := Check(models.Order{Status: test.from}, test.to, test.admin)
+
+		var transition *TransitionError
+		if got := errors.As(err, &transition); got != test.transition {
+			t.Errorf("Check(%s -> %s, admin %v) = %v, want transition error %v", test.from, test.to, test.admin, err, test.transition)
+		}
+		if got := errors.Is(err, ErrForbidden); got != test.forbidden {
+			t.Errorf("Check(%s -> %s, admin %v) = %v, want forbidden %v", test.from, test.to, test.admin, err, test.forbidden)
+		}
+		if transition != nil && (transition.From != test.from || transition.To != test.to) {
+			t.Errorf("Check(%s -> %s) reports %s -> %s", test.from, test.to, transition.From, transition.To)
+		}
+	}
+}
+
+func TestPaid(t *testing.T) {
+	tests := []struct {
+		status   string
+		paid     bool
+		editable bool
+	}{
+		{models.OrderPending, false, true},
+		{models.OrderAwaitingPayment, false, false},
+		{models.OrderPaid, true, false},
+		{models.OrderFulfilling, true, false},
+		{models.OrderShipped, true, false},
+		{models.OrderDelivered, true, false},
+		{models.OrderCancelled, false, false},
+		{models.OrderRefunded, false, false},
+	}
+
+	for _, test := range tests {
+		if got := Paid(test.status); got != test.paid {
+			t.Errorf("Paid(%s) = %v, want %v", test.status, got, test.paid)
+		}
+		if got := Editable(test.status); got != test.editable {
+			t.Errorf("Editable(%s) = %v, want %v", test.status, got, test.editable)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		// The status another request moved the order to after it was read
+		stored string
+		to     string
+		want   string
+		err    bool
+		// Status changes recorded by the move
+		changes int
+	}{
+		{"allowed", models.OrderPending, "", models.OrderPaid, models.OrderPaid, false, 1},
+		{"same status", models.OrderPaid, "", models.OrderPaid, models.OrderPaid, false, 0},
+		{"not allowed", models.OrderPending, "", models.OrderShipped, models.OrderPending, true, 0},
+		{"moved meanwhile", models.OrderPending, models.OrderCancelled, models.OrderPaid, models.OrderCancelled, true, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := testdb.Open(t, &models.Order{}, &models.OrderStatusChange{})
+
+			order := models.Order{UserID: 1, Status: test.from}
+			if err := db.Create(&order).Error; err != nil {
+				t.Fatal(err)
+			}
+			if test.stored != "" {
+				db.Model(&models.Order{}).Where("id = ?", order.ID).UpdateColumn("status", test.stored)
+			}
+
+			err := Move(db, &order, test.to, nil, "")
+
+			var transition *TransitionError
+			if got := errors.As(err, &transition); got != test.err {
+				t.Fatalf("Move() = %v, want transition error %v", err, test.err)
+			}
+			if transition != nil && transition.From != test.want {
+				t.Errorf("error from = %s, want %s", transition.From, test.want)
+			}
+
+			stored := models.Order{}
+			db.First(&stored, order.ID)
+			if stored.Status != test.want {
+				t.Errorf("stored status = %s, want %s", stored.Status, test.want)
+			}
+			if test.changes > 0 && test.want == models.OrderPaid && stored.PaidAt == nil {
+				t.Errorf("paid order has no paid time")
+			}
+			if changes := len(History(db, order.ID)); changes != test.changes {
+				t.Errorf("changes = %d, want %d", changes, test.changes)
+			}
+		})
+	}
+}
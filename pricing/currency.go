@@ -0,0 +1,119 @@
+package pricing
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/config"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// Quote - the price of a product in one currency
+type Quote struct {
+	Currency       string
+	Rate           float64
+	Price          money.Amount
+	CompareAtPrice *money.Amount
+	Explicit       bool
+}
+
+var (
+	currencies     config.Currency
+	currenciesOnce sync.Once
+)
+
+// currencyConfig - the currency settings, read once since every priced
+// request needs them
+func currencyConfig() config.Currency {
+	currenciesOnce.Do(func() {
+		currencies = config.GetConfig().Currency
+	})
+	return currencies
+}
+
+// Base - the currency product prices are kept in
+func Base() string {
+	return currencyConfig().Base
+}
+
+// IsSupported - whether the currency can be sold in
+func IsSupported(currency string) bool {
+	for _, supported := range currencyConfig().Supported {
+		if supported == currency {
+			return true
+		}
+	}
+	return false
+}
+
+// Rate - the exchange rate from the base currency to currency
+func Rate(db *gorm.DB, currency string) (float64, error) {
+	if currency == "" || currency == Base() {
+		return 1, nil
+	}
+
+	var rate models.ExchangeRate
+	db.Where("currency = ?", currency).Limit(1).Find(&rate)
+
+	if rate.ID == 0 || rate.Rate <= 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", currency)
+	}
+	return rate.Rate, nil
+}
+
+// onSale - whether a temporary sale price of the product is in effect at now
+func onSale(db *gorm.DB, productID uint, now time.Time) bool {
+	var active models.ProductPrice
+	db.Where("product_id = ? AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", productID, now, now).
+		Order("starts_at desc, id desc").Limit(1).Find(&active)
+	return active.ID != 0 && active.EndsAt != nil
+}
+
+// QuoteProduct - prices the product in currency, from its explicit price list
+// entry if there is one, else converted from the base price.
+func QuoteProduct(db *gorm.DB, product models.Product, currency string) (Quote, error) {
+	if currency == "" {
+		currency = Base()
+	}
+
+	rate, err := Rate(db, currency)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	quote := Quote{Currency: currency, Rate: rate}
+
+	var explicit models.ProductCurrencyPrice
+	db.Where("product_id = ? AND currency = ?", product.ID, currency).Limit(1).Find(&explicit)
+
+	if explicit.ID != 0 {
+		quote.Price = explicit.Price
+		quote.Explicit = true
+
+		// The explicit price is the regular price in the currency. Sales and
+		// compare-at prices take off or add the same share of it as they do of
+		// the base price.
+		regular := product.Price
+		if product.CompareAtPrice != nil && onSale(db, product.ID, time.Now()) {
+			regular = *product.CompareAtPrice
+		}
+		if regular > 0 {
+			quote.Price = explicit.Price.Proportion(product.Price, regular)
+			if product.CompareAtPrice != nil {
+				compareAt := explicit.Price.Proportion(*product.CompareAtPrice, regular)
+				quote.CompareAtPrice = &compareAt
+			}
+		}
+		return quote, nil
+	}
+
+	quote.Price = product.Price.Convert(rate)
+	if product.CompareAtPrice != nil {
+		compareAt := product.CompareAtPrice.Convert(rate)
+		quote.CompareAtPrice = &compareAt
+	}
+	return quote, nil
+}
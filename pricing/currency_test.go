@@ -0,0 +1,74 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+)
+
+func TestQuoteProduct(t *testing.T) {
+	testdb.Env(t)
+
+	tests := []struct {
+		name     string
+		currency string
+		// Explicit EUR price, none when 0
+		explicit  money.Amount
+		sale      bool
+		want      money.Amount
+		compareAt money.Amount
+	}{
+		{"base currency", "", 0, false, 2000, 0},
+		{"converted", "EUR", 0, false, 1800, 0},
+		{"converted sale", "EUR", 0, true, 1350, 1800},
+		{"explicit", "EUR", 1900, false, 1900, 0},
+		{"explicit sale", "EUR", 1900, true, 1425, 1900},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, product := priceDB(t)
+			db.AutoMigrate(&models.ExchangeRate{}, &models.ProductCurrencyPrice{})
+			db.Create(&models.ExchangeRate{Currency: "EUR", Rate: 0.9})
+
+			if test.explicit > 0 {
+				db.Create(&models.ProductCurrencyPrice{ProductID: product.ID, Currency: "EUR", Price: test.explicit})
+			}
+			if test.sale {
+				ends := time.Now().Add(time.Hour)
+				if _, err := Schedule(db, product, 1500, time.Now(), &ends, 0); err != nil {
+					t.Fatal(err)
+				}
+				db.First(&product, product.ID)
+			}
+
+			quote, err := QuoteProduct(db, product, test.currency)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var compareAt money.Amount
+			if quote.CompareAtPrice != nil {
+				compareAt = *quote.CompareAtPrice
+			}
+			if quote.Price != test.want || compareAt != test.compareAt {
+				t.Errorf("QuoteProduct() = %v compare at %v, want %v compare at %v",
+					quote.Price, compareAt, test.want, test.compareAt)
+			}
+		})
+	}
+}
+
+func TestQuoteProductWithoutRate(t *testing.T) {
+	testdb.Env(t)
+
+	db, product := priceDB(t)
+	db.AutoMigrate(&models.ExchangeRate{}, &models.ProductCurrencyPrice{})
+
+	if _, err := QuoteProduct(db, product, "GBP"); err == nil {
+		t.Error("QuoteProduct() priced in a currency without an exchange rate")
+	}
+}
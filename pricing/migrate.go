@@ -0,0 +1,49 @@
+package pricing
+
+import (
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// floatColumns - the float price columns from before prices were kept in minor
+// units, with the columns that replace them
+var floatColumns = []struct {
+	model interface{}
+	from  string
+	to    string
+}{
+	{&models.Product{}, "price", "price_minor"},
+	{&models.Product{}, "compare_at_price", "compare_at_price_minor"},
+	{&models.ProductPrice{}, "price", "price_minor"},
+	{&models.ProductCurrencyPrice{}, "price", "price_minor"},
+	{&models.OrderItem{}, "price", "price_minor"},
+	{&models.Order{}, "price", "price_minor"},
+	{&models.WishlistItem{}, "price", "price_minor"},
+}
+
+// MigrateMinorUnits - moves prices stored as floats into their minor unit
+// columns, rounding half away from zero to the cent, and drops the float
+// columns so it only happens once.
+func MigrateMinorUnits(db *gorm.DB) error {
+	for _, column := range floatColumns {
+		if !db.Migrator().HasColumn(column.model, column.from) {
+			continue
+		}
+
+		if err := db.Model(column.model).Unscoped().
+			Where(column.from+" IS NOT NULL").
+			UpdateColumn(column.to, gorm.Expr("CAST(ROUND("+column.from+" * 100) AS INTEGER)")).Error; err != nil {
+			return err
+		}
+
+		if err := db.Migrator().DropColumn(column.model, column.from); err != nil {
+			return err
+		}
+
+		// SQLite drops a column by rebuilding the table, which loses its indexes
+		if err := db.AutoMigrate(column.model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package pricing
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// optionalID - stores 0 as NULL
+func optionalID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// SetPrice - changes the regular price of a product right away and records it
+// in the price history.
+func SetPrice(tx *gorm.DB, productID uint, price money.Amount, actorID uint) error {
+	now := time.Now()
+
+	if err := tx.Create(&models.ProductPrice{
+		ProductID: productID,
+		Price:     price,
+		StartsAt:  now,
+		ActorID:   optionalID(actorID),
+	}).Error; err != nil {
+		return err
+	}
+
+	return Recompute(tx, productID, now)
+}
+
+// Schedule - records a price entry starting at startsAt, a temporary one when
+// endsAt is set. Entries already due are applied immediately, the others by
+// the scheduler.
+func Schedule(tx *gorm.DB, product models.Product, price money.Amount, startsAt time.Time, endsAt *time.Time, actorID uint) (models.ProductPrice, error) {
+	entry := models.ProductPrice{
+		ProductID: product.ID,
+		Price:     price,
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+		ActorID:   optionalID(actorID),
+	}
+
+	if endsAt != nil && !endsAt.After(startsAt) {
+		return entry, errors.New("ends_at must be after starts_at")
+	}
+
+	// Products from before the price history get their current price as the
+	// regular price, so a temporary sale has something to fall back to
+	var count int64
+	tx.Model(&models.ProductPrice{}).Where("product_id = ?", product.ID).Count(&count)
+	if count == 0 {
+		if err := tx.Create(&models.ProductPrice{
+			ProductID: product.ID,
+			Price:     product.Price,
+			StartsAt:  product.CreatedAt,
+			Applied:   true,
+		}).Error; err != nil {
+			return entry, err
+		}
+	}
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return entry, err
+	}
+
+	now := time.Now()
+	if !startsAt.After(now) {
+		if err := Recompute(tx, product.ID, now); err != nil {
+			return entry, err
+		}
+	}
+
+	return entry, tx.First(&entry, entry.ID).Error
+}
+
+// Recompute - sets the price of a product to the entry in effect at now. While
+// a temporary sale runs, the regular price becomes the compare-at price.
+func Recompute(tx *gorm.DB, productID uint, now time.Time) error {
+	var active models.ProductPrice
+	tx.Where("product_id = ? AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", productID, now, now).
+		Order("starts_at desc, id desc").Limit(1).Find(&active)
+
+	if active.ID != 0 {
+		var compareAt *money.Amount
+		if active.EndsAt != nil {
+			var regular models.ProductPrice
+			tx.Where("product_id = ? AND starts_at <= ? AND ends_at IS NULL", productID, now).
+				Order("starts_at desc, id desc").Limit(1).Find(&regular)
+
+			if regular.ID != 0 && regular.Price > active.Price {
+				compareAt = &regular.Price
+			}
+		}
+
+		if err := tx.Model(&models.Product{}).Where("id = ?", productID).Updates(map[string]interface{}{
+			"price_minor":            active.Price,
+			"compare_at_price_minor": compareAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&models.ProductPrice{}).
+		Where("product_id = ? AND applied = ? AND starts_at <= ?", productID, false, now).
+		Update("applied", true).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.ProductPrice{}).
+		Where("product_id = ? AND ended = ? AND ends_at <= ?", productID, false, now).
+		Update("ended", true).Error
+}
+
+// History - returns the price entries of a product, latest start first.
+func History(db *gorm.DB, productID uint) ([]models.ProductPrice, error) {
+	var prices []models.ProductPrice
+	err := db.Where("product_id = ?", productID).Order("starts_at desc, id desc").Find(&prices).Error
+	return prices, err
+}
+
+// due - products with an entry that started or ended since the last run
+func due(db *gorm.DB, now time.Time) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&models.ProductPrice{}).
+		Where("(applied = ? AND starts_at <= ?) OR (ended = ? AND ends_at <= ?)", false, now, false, now).
+		Distinct().Pluck("product_id", &ids).Error
+	return ids, err
+}
+
+// RunDue - applies every entry that became due.
+func RunDue(db *gorm.DB) error {
+	now := time.Now()
+
+	ids, err := due(db, now)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return Recompute(tx, id, now)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartScheduler - applies scheduled price changes in the background.
+func StartScheduler(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := RunDue(db); err != nil {
+				log.Println("Failed to apply scheduled prices: ", err.Error())
+			}
+			<-ticker.C
+		}
+	}()
+}
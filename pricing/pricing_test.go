@@ -0,0 +1,90 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// priceDB - one product at 20.00
+func priceDB(t *testing.T) (*gorm.DB, models.Product) {
+	t.Helper()
+
+	db := testdb.Open(t, &models.Product{}, &models.ProductPrice{})
+
+	product := models.Product{Name: "Mug", Price: 2000}
+	db.Create(&product)
+	return db, product
+}
+
+// priceOf - the current and compare-at price of the product
+func priceOf(db *gorm.DB, productID uint) (money.Amount, *money.Amount) {
+	product := models.Product{}
+	db.First(&product, productID)
+	return product.Price, product.CompareAtPrice
+}
+
+func TestSetPrice(t *testing.T) {
+	db, product := priceDB(t)
+
+	if err := SetPrice(db, product.ID, 2500, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPrice(db, product.ID, 2200, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if price, compareAt := priceOf(db, product.ID); price != 2200 || compareAt != nil {
+		t.Errorf("price = %v compare at %v, want 22.00 and none", price, compareAt)
+	}
+
+	history, _ := History(db, product.ID)
+	if len(history) != 2 || history[0].Price != 2200 || history[1].Price != 2500 {
+		t.Fatalf("history = %+v, want 22.00 then 25.00", history)
+	}
+	if !history[0].Applied || history[0].ActorID != nil || history[1].ActorID == nil || *history[1].ActorID != 7 {
+		t.Errorf("history = %+v, want applied entries with their actors", history)
+	}
+}
+
+func TestScheduleSale(t *testing.T) {
+	db, product := priceDB(t)
+	now := time.Now()
+	ends := now.Add(time.Hour)
+
+	if _, err := Schedule(db, product, 1500, now, &ends, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The price from before the history is kept as the regular price
+	if price, compareAt := priceOf(db, product.ID); price != 1500 || compareAt == nil || *compareAt != 2000 {
+		t.Errorf("during the sale price = %v compare at %v, want 15.00 and 20.00", price, compareAt)
+	}
+
+	if err := Recompute(db, product.ID, ends.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if price, compareAt := priceOf(db, product.ID); price != 2000 || compareAt != nil {
+		t.Errorf("after the sale price = %v compare at %v, want 20.00 and none", price, compareAt)
+	}
+}
+
+func TestScheduleLater(t *testing.T) {
+	db, product := priceDB(t)
+	starts := time.Now().Add(-time.Second)
+
+	if _, err := Schedule(db, product, 3000, starts.Add(time.Hour), nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if price, _ := priceOf(db, product.ID); price != 2000 {
+		t.Errorf("price = %v before the change is due, want 20.00", price)
+	}
+
+	if _, err := Schedule(db, product, 3000, starts, &starts, 0); err == nil {
+		t.Error("Schedule() accepted a sale ending when it starts")
+	}
+}
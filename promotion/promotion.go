@@ -0,0 +1,232 @@
+package promotion
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// Active - the promotions running at now, in the order they are applied
+func Active(db *gorm.DB, now time.Time) []models.Promotion {
+	var promotions []models.Promotion
+	db.Preload("Targets").Preload("Conditions").
+		Preload("Actions", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("(starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)", now, now).
+		Order("priority, id").Find(&promotions)
+	return promotions
+}
+
+// targets - whether the promotion targets the product
+func targets(promotion models.Promotion, product models.Product) bool {
+	if len(promotion.Targets) == 0 {
+		return true
+	}
+
+	for _, target := range promotion.Targets {
+		if target.ProductID != 0 && target.ProductID == product.ID {
+			return true
+		}
+		if target.Category != "" && strings.EqualFold(target.Category, product.ProductType) {
+			return true
+		}
+	}
+	return false
+}
+
+// holds - whether the targeted items meet every condition of the promotion
+func holds(promotion models.Promotion, order models.Order, items []models.OrderItem, scope []int) bool {
+	var subtotal money.Amount
+	quantity := 0
+	for _, i := range scope {
+		subtotal += items[i].Price
+		quantity += items[i].Quantity
+	}
+
+	for _, condition := range promotion.Conditions {
+		switch condition.Kind {
+		case models.ConditionMinSubtotal:
+			if subtotal < condition.Amount.Convert(order.ExchangeRate) {
+				return false
+			}
+		case models.ConditionMinQuantity:
+			if quantity < condition.Quantity {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// freeUnits - what buy Buy get Get takes off the items: of every Buy plus Get
+// units the Get cheapest are free
+func freeUnits(action models.PromotionAction, items []models.OrderItem, scope []int) map[int]money.Amount {
+	type unit struct {
+		item  int
+		price money.Amount
+	}
+
+	units := []unit{}
+	for _, i := range scope {
+		if items[i].Quantity <= 0 {
+			continue
+		}
+		price := items[i].Price / money.Amount(items[i].Quantity)
+		for n := 0; n < items[i].Quantity; n++ {
+			units = append(units, unit{item: i, price: price})
+		}
+	}
+
+	sort.SliceStable(units, func(a, b int) bool {
+		return units[a].price < units[b].price
+	})
+
+	free := map[int]money.Amount{}
+	if action.Buy+action.Get <= 0 {
+		return free
+	}
+
+	for _, u := range units[:len(units)/(action.Buy+action.Get)*action.Get] {
+		free[u.item] += u.price
+	}
+	return free
+}
+
+// Apply - runs the active promotions on the items, adding their discounts to
+// the items, and returns what each promotion took off each item. The actions
+// of a promotion run in order, each on what the ones before left.
+func Apply(db *gorm.DB, order models.Order, items []models.OrderItem, now time.Time) []models.OrderItemPromotion {
+	products := make([]models.Product, len(items))
+	for i, item := range items {
+		db.Unscoped().First(&products[i], item.ProductID)
+	}
+
+	discounted := make([]bool, len(items))
+	locked := make([]bool, len(items))
+	applied := []models.OrderItemPromotion{}
+
+	for _, promotion := range Active(db, now) {
+		scope := []int{}
+		for i := range items {
+			if locked[i] || (promotion.Exclusive && discounted[i]) || items[i].Price-items[i].Discount <= 0 {
+				continue
+			}
+			if targets(promotion, products[i]) {
+				scope = append(scope, i)
+			}
+		}
+
+		if len(scope) == 0 || !holds(promotion, order, items, scope) {
+			continue
+		}
+
+		discounts := make([]money.Amount, len(items))
+		left := func(i int) money.Amount {
+			return items[i].Price - items[i].Discount - discounts[i]
+		}
+
+		tiered := false
+		for _, action := range promotion.Actions {
+			switch action.Kind {
+			case models.ActionPercentOff:
+				for _, i := range scope {
+					discounts[i] += left(i).Percent(action.Percent)
+				}
+
+			case models.ActionAmountOff:
+				weights := make([]money.Amount, len(scope))
+				var total money.Amount
+				for n, i := range scope {
+					weights[n] = left(i)
+					total += weights[n]
+				}
+
+				amount := action.Amount.Convert(order.ExchangeRate)
+				if amount > total {
+					amount = total
+				}
+				for n, share := range amount.Allocate(weights) {
+					discounts[scope[n]] += share
+				}
+
+			case models.ActionBuyGet:
+				for i, free := range freeUnits(action, items, scope) {
+					if free > left(i) {
+						free = left(i)
+					}
+					discounts[i] += free
+				}
+
+			case models.ActionTier:
+				// All tiers of the promotion are one table, applied once
+				if tiered {
+					continue
+				}
+				tiered = true
+
+				for _, i := range scope {
+					var best *models.PromotionAction
+					for n, tier := range promotion.Actions {
+						if tier.Kind == models.ActionTier && tier.Quantity <= items[i].Quantity &&
+							(best == nil || tier.Quantity > best.Quantity) {
+							best = &promotion.Actions[n]
+						}
+					}
+					if best != nil {
+						discounts[i] += left(i).Percent(best.Percent)
+					}
+				}
+			}
+		}
+
+		for _, i := range scope {
+			if discounts[i] <= 0 {
+				continue
+			}
+
+			items[i].Discount += discounts[i]
+			discounted[i] = true
+			if promotion.Exclusive {
+				locked[i] = true
+			}
+
+			applied = append(applied, models.OrderItemPromotion{
+				OrderItemID: items[i].ID,
+				PromotionID: promotion.ID,
+				Name:        promotion.Name,
+				Discount:    discounts[i],
+			})
+		}
+	}
+	return applied
+}
+
+// Record - replaces the promotions explaining the prices of the items
+func Record(tx *gorm.DB, items []models.OrderItem, applied []models.OrderItemPromotion) error {
+	ids := make([]uint, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	if err := tx.Unscoped().Where("order_item_id IN ?", ids).Delete(&models.OrderItemPromotion{}).Error; err != nil {
+		return err
+	}
+
+	for i := range applied {
+		applied[i].ID = 0
+		if err := tx.Create(&applied[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ItemPromotions - the promotions that discounted an order item
+func ItemPromotions(db *gorm.DB, orderItemID uint) []models.OrderItemPromotion {
+	applied := []models.OrderItemPromotion{}
+	db.Where("order_item_id = ?", orderItemID).Order("id").Find(&applied)
+	return applied
+}
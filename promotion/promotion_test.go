@@ -0,0 +1,200 @@
+package promotion
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+)
+
+func TestApply(t *testing.T) {
+	type item struct {
+		productType string
+		quantity    int
+		price       money.Amount
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	percentOff := func(percent float64) models.PromotionAction {
+		return models.PromotionAction{Kind: models.ActionPercentOff, Percent: percent}
+	}
+
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		rate       float64
+		items      []item
+		// Discount of each item, then how many item discounts were recorded
+		want    []money.Amount
+		applied int
+	}{
+		{
+			name:       "percent off every item",
+			promotions: []models.Promotion{{Actions: []models.PromotionAction{percentOff(10)}}},
+			items:      []item{{"", 1, 1000}, {"", 2, 505}},
+			want:       []money.Amount{100, 51},
+			applied:    2,
+		},
+		{
+			name: "amount off split in proportion",
+			promotions: []models.Promotion{{Actions: []models.PromotionAction{
+				{Kind: models.ActionAmountOff, Amount: 300},
+			}}},
+			items:   []item{{"", 1, 1000}, {"", 1, 2000}},
+			want:    []money.Amount{100, 200},
+			applied: 2,
+		},
+		{
+			name: "amount off converted and capped",
+			promotions: []models.Promotion{{Actions: []models.PromotionAction{
+				{Kind: models.ActionAmountOff, Amount: 10000},
+			}}},
+			rate:    0.5,
+			items:   []item{{"", 1, 1000}},
+			want:    []money.Amount{1000},
+			applied: 1,
+		},
+		{
+			name: "buy two get the cheapest free",
+			promotions: []models.Promotion{{Actions: []models.PromotionAction{
+				{Kind: models.ActionBuyGet, Buy: 2, Get: 1},
+			}}},
+			items:   []item{{"", 2, 1000}, {"", 1, 2000}},
+			want:    []money.Amount{500, 0},
+			applied: 1,
+		},
+		{
+			name: "buy two get one needs three units",
+			promotions: []models.Promotion{{Actions: []models.PromotionAction{
+				{Kind: models.ActionBuyGet, Buy: 2, Get: 1},
+			}}},
+			items: []item{{"", 2, 1000}},
+			want:  []money.Amount{0},
+		},
+		{
+			name: "highest tier reached",
+			promotions: []models.Promotion{{Actions: []models.PromotionAction{
+				{Kind: models.ActionTier, Quantity: 5, Percent: 10},
+				{Kind: models.ActionTier, Quantity: 10, Percent: 20},
+			}}},
+			items:   []item{{"", 10, 10000}, {"", 7, 7000}, {"", 4, 4000}},
+			want:    []money.Amount{2000, 700, 0},
+			applied: 2,
+		},
+		{
+			name: "minimum subtotal not met",
+			promotions: []models.Promotion{{
+				Conditions: []models.PromotionCondition{{Kind: models.ConditionMinSubtotal, Amount: 5000}},
+				Actions:    []models.PromotionAction{percentOff(10)},
+			}},
+			items: []item{{"", 1, 4999}},
+			want:  []money.Amount{0},
+		},
+		{
+			name: "minimum quantity met",
+			promotions: []models.Promotion{{
+				Conditions: []models.PromotionCondition{{Kind: models.ConditionMinQuantity, Quantity: 3}},
+				Actions:    []models.PromotionAction{percentOff(10)},
+			}},
+			items:   []item{{"", 2, 1000}, {"", 1, 1000}},
+			want:    []money.Amount{100, 100},
+			applied: 2,
+		},
+		{
+			name: "only the targeted categories",
+			promotions: []models.Promotion{{
+				Targets: []models.PromotionTarget{{Category: "books"}},
+				Actions: []models.PromotionAction{percentOff(10)},
+			}},
+			items:   []item{{"Books", 1, 1000}, {"toys", 1, 1000}},
+			want:    []money.Amount{100, 0},
+			applied: 1,
+		},
+		{
+			name: "promotions stack by priority",
+			promotions: []models.Promotion{
+				{Priority: 1, Actions: []models.PromotionAction{{Kind: models.ActionAmountOff, Amount: 100}}},
+				{Priority: 0, Actions: []models.PromotionAction{percentOff(50)}},
+			},
+			items:   []item{{"", 1, 1000}},
+			want:    []money.Amount{600},
+			applied: 2,
+		},
+		{
+			name: "exclusive skips discounted items",
+			promotions: []models.Promotion{
+				{Priority: 0, Targets: []models.PromotionTarget{{Category: "books"}}, Actions: []models.PromotionAction{percentOff(10)}},
+				{Priority: 1, Exclusive: true, Actions: []models.PromotionAction{percentOff(50)}},
+			},
+			items:   []item{{"books", 1, 1000}, {"toys", 1, 1000}},
+			want:    []money.Amount{100, 500},
+			applied: 2,
+		},
+		{
+			name: "exclusive locks out later promotions",
+			promotions: []models.Promotion{
+				{Priority: 0, Exclusive: true, Actions: []models.PromotionAction{percentOff(50)}},
+				{Priority: 1, Actions: []models.PromotionAction{percentOff(10)}},
+			},
+			items:   []item{{"", 1, 1000}},
+			want:    []money.Amount{500},
+			applied: 1,
+		},
+		{
+			name: "not running yet",
+			promotions: []models.Promotion{
+				{StartsAt: &later, Actions: []models.PromotionAction{percentOff(10)}},
+			},
+			items: []item{{"", 1, 1000}},
+			want:  []money.Amount{0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := testdb.Open(t, &models.Product{}, &models.Promotion{}, &models.PromotionTarget{},
+				&models.PromotionCondition{}, &models.PromotionAction{})
+
+			for n, promotion := range test.promotions {
+				promotion.Name = fmt.Sprintf("Promotion %d", n)
+				if err := db.Create(&promotion).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			items := make([]models.OrderItem, len(test.items))
+			for i, line := range test.items {
+				product := models.Product{Name: fmt.Sprintf("Product %d", i), ProductType: line.productType}
+				if err := db.Create(&product).Error; err != nil {
+					t.Fatal(err)
+				}
+				items[i] = models.OrderItem{ProductID: int(product.ID), Quantity: line.quantity, Price: line.price}
+			}
+
+			rate := test.rate
+			if rate == 0 {
+				rate = 1
+			}
+			order := models.Order{Currency: "USD", ExchangeRate: rate}
+
+			applied := Apply(db, order, items, now)
+
+			discounts := make([]money.Amount, len(items))
+			for i := range items {
+				discounts[i] = items[i].Discount
+			}
+			if !reflect.DeepEqual(discounts, test.want) {
+				t.Errorf("discounts = %v, want %v", discounts, test.want)
+			}
+			if len(applied) != test.applied {
+				t.Errorf("applied = %d, want %d", len(applied), test.applied)
+			}
+		})
+	}
+}
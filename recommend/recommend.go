@@ -0,0 +1,152 @@
+package recommend
+
+import (
+	"log"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/catalog"
+	"github.com/rama-kairi/fiber-api/config"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// Recommendation sources
+const (
+	SourceOrders   = "orders"
+	SourceCategory = "category"
+)
+
+// Recommendation - a related product and why it was picked
+type Recommendation struct {
+	Product models.Product
+	Score   int
+	Source  string
+}
+
+// pair - how many orders contain both products
+type pair struct {
+	ProductID uint
+	RelatedID uint
+	Score     int
+}
+
+// Compute - rebuilds the related products from the order history, keeping the
+// topN strongest per product among pairs bought together in at least
+// minSupport orders.
+func Compute(db *gorm.DB, topN int, minSupport int) error {
+	var pairs []pair
+	err := db.Raw(`
+		SELECT a.product_id AS product_id, b.product_id AS related_id, COUNT(DISTINCT a.order_id) AS score
+		FROM order_items a
+		JOIN order_items b ON b.order_id = a.order_id AND b.product_id <> a.product_id AND b.deleted_at IS NULL
+		JOIN orders o ON o.id = a.order_id AND o.deleted_at IS NULL
+		WHERE a.deleted_at IS NULL AND a.order_id <> 0
+		GROUP BY a.product_id, b.product_id
+		HAVING COUNT(DISTINCT a.order_id) >= ?
+		ORDER BY a.product_id, score DESC, b.product_id`, minSupport).Scan(&pairs).Error
+	if err != nil {
+		return err
+	}
+
+	related := []models.RelatedProduct{}
+	position := 0
+	for i, p := range pairs {
+		if i == 0 || p.ProductID != pairs[i-1].ProductID {
+			position = 0
+		}
+		position++
+		if position > topN {
+			continue
+		}
+
+		related = append(related, models.RelatedProduct{
+			ProductID: p.ProductID,
+			RelatedID: p.RelatedID,
+			Score:     p.Score,
+			Position:  position,
+		})
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("1 = 1").Delete(&models.RelatedProduct{}).Error; err != nil {
+			return err
+		}
+		if len(related) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(related, 500).Error
+	})
+}
+
+// Related - up to limit products bought together with the product, topped up
+// with products of the same type when the order history has too few.
+func Related(db *gorm.DB, product models.Product, limit int) ([]Recommendation, error) {
+	var related []models.RelatedProduct
+	if err := db.Where("product_id = ?", product.ID).Order("position").Limit(limit).Find(&related).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(related))
+	for i, r := range related {
+		ids[i] = r.RelatedID
+	}
+
+	// Deleted and unpublished products drop out here
+	var products []models.Product
+	if len(ids) > 0 {
+		if err := db.Scopes(catalog.Published).Find(&products, ids).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	byID := map[uint]models.Product{}
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	recommendations := []Recommendation{}
+	exclude := []uint{product.ID}
+	for _, r := range related {
+		if p, ok := byID[r.RelatedID]; ok {
+			recommendations = append(recommendations, Recommendation{Product: p, Score: r.Score, Source: SourceOrders})
+			exclude = append(exclude, p.ID)
+		}
+	}
+
+	if len(recommendations) >= limit || product.ProductType == "" {
+		return recommendations, nil
+	}
+
+	var fallback []models.Product
+	if err := db.Scopes(catalog.Published).Where("product_type = ? AND id NOT IN ?", product.ProductType, exclude).
+		Order("rating_count desc, id").Limit(limit - len(recommendations)).Find(&fallback).Error; err != nil {
+		return nil, err
+	}
+
+	for _, p := range fallback {
+		recommendations = append(recommendations, Recommendation{Product: p, Source: SourceCategory})
+	}
+
+	return recommendations, nil
+}
+
+// StartScheduler - recomputes the related products in the background, right
+// away and then every interval.
+func StartScheduler(db *gorm.DB, cfg config.Recommend) {
+	interval := time.Duration(cfg.IntervalMin) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := Compute(db, cfg.TopN, cfg.MinSupport); err != nil {
+				log.Println("Failed to compute related products: ", err.Error())
+			}
+			<-ticker.C
+		}
+	}()
+}
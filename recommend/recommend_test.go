@@ -0,0 +1,106 @@
+package recommend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// orderDB - mugs 1 to 3 and plate 4, with products 1 and 2 bought together in
+// three orders, 1 and 3 in two and 1 and 4 in one. Products 5 and 6 are cups
+// nobody bought.
+func orderDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := testdb.Open(t, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.RelatedProduct{})
+	for n := 1; n <= 6; n++ {
+		productType := "mug"
+		if n == 4 {
+			productType = "plate"
+		} else if n >= 5 {
+			productType = "cup"
+		}
+		db.Create(&models.Product{Name: fmt.Sprintf("Product %d", n), ProductType: productType})
+	}
+
+	for _, products := range [][]int{{1, 2, 3}, {1, 2, 3}, {1, 2}, {1, 4}} {
+		order := models.Order{UserID: 1}
+		db.Create(&order)
+		for _, productID := range products {
+			db.Create(&models.OrderItem{OrderID: order.ID, ProductID: productID, Quantity: 1})
+		}
+	}
+	return db
+}
+
+// relatedIDs - the products recommended for the product
+func relatedIDs(t *testing.T, db *gorm.DB, productID uint, limit int) []uint {
+	t.Helper()
+
+	product := models.Product{}
+	db.First(&product, productID)
+
+	recommendations, err := Related(db, product, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []uint{}
+	for _, r := range recommendations {
+		ids = append(ids, r.Product.ID)
+	}
+	return ids
+}
+
+func TestCompute(t *testing.T) {
+	db := orderDB(t)
+
+	if err := Compute(db, 2, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Product 4 was bought with 1 only once, below the support
+	if got := relatedIDs(t, db, 1, 5); !reflect.DeepEqual(got, []uint{2, 3}) {
+		t.Errorf("related to 1 = %v, want [2 3]", got)
+	}
+
+	// Only the top pair is kept
+	if err := Compute(db, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	var kept []uint
+	db.Model(&models.RelatedProduct{}).Where("product_id = ?", 1).Pluck("related_id", &kept)
+	if !reflect.DeepEqual(kept, []uint{2}) {
+		t.Errorf("kept for 1 = %v, want [2]", kept)
+	}
+}
+
+func TestRelatedFallback(t *testing.T) {
+	db := orderDB(t)
+
+	if err := Compute(db, 5, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := relatedIDs(t, db, 3, 3); !reflect.DeepEqual(got, []uint{1, 2}) {
+		t.Errorf("related to 3 = %v, want [1 2]", got)
+	}
+
+	// Deleted products drop out
+	db.Delete(&models.Product{}, 1)
+	if got := relatedIDs(t, db, 3, 3); !reflect.DeepEqual(got, []uint{2}) {
+		t.Errorf("related to 3 = %v, want [2]", got)
+	}
+	if got := relatedIDs(t, db, 4, 3); len(got) != 0 {
+		t.Errorf("related to 4 = %v, want none", got)
+	}
+
+	// Products of the same type stand in when nothing was bought together
+	if got := relatedIDs(t, db, 5, 3); !reflect.DeepEqual(got, []uint{6}) {
+		t.Errorf("related to 5 = %v, want [6]", got)
+	}
+}
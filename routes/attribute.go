@@ -0,0 +1,200 @@
+package routes
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/catalog"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// attributeDefinitionInput - the body of the definition endpoints, enum
+// options are sent as a list
+type attributeDefinitionInput struct {
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required"`
+	Unit        string   `json:"unit"`
+	ProductType string   `json:"product_type"`
+	Options     []string `json:"options"`
+}
+
+func AttributeDefinitionResponse(def models.AttributeDefinition) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           def.ID,
+		"code":         def.Code,
+		"name":         def.Name,
+		"type":         def.Type,
+		"required":     def.Required,
+		"unit":         def.Unit,
+		"product_type": def.ProductType,
+		"options":      catalog.Options(def),
+	}
+}
+
+// AttributeErrorResponse - 400 with the message of every invalid attribute
+func AttributeErrorResponse(c *fiber.Ctx, err *catalog.AttributeError) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":      err.Error(),
+		"attributes": err.Errors,
+	})
+}
+
+// applyDefinitionInput - copies the body onto the definition
+func applyDefinitionInput(def *models.AttributeDefinition, input attributeDefinitionInput) {
+	def.Code = strings.TrimSpace(input.Code)
+	def.Name = strings.TrimSpace(input.Name)
+	def.Type = input.Type
+	def.Required = input.Required
+	def.Unit = strings.TrimSpace(input.Unit)
+	def.ProductType = strings.TrimSpace(input.ProductType)
+	def.Options = strings.Join(input.Options, "\n")
+
+	if def.Name == "" {
+		def.Name = def.Code
+	}
+}
+
+// GetAttributeDefinitions - returns the attribute definitions, only those that
+// apply to ?product_type= when it is given
+func GetAttributeDefinitions(c *fiber.Ctx) error {
+	var definitions []models.AttributeDefinition
+
+	query := database.Database.Db.Order("code")
+	if productType := c.Query("product_type"); productType != "" {
+		query = query.Where("product_type = '' OR product_type = ?", productType)
+	}
+	query.Find(&definitions)
+
+	responseDefinitions := make([]map[string]interface{}, len(definitions))
+
+	for i, def := range definitions {
+		responseDefinitions[i] = AttributeDefinitionResponse(def)
+	}
+
+	return c.JSON(responseDefinitions)
+}
+
+// CreateAttributeDefinition - adds a product attribute
+func CreateAttributeDefinition(c *fiber.Ctx) error {
+	input := attributeDefinitionInput{}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	def := models.AttributeDefinition{}
+	applyDefinitionInput(&def, input)
+
+	if err := catalog.ValidateDefinition(def); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := database.Database.Db.Create(&def).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Attribute already exists with code " + def.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(AttributeDefinitionResponse(def))
+}
+
+// UpdateAttributeDefinition - changes a product attribute, as long as the
+// values products already have stay valid
+func UpdateAttributeDefinition(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	def := models.AttributeDefinition{}
+	db.First(&def, c.Params("id"))
+
+	if def.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Attribute not found with id " + c.Params("id"),
+		})
+	}
+
+	previous := def
+	input := attributeDefinitionInput{
+		Code:        def.Code,
+		Name:        def.Name,
+		Type:        def.Type,
+		Required:    def.Required,
+		Unit:        def.Unit,
+		ProductType: def.ProductType,
+		Options:     catalog.Options(def),
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	applyDefinitionInput(&def, input)
+
+	if err := catalog.ValidateDefinition(def); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := catalog.CheckDefinitionChange(db, previous, def); err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := db.Save(&def).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Attribute already exists with code " + def.Code,
+		})
+	}
+
+	return c.JSON(AttributeDefinitionResponse(def))
+}
+
+// DeleteAttributeDefinition - removes a product attribute and its values
+func DeleteAttributeDefinition(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	def := models.AttributeDefinition{}
+	db.First(&def, c.Params("id"))
+
+	if def.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Attribute not found with id " + c.Params("id"),
+		})
+	}
+
+	// Codes are unique, so definitions are removed instead of soft deleted
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("attribute_definition_id = ?", def.ID).Delete(&models.ProductAttribute{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&def).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// productErrorResponse - the response for a failed product write
+func productErrorResponse(c *fiber.Ctx, err error) error {
+	var attributeErr *catalog.AttributeError
+	if errors.As(err, &attributeErr) {
+		return AttributeErrorResponse(c, attributeErr)
+	}
+	return StockErrorResponse(c, err)
+}
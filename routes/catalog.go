@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/catalog"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+)
+
+func ImportJobResponse(job models.ImportJob) map[string]interface{} {
+	rowErrors := make([]map[string]interface{}, len(job.Errors))
+	for i, rowError := range job.Errors {
+		rowErrors[i] = map[string]interface{}{
+			"row":   rowError.Row,
+			"error": rowError.Error,
+		}
+	}
+
+	return map[string]interface{}{
+		"id":         job.ID,
+		"created_at": job.CreatedAt,
+		"updated_at": job.UpdatedAt,
+		"status":     job.Status,
+		"dry_run":    job.DryRun,
+		"file_name":  job.FileName,
+		"rows":       job.Rows,
+		"created":    job.Created,
+		"updated":    job.Updated,
+		"failed":     job.Failed,
+		"message":    job.Message,
+		"errors":     rowErrors,
+	}
+}
+
+// ImportProducts - upserts products from an uploaded CSV file in the background
+func ImportProducts(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	tmp, err := os.CreateTemp("", "product-import-*.csv")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	tmp.Close()
+
+	// The file is either sent as multipart form field "file" or as the raw body
+	fileName := "body.csv"
+	if file, formErr := c.FormFile("file"); formErr == nil {
+		fileName = filepath.Base(file.Filename)
+		err = c.SaveFile(file, tmp.Name())
+	} else if len(c.Body()) > 0 {
+		err = os.WriteFile(tmp.Name(), c.Body(), 0o600)
+	} else {
+		os.Remove(tmp.Name())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "CSV file is required",
+		})
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	job := models.ImportJob{
+		Status:   models.JobPending,
+		DryRun:   c.Query("dry_run") == "true",
+		FileName: fileName,
+		UserID:   utils.CurrentUserID(c),
+	}
+
+	if err := db.Create(&job).Error; err != nil {
+		os.Remove(tmp.Name())
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	go catalog.RunImport(db, job, tmp.Name())
+
+	return c.Status(fiber.StatusAccepted).JSON(ImportJobResponse(job))
+}
+
+// GetImportJob - returns the status of a product import
+func GetImportJob(c *fiber.Ctx) error {
+	var job models.ImportJob
+
+	id := c.Params("id")
+
+	database.Database.Db.Preload("Errors").First(&job, id)
+
+	if job.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Import job not found with id " + id,
+		})
+	}
+
+	return c.JSON(ImportJobResponse(job))
+}
+
+// ExportProducts - streams the product catalog as CSV
+func ExportProducts(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="products.csv"`)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := catalog.ExportCSV(database.Database.Db, w); err != nil {
+			log.Println("Failed to export products: ", err.Error())
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,307 @@
+package routes
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/coupon"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// couponInput - the restrictions of a coupon, left alone on update when absent
+type couponInput struct {
+	ProductIDs *[]uint   `json:"product_ids"`
+	Categories *[]string `json:"categories"`
+}
+
+func CouponResponse(item models.Coupon) map[string]interface{} {
+	productIDs := make([]uint, len(item.Products))
+	for i, restriction := range item.Products {
+		productIDs[i] = restriction.ProductID
+	}
+
+	categories := make([]string, len(item.Categories))
+	for i, restriction := range item.Categories {
+		categories[i] = restriction.Category
+	}
+
+	return map[string]interface{}{
+		"id":                 item.ID,
+		"created_at":         item.CreatedAt,
+		"updated_at":         item.UpdatedAt,
+		"code":               item.Code,
+		"kind":               item.Kind,
+		"percent":            item.Percent,
+		"amount":             item.Amount,
+		"min_order":          item.MinOrder,
+		"starts_at":          item.StartsAt,
+		"ends_at":            item.EndsAt,
+		"usage_limit":        item.UsageLimit,
+		"per_customer_limit": item.PerCustomerLimit,
+		"used_count":         item.UsedCount,
+		"product_ids":        productIDs,
+		"categories":         categories,
+	}
+}
+
+// checkCoupon - returns why the coupon can not be saved, empty when it can
+func checkCoupon(item *models.Coupon) string {
+	item.Code = coupon.Normalize(item.Code)
+
+	switch {
+	case item.Code == "":
+		return "Code is required"
+	case item.Kind == models.CouponPercent && (item.Percent <= 0 || item.Percent > 100):
+		return "Percent must be greater than 0 and at most 100"
+	case item.Kind == models.CouponFixed && item.Amount <= 0:
+		return "Amount must be greater than 0"
+	case item.Kind != models.CouponPercent && item.Kind != models.CouponFixed:
+		return "Kind must be percent or fixed"
+	case item.MinOrder < 0:
+		return "Minimum order can not be negative"
+	case item.UsageLimit < 0 || item.PerCustomerLimit < 0:
+		return "Usage limits can not be negative"
+	case item.StartsAt != nil && item.EndsAt != nil && !item.EndsAt.After(*item.StartsAt):
+		return "ends_at must be after starts_at"
+	}
+
+	return ""
+}
+
+// saveRestrictions - replaces the products and categories of the coupon with
+// the ones given
+func saveRestrictions(tx *gorm.DB, item *models.Coupon, input couponInput) error {
+	if input.ProductIDs != nil {
+		if err := tx.Unscoped().Where("coupon_id = ?", item.ID).Delete(&models.CouponProduct{}).Error; err != nil {
+			return err
+		}
+
+		item.Products = []models.CouponProduct{}
+		for _, productID := range *input.ProductIDs {
+			restriction := models.CouponProduct{CouponID: item.ID, ProductID: productID}
+			if err := tx.Create(&restriction).Error; err != nil {
+				return err
+			}
+			item.Products = append(item.Products, restriction)
+		}
+	}
+
+	if input.Categories != nil {
+		if err := tx.Unscoped().Where("coupon_id = ?", item.ID).Delete(&models.CouponCategory{}).Error; err != nil {
+			return err
+		}
+
+		item.Categories = []models.CouponCategory{}
+		for _, category := range *input.Categories {
+			restriction := models.CouponCategory{CouponID: item.ID, Category: strings.TrimSpace(category)}
+			if err := tx.Create(&restriction).Error; err != nil {
+				return err
+			}
+			item.Categories = append(item.Categories, restriction)
+		}
+	}
+
+	return nil
+}
+
+// checkRestrictions - returns why the restrictions can not be saved, empty
+// when they can
+func checkRestrictions(input couponInput) string {
+	if input.ProductIDs != nil {
+		for _, productID := range *input.ProductIDs {
+			product := models.Product{}
+			database.Database.Db.First(&product, productID)
+
+			if product.ID == 0 {
+				return "Product not found with id " + strconv.Itoa(int(productID))
+			}
+		}
+	}
+
+	if input.Categories != nil {
+		for _, category := range *input.Categories {
+			if strings.TrimSpace(category) == "" {
+				return "Categories can not be empty"
+			}
+		}
+	}
+
+	return ""
+}
+
+// GetCoupons - returns the coupons, newest first
+func GetCoupons(c *fiber.Ctx) error {
+	var coupons []models.Coupon
+
+	database.Database.Db.Preload("Products").Preload("Categories").Order("id desc").Find(&coupons)
+
+	responseCoupons := make([]map[string]interface{}, len(coupons))
+
+	for i, item := range coupons {
+		responseCoupons[i] = CouponResponse(item)
+	}
+
+	return c.JSON(responseCoupons)
+}
+
+// GetCoupon - returns a coupon with its restrictions and uses
+func GetCoupon(c *fiber.Ctx) error {
+	item := models.Coupon{}
+	database.Database.Db.Preload("Products").Preload("Categories").First(&item, c.Params("id"))
+
+	if item.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Coupon not found with id " + c.Params("id"),
+		})
+	}
+
+	return c.JSON(CouponResponse(item))
+}
+
+// CreateCoupon - adds a coupon
+func CreateCoupon(c *fiber.Ctx) error {
+	item := models.Coupon{}
+	inputJson := couponInput{}
+
+	if err := c.BodyParser(&item); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := c.BodyParser(&inputJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	item.ID = 0
+	item.UsedCount = 0
+
+	msg := checkCoupon(&item)
+	if msg == "" {
+		msg = checkRestrictions(inputJson)
+	}
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err := database.Database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Products", "Categories").Create(&item).Error; err != nil {
+			return err
+		}
+		return saveRestrictions(tx, &item, inputJson)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Coupon already exists with code " + item.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(CouponResponse(item))
+}
+
+// UpdateCoupon - changes a coupon, orders that redeemed it keep their discount
+func UpdateCoupon(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	item := models.Coupon{}
+	db.Preload("Products").Preload("Categories").First(&item, c.Params("id"))
+
+	if item.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Coupon not found with id " + c.Params("id"),
+		})
+	}
+
+	id, usedCount := item.ID, item.UsedCount
+	inputJson := couponInput{}
+
+	if err := c.BodyParser(&item); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := c.BodyParser(&inputJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	item.ID, item.UsedCount = id, usedCount
+
+	msg := checkCoupon(&item)
+	if msg == "" {
+		msg = checkRestrictions(inputJson)
+	}
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	// The use count only changes through orders
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("used_count", "Products", "Categories").Save(&item).Error; err != nil {
+			return err
+		}
+		return saveRestrictions(tx, &item, inputJson)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Coupon already exists with code " + item.Code,
+		})
+	}
+
+	db.Preload("Products").Preload("Categories").First(&item, item.ID)
+
+	return c.JSON(CouponResponse(item))
+}
+
+// DeleteCoupon - removes a coupon that was never redeemed. Redeemed coupons
+// are kept for their orders, end them with ends_at instead.
+func DeleteCoupon(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	item := models.Coupon{}
+	db.First(&item, c.Params("id"))
+
+	if item.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Coupon not found with id " + c.Params("id"),
+		})
+	}
+
+	var redemptions int64
+	db.Unscoped().Model(&models.Order{}).Where("coupon_id = ?", item.ID).Count(&redemptions)
+
+	if redemptions > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Coupon was used by " + strconv.FormatInt(redemptions, 10) + " orders, set ends_at to end it",
+		})
+	}
+
+	// Codes are unique, so coupons are removed instead of soft deleted
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("coupon_id = ?", item.ID).Delete(&models.CouponProduct{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("coupon_id = ?", item.ID).Delete(&models.CouponCategory{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&item).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
@@ -0,0 +1,221 @@
+package routes
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/config"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"github.com/rama-kairi/fiber-api/pricing"
+)
+
+func ExchangeRateResponse(rate models.ExchangeRate) map[string]interface{} {
+	return map[string]interface{}{
+		"currency":   rate.Currency,
+		"rate":       rate.Rate,
+		"updated_at": rate.UpdatedAt,
+	}
+}
+
+func ProductCurrencyPriceResponse(price models.ProductCurrencyPrice) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         price.ID,
+		"updated_at": price.UpdatedAt,
+		"product_id": price.ProductID,
+		"currency":   price.Currency,
+		"price":      price.Price,
+	}
+}
+
+// checkCurrency - returns why the currency can not have rates or explicit
+// prices, empty when it can
+func checkCurrency(currency string) string {
+	if currency == config.GetConfig().Currency.Base {
+		return currency + " is the base currency"
+	}
+
+	if !pricing.IsSupported(currency) {
+		return "Unsupported currency " + currency
+	}
+
+	return ""
+}
+
+// GetCurrencies - returns the base currency and the exchange rates
+func GetCurrencies(c *fiber.Ctx) error {
+	var rates []models.ExchangeRate
+
+	database.Database.Db.Order("currency").Find(&rates)
+
+	responseRates := make([]map[string]interface{}, len(rates))
+
+	for i, rate := range rates {
+		responseRates[i] = ExchangeRateResponse(rate)
+	}
+
+	return c.JSON(fiber.Map{
+		"base":      config.GetConfig().Currency.Base,
+		"supported": config.GetConfig().Currency.Supported,
+		"rates":     responseRates,
+	})
+}
+
+// SetExchangeRate - creates or updates the exchange rate of a currency
+func SetExchangeRate(c *fiber.Ctx) error {
+	type rateUpdate struct {
+		Rate float64 `json:"rate"`
+	}
+
+	db := database.Database.Db
+	rateJson := new(rateUpdate)
+
+	currency := strings.ToUpper(c.Params("code"))
+
+	if msg := checkCurrency(currency); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if err := c.BodyParser(&rateJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if rateJson.Rate <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Rate must be greater than 0",
+		})
+	}
+
+	rate := models.ExchangeRate{}
+	db.Where("currency = ?", currency).Limit(1).Find(&rate)
+
+	rate.Currency = currency
+	rate.Rate = rateJson.Rate
+
+	if err := db.Save(&rate).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(ExchangeRateResponse(rate))
+}
+
+// DeleteExchangeRate - removes the exchange rate of a currency
+func DeleteExchangeRate(c *fiber.Ctx) error {
+	currency := strings.ToUpper(c.Params("code"))
+
+	if msg := checkCurrency(currency); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	// Rows are unique per currency, so they are removed instead of soft deleted
+	result := database.Database.Db.Unscoped().Where("currency = ?", currency).Delete(&models.ExchangeRate{})
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Exchange rate not found for " + currency,
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// GetProductCurrencyPrices - returns the explicit prices of a product
+func GetProductCurrencyPrices(c *fiber.Ctx) error {
+	var prices []models.ProductCurrencyPrice
+
+	database.Database.Db.Where("product_id = ?", c.Params("id")).Order("currency").Find(&prices)
+
+	responsePrices := make([]map[string]interface{}, len(prices))
+
+	for i, price := range prices {
+		responsePrices[i] = ProductCurrencyPriceResponse(price)
+	}
+
+	return c.JSON(responsePrices)
+}
+
+// SetProductCurrencyPrice - sets the explicit price of a product in a currency
+func SetProductCurrencyPrice(c *fiber.Ctx) error {
+	type priceUpdate struct {
+		Price money.Amount `json:"price"`
+	}
+
+	db := database.Database.Db
+	priceJson := new(priceUpdate)
+
+	currency := strings.ToUpper(c.Params("code"))
+
+	if msg := checkCurrency(currency); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if err := c.BodyParser(&priceJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if priceJson.Price < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Price can not be negative",
+		})
+	}
+
+	product := models.Product{}
+	db.First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	price := models.ProductCurrencyPrice{}
+	db.Where("product_id = ? AND currency = ?", product.ID, currency).Limit(1).Find(&price)
+
+	price.ProductID = product.ID
+	price.Currency = currency
+	price.Price = priceJson.Price
+
+	if err := db.Save(&price).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(ProductCurrencyPriceResponse(price))
+}
+
+// DeleteProductCurrencyPrice - goes back to converting the base price
+func DeleteProductCurrencyPrice(c *fiber.Ctx) error {
+	currency := strings.ToUpper(c.Params("code"))
+
+	if msg := checkCurrency(currency); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	result := database.Database.Db.Unscoped().
+		Where("product_id = ? AND currency = ?", c.Params("id"), currency).
+		Delete(&models.ProductCurrencyPrice{})
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "No " + currency + " price for product " + c.Params("id"),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
@@ -0,0 +1,270 @@
+package routes
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/digital"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/orderstatus"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"gorm.io/gorm"
+)
+
+func ProductFileResponse(file models.ProductFile) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           file.ID,
+		"created_at":   file.CreatedAt,
+		"product_id":   file.ProductID,
+		"file_name":    file.FileName,
+		"size":         file.Size,
+		"content_type": file.ContentType,
+	}
+}
+
+// DownloadGrantResponse - a grant with a fresh signed link while downloads are left
+func DownloadGrantResponse(c *fiber.Ctx, grant models.DownloadGrant) map[string]interface{} {
+	response := map[string]interface{}{
+		"id":              grant.ID,
+		"order_id":        grant.OrderID,
+		"product_id":      grant.ProductFile.ProductID,
+		"product_file_id": grant.ProductFileID,
+		"file_name":       grant.ProductFile.FileName,
+		"size":            grant.ProductFile.Size,
+		"download_limit":  grant.DownloadLimit,
+		"downloads":       grant.Downloads,
+		"expires_at":      grant.ExpiresAt,
+		"url":             nil,
+		"url_expires_at":  nil,
+	}
+
+	if grant.Downloads < grant.DownloadLimit && time.Now().Before(grant.ExpiresAt) {
+		path, expires := digital.Link(grant)
+		response["url"] = c.BaseURL() + path
+		response["url_expires_at"] = expires
+	}
+
+	return response
+}
+
+// GetProductFiles - returns the files attached to a digital product
+func GetProductFiles(c *fiber.Ctx) error {
+	var files []models.ProductFile
+
+	database.Database.Db.Where("product_id = ?", c.Params("id")).Order("id").Find(&files)
+
+	responseFiles := make([]map[string]interface{}, len(files))
+
+	for i, file := range files {
+		responseFiles[i] = ProductFileResponse(file)
+	}
+
+	return c.JSON(responseFiles)
+}
+
+// UploadProductFile - attaches a file, sent as multipart field "file", to a
+// digital product
+func UploadProductFile(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	product := models.Product{}
+	db.First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	if !product.IsDigital {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Files can only be attached to digital products",
+		})
+	}
+
+	upload, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "File is required",
+		})
+	}
+
+	path, err := digital.FilePath(product.ID, upload.Filename)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o750)
+	}
+	if err == nil {
+		err = c.SaveFile(upload, path)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	file := models.ProductFile{
+		ProductID:   product.ID,
+		FileName:    filepath.Base(upload.Filename),
+		Path:        path,
+		Size:        upload.Size,
+		ContentType: upload.Header.Get(fiber.HeaderContentType),
+	}
+
+	if err := db.Create(&file).Error; err != nil {
+		os.Remove(path)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(ProductFileResponse(file))
+}
+
+// DeleteProductFile - detaches a file from a product. Buyers who were already
+// granted it can no longer download it.
+func DeleteProductFile(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	file := models.ProductFile{}
+	db.Where("product_id = ?", c.Params("id")).First(&file, c.Params("fileId"))
+
+	if file.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found with id " + c.Params("fileId"),
+		})
+	}
+
+	db.Delete(&file)
+	os.Remove(file.Path)
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// MarkOrderPaid - records the payment of an order, giving the buyer access
+// to the files of its digital products and the gift cards it bought
+func MarkOrderPaid(c *fiber.Ctx) error {
+	var order models.Order
+	db := database.Database.Db
+
+	id := c.Params("id")
+
+	db.First(&order, id)
+
+	if order.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order not found with id " + id,
+		})
+	}
+
+	adminID := utils.CurrentUserID(c)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return transitionOrder(tx, &order, models.OrderPaid, &adminID, "")
+	})
+	if err != nil {
+		return StatusErrorResponse(c, err)
+	}
+
+	orderItems := make([]models.OrderItem, len(order.OrderItems))
+	db.Where("order_id = ?", order.ID).Find(&orderItems)
+
+	user := models.User{}
+	db.First(&user, order.UserID)
+
+	return c.JSON(OrderResponse(order, orderItems, user))
+}
+
+// GetOrderDownloads - returns the downloads of a paid order with signed links,
+// to the buyer or an admin
+func GetOrderDownloads(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	order := models.Order{}
+	db.First(&order, c.Params("id"))
+
+	if order.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order not found with id " + c.Params("id"),
+		})
+	}
+
+	user := models.User{}
+	db.First(&user, utils.CurrentUserID(c))
+
+	if uint(order.UserID) != user.ID && !user.IsAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the buyer or an admin can see the downloads of an order",
+		})
+	}
+
+	var grants []models.DownloadGrant
+	db.Joins("ProductFile").Where("order_id = ?", order.ID).Order("download_grants.id").Find(&grants)
+
+	responseGrants := make([]map[string]interface{}, len(grants))
+
+	for i, grant := range grants {
+		responseGrants[i] = DownloadGrantResponse(c, grant)
+	}
+
+	return c.JSON(responseGrants)
+}
+
+// DownloadFile - serves a file through a signed link, counting the download
+// against the order's limit
+func DownloadFile(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	grantID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid download id",
+		})
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !digital.Verify(uint(grantID), expires, c.Query("signature")) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid download link",
+		})
+	}
+
+	if time.Now().Unix() > expires {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error": "Download link has expired",
+		})
+	}
+
+	// Files deleted after the grant are no longer served
+	grant := models.DownloadGrant{}
+	db.Joins("ProductFile").First(&grant, grantID)
+
+	if grant.ID == 0 || grant.ProductFile.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Download not found",
+		})
+	}
+
+	// Refunded and cancelled orders lose their downloads
+	if status := orderStatus(db, grant.OrderID); !orderstatus.Paid(status) {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error": "Order is " + status + ", its downloads are no longer available",
+		})
+	}
+
+	if err := digital.Consume(db, grant, c.IP(), c.Get(fiber.HeaderUserAgent)); err != nil {
+		if errors.Is(err, digital.ErrLimitReached) || errors.Is(err, digital.ErrAccessExpired) {
+			return c.Status(fiber.StatusGone).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Download(grant.ProductFile.Path, grant.ProductFile.FileName)
+}
@@ -0,0 +1,484 @@
+package routes
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/giftcard"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"github.com/rama-kairi/fiber-api/orderstatus"
+	"github.com/rama-kairi/fiber-api/pricing"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"gorm.io/gorm"
+)
+
+func GiftCardResponse(card models.GiftCard) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            card.ID,
+		"created_at":    card.CreatedAt,
+		"updated_at":    card.UpdatedAt,
+		"code":          card.Code,
+		"currency":      card.Currency,
+		"initial":       card.Initial,
+		"balance":       card.Balance,
+		"expires_at":    card.ExpiresAt,
+		"user_id":       card.UserID,
+		"order_item_id": card.OrderItemID,
+		"note":          card.Note,
+	}
+}
+
+func GiftCardTransactionResponse(transaction models.GiftCardTransaction) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         transaction.ID,
+		"created_at": transaction.CreatedAt,
+		"order_id":   transaction.OrderID,
+		"kind":       transaction.Kind,
+		"amount":     transaction.Amount,
+		"balance":    transaction.Balance,
+		"note":       transaction.Note,
+	}
+}
+
+func StoreCreditResponse(entry models.StoreCredit) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         entry.ID,
+		"created_at": entry.CreatedAt,
+		"currency":   entry.Currency,
+		"order_id":   entry.OrderID,
+		"kind":       entry.Kind,
+		"amount":     entry.Amount,
+		"balance":    entry.Balance,
+		"note":       entry.Note,
+	}
+}
+
+// PaymentsResponse - what gift cards and store credit paid of an order, with
+// the codes of the cards masked
+func PaymentsResponse(payments []models.OrderPayment) []map[string]interface{} {
+	responsePayments := make([]map[string]interface{}, len(payments))
+
+	for i, payment := range payments {
+		responsePayments[i] = map[string]interface{}{
+			"method": payment.Method,
+			"amount": payment.Amount,
+		}
+
+		if payment.GiftCardID != nil {
+			card := models.GiftCard{}
+			database.Database.Db.Unscoped().Select("code").First(&card, *payment.GiftCardID)
+			responsePayments[i]["gift_card"] = giftcard.Mask(card.Code)
+		}
+	}
+
+	return responsePayments
+}
+
+// GiftCardErrorResponse - returns why a gift card or store credit change was
+// refused, other errors as 500
+func GiftCardErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, giftcard.ErrBalance) || errors.Is(err, giftcard.ErrRefund) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// balanceCurrency - the currency of a gift card or store credit upper case,
+// the base currency when empty, and why it can not be used
+func balanceCurrency(currency string) (string, string) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return pricing.Base(), ""
+	}
+
+	if currency != pricing.Base() && !pricing.IsSupported(currency) {
+		return currency, "Unsupported currency " + currency
+	}
+	return currency, ""
+}
+
+// GetGiftCards - returns the gift cards, newest first
+func GetGiftCards(c *fiber.Ctx) error {
+	var cards []models.GiftCard
+
+	database.Database.Db.Order("id desc").Find(&cards)
+
+	responseCards := make([]map[string]interface{}, len(cards))
+
+	for i, card := range cards {
+		responseCards[i] = GiftCardResponse(card)
+	}
+
+	return c.JSON(responseCards)
+}
+
+// GetMyGiftCards - returns the gift cards of the current user, the ones bought
+// with their orders and the ones issued to them
+func GetMyGiftCards(c *fiber.Ctx) error {
+	var cards []models.GiftCard
+
+	database.Database.Db.Where("user_id = ?", utils.CurrentUserID(c)).Order("id desc").Find(&cards)
+
+	responseCards := make([]map[string]interface{}, len(cards))
+
+	for i, card := range cards {
+		responseCards[i] = GiftCardResponse(card)
+	}
+
+	return c.JSON(responseCards)
+}
+
+// GetGiftCard - returns a gift card with its transactions
+func GetGiftCard(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	card := models.GiftCard{}
+	db.First(&card, c.Params("id"))
+
+	if card.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Gift card not found with id " + c.Params("id"),
+		})
+	}
+
+	transactions := giftcard.Transactions(db, card.ID)
+	responseTransactions := make([]map[string]interface{}, len(transactions))
+
+	for i, transaction := range transactions {
+		responseTransactions[i] = GiftCardTransactionResponse(transaction)
+	}
+
+	response := GiftCardResponse(card)
+	response["transactions"] = responseTransactions
+
+	return c.JSON(response)
+}
+
+// CheckGiftCard - returns the balance of the gift card with the code, without
+// showing the code back
+func CheckGiftCard(c *fiber.Ctx) error {
+	type giftCardCheck struct {
+		Code string `json:"code"`
+	}
+
+	checkJson := giftCardCheck{}
+	if err := c.BodyParser(&checkJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	card, err := giftcard.Find(database.Database.Db, checkJson.Code)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"code":       giftcard.Mask(card.Code),
+		"currency":   card.Currency,
+		"balance":    card.Balance,
+		"expires_at": card.ExpiresAt,
+		"expired":    card.ExpiresAt != nil && !time.Now().Before(*card.ExpiresAt),
+	})
+}
+
+// IssueGiftCard - issues a gift card worth initial, with a random code unless
+// one is given
+func IssueGiftCard(c *fiber.Ctx) error {
+	card := models.GiftCard{}
+
+	if err := c.BodyParser(&card); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	card.ID = 0
+	card.OrderItemID = nil
+
+	currency, msg := balanceCurrency(card.Currency)
+	card.Currency = currency
+
+	switch {
+	case msg != "":
+	case card.Initial <= 0:
+		msg = "Initial must be greater than 0"
+	case card.Code != "" && len(strings.ReplaceAll(giftcard.Normalize(card.Code), "-", "")) < 8:
+		msg = "Code must have at least 8 letters or digits"
+	case card.UserID != nil:
+		user := models.User{}
+		database.Database.Db.First(&user, *card.UserID)
+
+		if user.ID == 0 {
+			msg = "User not found with id " + strconv.Itoa(int(*card.UserID))
+		}
+	}
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err := database.Database.Db.Transaction(func(tx *gorm.DB) error {
+		return giftcard.Issue(tx, &card)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Gift card already exists with code " + card.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(GiftCardResponse(card))
+}
+
+// UpdateGiftCard - changes when a gift card expires, who it belongs to and its
+// note. Balances only change through transactions.
+func UpdateGiftCard(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	card := models.GiftCard{}
+	db.First(&card, c.Params("id"))
+
+	if card.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Gift card not found with id " + c.Params("id"),
+		})
+	}
+
+	// Fields left out of the body keep their values
+	input := card
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if input.UserID != nil {
+		user := models.User{}
+		db.First(&user, *input.UserID)
+
+		if user.ID == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "User not found with id " + strconv.Itoa(int(*input.UserID)),
+			})
+		}
+	}
+
+	if err := db.Model(&card).Updates(map[string]interface{}{
+		"expires_at": input.ExpiresAt,
+		"user_id":    input.UserID,
+		"note":       input.Note,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	db.First(&card, card.ID)
+
+	return c.JSON(GiftCardResponse(card))
+}
+
+// AdjustGiftCard - adds to or takes from the balance of a gift card
+func AdjustGiftCard(c *fiber.Ctx) error {
+	type giftCardAdjust struct {
+		Amount money.Amount `json:"amount"`
+		Note   string       `json:"note"`
+	}
+
+	db := database.Database.Db
+
+	card := models.GiftCard{}
+	db.First(&card, c.Params("id"))
+
+	if card.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Gift card not found with id " + c.Params("id"),
+		})
+	}
+
+	adjustJson := giftCardAdjust{}
+	if err := c.BodyParser(&adjustJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if adjustJson.Amount == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Amount can not be 0",
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return giftcard.Change(tx, &card, nil, models.CreditAdjust, adjustJson.Amount, adjustJson.Note)
+	})
+	if err != nil {
+		return GiftCardErrorResponse(c, err)
+	}
+
+	db.First(&card, card.ID)
+
+	return c.JSON(GiftCardResponse(card))
+}
+
+// GetStoreCredit - returns the store credit of a user by currency with its
+// history, to the user or an admin
+func GetStoreCredit(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	user := models.User{}
+	db.First(&user, c.Params("id"))
+
+	if user.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found with id " + c.Params("id"),
+		})
+	}
+
+	current := models.User{}
+	db.First(&current, utils.CurrentUserID(c))
+
+	if current.ID != user.ID && !current.IsAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the user or an admin can see their store credit",
+		})
+	}
+
+	history := giftcard.CreditHistory(db, user.ID)
+	responseHistory := make([]map[string]interface{}, len(history))
+
+	for i, entry := range history {
+		responseHistory[i] = StoreCreditResponse(entry)
+	}
+
+	return c.JSON(fiber.Map{
+		"balances": giftcard.CreditBalances(db, user.ID),
+		"history":  responseHistory,
+	})
+}
+
+// AdjustStoreCredit - adds to or takes from the store credit of a user
+func AdjustStoreCredit(c *fiber.Ctx) error {
+	type storeCreditAdjust struct {
+		Amount   money.Amount `json:"amount"`
+		Currency string       `json:"currency"`
+		Note     string       `json:"note"`
+	}
+
+	db := database.Database.Db
+
+	user := models.User{}
+	db.First(&user, c.Params("id"))
+
+	if user.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found with id " + c.Params("id"),
+		})
+	}
+
+	adjustJson := storeCreditAdjust{}
+	if err := c.BodyParser(&adjustJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	currency, msg := balanceCurrency(adjustJson.Currency)
+	if msg == "" && adjustJson.Amount == 0 {
+		msg = "Amount can not be 0"
+	}
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	var entry models.StoreCredit
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		entry, err = giftcard.Credit(tx, user.ID, currency, nil, models.CreditAdjust, adjustJson.Amount, adjustJson.Note)
+		return err
+	})
+	if err != nil {
+		return GiftCardErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(StoreCreditResponse(entry))
+}
+
+// RefundOrder - refunds an order as store credit for its buyer, all that is
+// left to refund without an amount
+func RefundOrder(c *fiber.Ctx) error {
+	type orderRefund struct {
+		Amount money.Amount `json:"amount"`
+		Note   string       `json:"note"`
+	}
+
+	db := database.Database.Db
+
+	order := models.Order{}
+	db.First(&order, c.Params("id"))
+
+	if order.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order not found with id " + c.Params("id"),
+		})
+	}
+
+	refundJson := orderRefund{}
+	if err := c.BodyParser(&refundJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if refundJson.Amount < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Amount can not be negative",
+		})
+	}
+
+	if !orderstatus.Paid(order.Status) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Only paid orders can be refunded, the order is " + order.Status,
+		})
+	}
+
+	adminID := utils.CurrentUserID(c)
+	var entry models.StoreCredit
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		entry, err = giftcard.Refund(tx, order, refundJson.Amount, refundJson.Note)
+		if err != nil {
+			return err
+		}
+
+		// Refunding the last of the order refunds the order
+		if giftcard.Refunded(tx, order.ID) >= order.Price {
+			return transitionOrder(tx, &order, models.OrderRefunded, &adminID, refundJson.Note)
+		}
+		return nil
+	})
+	if err != nil {
+		return StatusErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"refunded": giftcard.Refunded(db, order.ID),
+		"status":   order.Status,
+		"credit":   StoreCreditResponse(entry),
+	})
+}
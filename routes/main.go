@@ -6,8 +6,58 @@ import (
 )
 
 func SetupRoutes(app *fiber.App) {
-	// Middleware
-	api := app.Group("/api")
+	// Middleware, routes that return prices also pick the currency
+	api := app.Group("/api", middleware.Locale)
+
+	// Currencies
+	currency := api.Group("/currencies")
+	currency.Get("/", GetCurrencies)
+	currency.Put("/:code", middleware.IsAuthenticated, middleware.IsAdmin, SetExchangeRate)
+	currency.Delete("/:code", middleware.IsAuthenticated, middleware.IsAdmin, DeleteExchangeRate)
+
+	// Tax rates
+	taxRate := api.Group("/tax-rates")
+	taxRate.Get("/", GetTaxRates)
+	taxRate.Post("/", middleware.IsAuthenticated, middleware.IsAdmin, CreateTaxRate)
+	taxRate.Put("/:id", middleware.IsAuthenticated, middleware.IsAdmin, UpdateTaxRate)
+	taxRate.Delete("/:id", middleware.IsAuthenticated, middleware.IsAdmin, DeleteTaxRate)
+
+	// Coupons
+	coupon := api.Group("/coupons", middleware.IsAuthenticated, middleware.IsAdmin)
+	coupon.Get("/", GetCoupons)
+	coupon.Post("/", CreateCoupon)
+	coupon.Get("/:id", GetCoupon)
+	coupon.Put("/:id", UpdateCoupon)
+	coupon.Delete("/:id", DeleteCoupon)
+
+	// Promotions
+	promotion := api.Group("/promotions", middleware.IsAuthenticated, middleware.IsAdmin)
+	promotion.Get("/", GetPromotions)
+	promotion.Post("/", CreatePromotion)
+	promotion.Get("/:id", GetPromotion)
+	promotion.Put("/:id", UpdatePromotion)
+	promotion.Delete("/:id", DeletePromotion)
+
+	// Shipping
+	shippingRoutes := api.Group("/shipping", middleware.Currency)
+	shippingRoutes.Post("/quote", QuoteShipping)
+	shippingRoutes.Get("/zones", middleware.IsAuthenticated, middleware.IsAdmin, GetShippingZones)
+	shippingRoutes.Post("/zones", middleware.IsAuthenticated, middleware.IsAdmin, CreateShippingZone)
+	shippingRoutes.Put("/zones/:id", middleware.IsAuthenticated, middleware.IsAdmin, UpdateShippingZone)
+	shippingRoutes.Delete("/zones/:id", middleware.IsAuthenticated, middleware.IsAdmin, DeleteShippingZone)
+	shippingRoutes.Post("/methods", middleware.IsAuthenticated, middleware.IsAdmin, CreateShippingMethod)
+	shippingRoutes.Put("/methods/:id", middleware.IsAuthenticated, middleware.IsAdmin, UpdateShippingMethod)
+	shippingRoutes.Delete("/methods/:id", middleware.IsAuthenticated, middleware.IsAdmin, DeleteShippingMethod)
+
+	// Gift cards
+	giftCard := api.Group("/gift-cards", middleware.IsAuthenticated)
+	giftCard.Get("/", middleware.IsAdmin, GetGiftCards)
+	giftCard.Post("/", middleware.IsAdmin, IssueGiftCard)
+	giftCard.Get("/mine", GetMyGiftCards)
+	giftCard.Post("/check", CheckGiftCard)
+	giftCard.Get("/:id", middleware.IsAdmin, GetGiftCard)
+	giftCard.Put("/:id", middleware.IsAdmin, UpdateGiftCard)
+	giftCard.Post("/:id/adjust", middleware.IsAdmin, AdjustGiftCard)
 
 	// Users
 	user := api.Group("/users")
@@ -15,6 +65,8 @@ func SetupRoutes(app *fiber.App) {
 	user.Get("/:id", GetUser)
 	user.Put("/:id", middleware.IsAuthenticated, UpdateUser)
 	user.Delete("/:id", middleware.IsAuthenticated, DeleteUser)
+	user.Get("/:id/store-credit", middleware.IsAuthenticated, GetStoreCredit)
+	user.Post("/:id/store-credit", middleware.IsAuthenticated, middleware.IsAdmin, AdjustStoreCredit)
 
 	// Authentication
 	auth := api.Group("/auth")
@@ -23,14 +75,84 @@ func SetupRoutes(app *fiber.App) {
 	auth.Get("/me", middleware.IsAuthenticated, UserMe)
 	auth.Get("/refresh", middleware.IsAuthenticatedRefresh, Refresh)
 
-	product := api.Group("/products")
-	product.Post("/", middleware.IsAuthenticated, CreateProduct)
-	product.Get("/", GetAllProducts)
-	product.Get("/:id", GetProduct)
-	product.Put("/:id", middleware.IsAuthenticated, UpdateProduct)
-	product.Delete("/:id", middleware.IsAuthenticated, DeleteProduct)
+	// Product attributes
+	attribute := api.Group("/attributes")
+	attribute.Get("/", GetAttributeDefinitions)
+	attribute.Post("/", middleware.IsAuthenticated, middleware.IsAdmin, CreateAttributeDefinition)
+	attribute.Put("/:id", middleware.IsAuthenticated, middleware.IsAdmin, UpdateAttributeDefinition)
+	attribute.Delete("/:id", middleware.IsAuthenticated, middleware.IsAdmin, DeleteAttributeDefinition)
+
+	product := api.Group("/products", middleware.Currency)
+	product.Post("/", middleware.IsAuthenticated, middleware.IsAdmin, CreateProduct)
+	product.Get("/", middleware.OptionalAuthentication, GetAllProducts)
+	product.Get("/low-stock", middleware.IsAuthenticated, middleware.IsAdmin, GetLowStockProducts)
+	product.Post("/import", middleware.IsAuthenticated, middleware.IsAdmin, ImportProducts)
+	product.Get("/import/:id", middleware.IsAuthenticated, middleware.IsAdmin, GetImportJob)
+	product.Get("/export", middleware.IsAuthenticated, middleware.IsAdmin, ExportProducts)
+	product.Get("/sku/:sku", middleware.OptionalAuthentication, GetProductBySKU)
+	product.Get("/:idOrSlug", middleware.OptionalAuthentication, GetProduct)
+	product.Put("/:id", middleware.IsAuthenticated, middleware.IsAdmin, UpdateProduct)
+	product.Delete("/:id", middleware.IsAuthenticated, middleware.IsAdmin, DeleteProduct)
+	product.Get("/:id/stock", middleware.IsAuthenticated, middleware.IsAdmin, GetProductStockLevels)
+	product.Post("/:id/stock", middleware.IsAuthenticated, middleware.IsAdmin, AdjustProductStock)
+	product.Get("/:id/movements", middleware.IsAuthenticated, middleware.IsAdmin, GetProductMovements)
+	product.Get("/:id/prices", GetProductPrices)
+	product.Post("/:id/prices", middleware.IsAuthenticated, middleware.IsAdmin, ScheduleProductPrice)
+	product.Delete("/:id/prices/:priceId", middleware.IsAuthenticated, middleware.IsAdmin, DeleteProductPrice)
+	product.Get("/:id/currency-prices", GetProductCurrencyPrices)
+	product.Put("/:id/currency-prices/:code", middleware.IsAuthenticated, middleware.IsAdmin, SetProductCurrencyPrice)
+	product.Delete("/:id/currency-prices/:code", middleware.IsAuthenticated, middleware.IsAdmin, DeleteProductCurrencyPrice)
+	product.Get("/:id/files", middleware.IsAuthenticated, middleware.IsAdmin, GetProductFiles)
+	product.Post("/:id/files", middleware.IsAuthenticated, middleware.IsAdmin, UploadProductFile)
+	product.Delete("/:id/files/:fileId", middleware.IsAuthenticated, middleware.IsAdmin, DeleteProductFile)
+	product.Get("/:id/translations", middleware.IsAuthenticated, middleware.IsAdmin, GetProductTranslations)
+	product.Put("/:id/translations/:locale", middleware.IsAuthenticated, middleware.IsAdmin, SetProductTranslation)
+	product.Delete("/:id/translations/:locale", middleware.IsAuthenticated, middleware.IsAdmin, DeleteProductTranslation)
+	product.Post("/:id/subscriptions", middleware.OptionalAuthentication, SubscribeToStock)
+	product.Get("/:id/related", middleware.OptionalAuthentication, GetRelatedProducts)
+	product.Get("/:id/reviews", GetProductReviews)
+	product.Post("/:id/reviews", middleware.IsAuthenticated, CreateReview)
+
+	warehouse := api.Group("/warehouses", middleware.IsAuthenticated, middleware.IsAdmin)
+	warehouse.Get("/", GetWarehouses)
+	warehouse.Post("/", CreateWarehouse)
+	warehouse.Put("/:id", UpdateWarehouse)
+	warehouse.Delete("/:id", DeleteWarehouse)
 
-	orderItem := api.Group("/orderitems")
+	transfer := api.Group("/stock-transfers", middleware.IsAuthenticated, middleware.IsAdmin)
+	transfer.Get("/", GetStockTransfers)
+	transfer.Post("/", CreateStockTransfer)
+
+	wishlist := api.Group("/wishlists", middleware.Currency)
+	wishlist.Get("/shared/:token", GetSharedWishlist)
+	wishlist.Get("/", middleware.IsAuthenticated, GetWishlists)
+	wishlist.Post("/", middleware.IsAuthenticated, CreateWishlist)
+	wishlist.Get("/:id", middleware.IsAuthenticated, GetWishlist)
+	wishlist.Put("/:id", middleware.IsAuthenticated, RenameWishlist)
+	wishlist.Delete("/:id", middleware.IsAuthenticated, DeleteWishlist)
+	wishlist.Post("/:id/items", middleware.IsAuthenticated, AddWishlistItem)
+	wishlist.Delete("/:id/items/:productId", middleware.IsAuthenticated, RemoveWishlistItem)
+	wishlist.Post("/:id/items/:productId/cart", middleware.IsAuthenticated, MoveWishlistItemToCart)
+	wishlist.Put("/:id/share", middleware.IsAuthenticated, ShareWishlist)
+	wishlist.Delete("/:id/share", middleware.IsAuthenticated, UnshareWishlist)
+
+	subscription := api.Group("/stock-subscriptions")
+	subscription.Get("/", middleware.IsAuthenticated, GetStockSubscriptions)
+	subscription.Delete("/:token", Unsubscribe)
+
+	category := api.Group("/categories")
+	category.Get("/:type/translations", GetCategoryTranslations)
+	category.Put("/:type/translations/:locale", middleware.IsAuthenticated, middleware.IsAdmin, SetCategoryTranslation)
+	category.Delete("/:type/translations/:locale", middleware.IsAuthenticated, middleware.IsAdmin, DeleteCategoryTranslation)
+
+	review := api.Group("/reviews")
+	review.Get("/pending", middleware.IsAuthenticated, middleware.IsAdmin, GetPendingReviews)
+	review.Put("/:id", middleware.IsAuthenticated, UpdateReview)
+	review.Delete("/:id", middleware.IsAuthenticated, DeleteReview)
+	review.Put("/:id/moderate", middleware.IsAuthenticated, middleware.IsAdmin, ModerateReview)
+	review.Post("/:id/helpful", middleware.IsAuthenticated, VoteReviewHelpful)
+
+	orderItem := api.Group("/orderitems", middleware.Currency)
 	orderItem.Post("/", middleware.IsAuthenticated, CreateOrderItem)
 	orderItem.Get("/", GetAllOrderItems)
 	orderItem.Get("/:id", GetOrderItem)
@@ -38,11 +160,31 @@ func SetupRoutes(app *fiber.App) {
 	orderItem.Get("/order/:id", GetAllOrderItemsByOrderID)
 	orderItem.Delete("/:id", middleware.IsAuthenticated, DeleteOrderItem)
 
-	order := api.Group("/orders")
+	order := api.Group("/orders", middleware.Currency)
 	order.Post("/", middleware.IsAuthenticated, CreateOrder)
 	order.Get("/", GetAllOrders)
 	order.Get("/:id", GetOrderByID)
 	order.Put("/:id", middleware.IsAuthenticated, UpdateOrder)
 	order.Get("/user/:id", GetOrdersByUserID)
+	order.Put("/:id/pay", middleware.IsAuthenticated, middleware.IsAdmin, MarkOrderPaid)
+	order.Put("/:id/deliver", middleware.IsAuthenticated, middleware.IsAdmin, MarkOrderDelivered)
+	order.Put("/:id/status", middleware.IsAuthenticated, UpdateOrderStatus)
+	order.Post("/:id/refund", middleware.IsAuthenticated, middleware.IsAdmin, RefundOrder)
+	order.Get("/:id/downloads", middleware.IsAuthenticated, GetOrderDownloads)
 	order.Delete("/:id", middleware.IsAuthenticated, DeleteOrder)
+
+	// Signed download links, no login needed
+	api.Get("/downloads/:id", DownloadFile)
+
+	// Deleted records
+	trash := api.Group("/trash", middleware.Currency, middleware.IsAuthenticated, middleware.IsAdmin)
+	trash.Get("/products", GetDeletedProducts)
+	trash.Post("/products/:id/restore", RestoreProduct)
+	trash.Delete("/products/:id", PurgeProduct)
+	trash.Get("/users", GetDeletedUsers)
+	trash.Post("/users/:id/restore", RestoreUser)
+	trash.Delete("/users/:id", PurgeUser)
+	trash.Get("/orders", GetDeletedOrders)
+	trash.Post("/orders/:id/restore", RestoreOrder)
+	trash.Delete("/orders/:id", PurgeOrder)
 }
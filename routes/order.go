@@ -1,25 +1,52 @@
 package routes
 
 import (
+	"errors"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/rama-kairi/fiber-api/coupon"
 	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/digital"
+	"github.com/rama-kairi/fiber-api/giftcard"
+	"github.com/rama-kairi/fiber-api/inventory"
 	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"github.com/rama-kairi/fiber-api/orderstatus"
+	"github.com/rama-kairi/fiber-api/pricing"
+	"github.com/rama-kairi/fiber-api/promotion"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"github.com/rama-kairi/fiber-api/shipping"
+	"github.com/rama-kairi/fiber-api/tax"
+	"gorm.io/gorm"
 )
 
 func OrderItemsResponse(orderItem models.OrderItem, product models.Product) map[string]interface{} {
-	return map[string]interface{}{
-		"id":         orderItem.ID,
-		"created_at": orderItem.CreatedAt,
-		"updated_at": orderItem.UpdatedAt,
-		"quantity":   orderItem.Quantity,
-		"price":      orderItem.Price,
-		"product":    product,
-		"product_id": orderItem.ProductID,
-		"order_id":   orderItem.OrderID,
+	response := map[string]interface{}{
+		"id":            orderItem.ID,
+		"created_at":    orderItem.CreatedAt,
+		"updated_at":    orderItem.UpdatedAt,
+		"quantity":      orderItem.Quantity,
+		"price":         orderItem.Price,
+		"currency":      orderItem.Currency,
+		"exchange_rate": orderItem.ExchangeRate,
+		"discount":      orderItem.Discount,
+		"tax":           orderItem.Tax,
+		"tax_rate":      orderItem.TaxRate,
+		"promotions":    PromotionsAppliedResponse(promotion.ItemPromotions(database.Database.Db, orderItem.ID)),
+		"product":       product,
+		"product_id":    orderItem.ProductID,
+		"order_id":      orderItem.OrderID,
+	}
+
+	// Bundles list what one of them is made of
+	if components := inventory.ItemComponents(database.Database.Db, orderItem); len(components) > 0 {
+		response["components"] = components
 	}
+
+	return response
 }
 
 func OrderItemsAllResponse(orderItems []models.OrderItem) []map[string]interface{} {
@@ -34,15 +61,309 @@ func OrderItemsAllResponse(orderItems []models.OrderItem) []map[string]interface
 
 func OrderResponse(order models.Order, OrderItems []models.OrderItem, user models.User) map[string]interface{} {
 	return map[string]interface{}{
-		"id":         order.ID,
-		"created_at": order.CreatedAt,
-		"updated_at": order.UpdatedAt,
-		"quantity":   order.Quantity,
-		"price":      order.Price,
-		"user":       ResponseUser(user),
-		"userID":     order.UserID,
-		"orderItems": OrderItemsAllResponse(OrderItems),
+		"id":                 order.ID,
+		"created_at":         order.CreatedAt,
+		"updated_at":         order.UpdatedAt,
+		"quantity":           order.Quantity,
+		"price":              order.Price,
+		"discount":           order.Discount,
+		"coupon_code":        couponCode(order),
+		"coupon_discount":    order.CouponDiscount,
+		"shipping_method":    order.ShippingMethod,
+		"shipping_method_id": order.ShippingMethodID,
+		"shipping":           order.Shipping,
+		"redeemed":           order.Redeemed,
+		"amount_due":         order.Price - order.Redeemed,
+		"payments":           PaymentsResponse(giftcard.Payments(database.Database.Db, order.ID)),
+		"tax":                order.Tax,
+		"taxes":              TaxBreakdownResponse(tax.OrderBreakdown(database.Database.Db, order.ID)),
+		"currency":           order.Currency,
+		"exchange_rate":      order.ExchangeRate,
+		"status":             order.Status,
+		"paid_at":            order.PaidAt,
+		"delivered_at":       order.DeliveredAt,
+		"region":             order.Region,
+		"country":            order.Country,
+		"allocations":        inventory.Allocations(database.Database.Db, order.ID),
+		"user":               ResponseUser(user),
+		"userID":             order.UserID,
+		"orderItems":         OrderItemsAllResponse(OrderItems),
+	}
+}
+
+// couponCode - the code of the coupon the order redeemed, nil without one
+func couponCode(order models.Order) interface{} {
+	if order.CouponID == nil {
+		return nil
+	}
+
+	applied := models.Coupon{}
+	database.Database.Db.Unscoped().Select("code").First(&applied, *order.CouponID)
+	return applied.Code
+}
+
+// StockErrorResponse - returns 409 listing the short items for a stock error
+func StockErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, ErrItemTaken) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if errors.Is(err, inventory.ErrBundleStock) || errors.Is(err, inventory.ErrSameWarehouse) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var shortage *inventory.ShortageError
+	if errors.As(err, &shortage) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": shortage.Error(),
+			"items": shortage.Shortages,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// ErrItemTaken - an order item was claimed by another order
+var ErrItemTaken = errors.New("Order Item already belongs to an order")
+
+// claimOrderItems - puts the items that belong to no order yet into the order,
+// failing with ErrItemTaken when another order got to one of them first.
+// Items taken out of an order through its association have no order at all.
+func claimOrderItems(tx *gorm.DB, orderID uint, orderItems []models.OrderItem) error {
+	if len(orderItems) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(orderItems))
+	for i, orderItem := range orderItems {
+		ids[i] = orderItem.ID
+	}
+
+	result := tx.Model(&models.OrderItem{}).Where("id IN ? AND (order_id = 0 OR order_id IS NULL)", ids).
+		UpdateColumn("order_id", orderID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(ids)) {
+		return ErrItemTaken
+	}
+	return nil
+}
+
+// orderCurrency - the currency all items of an order share and the rate they
+// were priced at
+func orderCurrency(c *fiber.Ctx, orderItems []models.OrderItem) (string, float64, error) {
+	currency := RequestCurrency(c)
+
+	// The rate the items were priced at, the latest priced item's when the
+	// rate changed in between
+	var rate float64
+	var pricedAt time.Time
+
+	for i, orderItem := range orderItems {
+		itemCurrency := orderItem.Currency
+		if itemCurrency == "" {
+			itemCurrency = pricing.Base()
+		}
+
+		if i == 0 {
+			currency = itemCurrency
+		} else if itemCurrency != currency {
+			return "", 0, errors.New("Order items must all be in the same currency")
+		}
+
+		if orderItem.ExchangeRate > 0 && !orderItem.UpdatedAt.Before(pricedAt) {
+			rate = orderItem.ExchangeRate
+			pricedAt = orderItem.UpdatedAt
+		}
+	}
+
+	if rate > 0 {
+		return currency, rate, nil
+	}
+
+	rate, err := pricing.Rate(database.Database.Db, currency)
+	return currency, rate, err
+}
+
+// orderPricing - the parts of an order's price that are stored apart from it
+type orderPricing struct {
+	taxes      tax.Breakdown
+	promotions []models.OrderItemPromotion
+}
+
+// priceOrder - works out the discount, tax, shipping and total of the order
+// from its items. The running promotions come first, then the coupon when
+// there is one.
+func priceOrder(db *gorm.DB, order *models.Order, orderItems []models.OrderItem, applied *models.Coupon) (orderPricing, error) {
+	var subtotal money.Amount
+	for i := range orderItems {
+		orderItems[i].Discount = 0
+		subtotal += orderItems[i].Price
+	}
+
+	promotions := promotion.Apply(db, *order, orderItems, time.Now())
+
+	order.Discount = 0
+	for _, orderItem := range orderItems {
+		order.Discount += orderItem.Discount
+	}
+
+	order.CouponID = nil
+	order.CouponDiscount = 0
+	if applied != nil {
+		discount, err := coupon.Apply(db, *applied, *order, orderItems)
+		if err != nil {
+			return orderPricing{}, err
+		}
+		order.Discount += discount
+		order.CouponID = &applied.ID
+		order.CouponDiscount = discount
+	}
+
+	breakdown := tax.Compute(db, *order, orderItems)
+
+	order.Tax = breakdown.Total()
+
+	if err := shipping.Apply(db, order, orderItems); err != nil {
+		return orderPricing{}, err
+	}
+
+	order.Price = subtotal - order.Discount + breakdown.Added() + order.Shipping
+	return orderPricing{taxes: breakdown, promotions: promotions}, nil
+}
+
+// recordPricing - stores the discount and tax of the items, the promotions
+// behind their discounts and the tax breakdown of the order
+func recordPricing(tx *gorm.DB, order models.Order, orderItems []models.OrderItem, priced orderPricing) error {
+	for _, orderItem := range orderItems {
+		if err := tx.Model(&models.OrderItem{}).Where("id = ?", orderItem.ID).UpdateColumns(map[string]interface{}{
+			"discount_minor": orderItem.Discount,
+			"tax_minor":      orderItem.Tax,
+			"tax_rate":       orderItem.TaxRate,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := promotion.Record(tx, orderItems, priced.promotions); err != nil {
+		return err
+	}
+
+	return tax.Record(tx, order.ID, priced.taxes)
+}
+
+// orderCoupon - the coupon an order redeemed, nil when it has none
+func orderCoupon(db *gorm.DB, order models.Order) *models.Coupon {
+	if order.CouponID == nil {
+		return nil
+	}
+
+	applied := models.Coupon{}
+	db.Unscoped().Preload("Products").Preload("Categories").First(&applied, *order.CouponID)
+	return &applied
+}
+
+// CouponErrorResponse - returns why a coupon can not be used, other errors are
+// reported like stock errors
+func CouponErrorResponse(c *fiber.Ctx, err error) error {
+	var minimum *coupon.MinimumError
+
+	status := fiber.StatusBadRequest
+	switch {
+	case errors.Is(err, coupon.ErrNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, coupon.ErrUsedUp), errors.Is(err, coupon.ErrCustomerLimit):
+		status = fiber.StatusConflict
+	case errors.Is(err, coupon.ErrNotStarted), errors.Is(err, coupon.ErrExpired),
+		errors.Is(err, coupon.ErrNotApplicable), errors.As(err, &minimum):
+	default:
+		return StockErrorResponse(c, err)
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// PaymentErrorResponse - returns why gift cards or store credit can not pay
+// an order, other errors are reported like pricing errors
+func PaymentErrorResponse(c *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	switch {
+	case errors.Is(err, giftcard.ErrNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, giftcard.ErrBalance):
+		status = fiber.StatusConflict
+	case errors.Is(err, giftcard.ErrExpired), errors.Is(err, giftcard.ErrEmpty), errors.Is(err, giftcard.ErrCurrency):
+	default:
+		return PricingErrorResponse(c, err)
 	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// settleOrder - pays what it can of the order with the gift cards and store
+// credit, an order they pay in full is paid
+func settleOrder(tx *gorm.DB, order *models.Order, cards []models.GiftCard, useCredit bool) error {
+	if err := giftcard.Pay(tx, order, cards, useCredit); err != nil {
+		return err
+	}
+
+	if order.Redeemed > 0 && order.Redeemed >= order.Price {
+		return payOrder(tx, order, nil, "Paid with gift cards and store credit")
+	}
+	return nil
+}
+
+// payOrder - moves the order to paid, giving the buyer the files of its
+// digital products and the gift cards it bought. Both are only handed out
+// once.
+func payOrder(tx *gorm.DB, order *models.Order, userID *uint, note string) error {
+	if err := orderstatus.Move(tx, order, models.OrderPaid, userID, note); err != nil {
+		return err
+	}
+
+	if err := digital.Grant(tx, *order); err != nil {
+		return err
+	}
+	return giftcard.IssuePurchased(tx, *order)
+}
+
+// PricingErrorResponse - returns why an order can not be priced
+func PricingErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, shipping.ErrNoZone) || errors.Is(err, shipping.ErrUnavailable) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return CouponErrorResponse(c, err)
+}
+
+// pricedOrderItem - an order item for the product priced in the currency, not
+// yet saved
+func pricedOrderItem(db *gorm.DB, product models.Product, quantity int, currency string) (models.OrderItem, error) {
+	quote, err := pricing.QuoteProduct(db, product, currency)
+	if err != nil {
+		return models.OrderItem{}, err
+	}
+
+	return models.OrderItem{
+		Quantity:     quantity,
+		ProductID:    int(product.ID),
+		Price:        quote.Price.Times(quantity),
+		Currency:     quote.Currency,
+		ExchangeRate: quote.Rate,
+	}, nil
 }
 
 // CreateOrderItem - add new order item
@@ -64,6 +385,12 @@ func CreateOrderItem(c *fiber.Ctx) error {
 			"error": err.Error(),
 		})
 	}
+	if orderItemJson.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Quantity must be greater than 0",
+		})
+	}
+
 	// Declaring the Product variable
 	product := models.Product{}
 
@@ -79,11 +406,24 @@ func CreateOrderItem(c *fiber.Ctx) error {
 		})
 	}
 
-	// Declaring the Order variable for Creating the OrderItem with custom Price
-	orderItemInstance := models.OrderItem{
-		Quantity:  orderItemJson.Quantity,
-		ProductID: orderItemJson.ProductID,
-		Price:     product.Price * float64(orderItemJson.Quantity),
+	// Only published products can be ordered
+	if product.Status != models.ProductPublished {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Product is not available",
+		})
+	}
+
+	// Pricing the item in the currency of the request
+	orderItemInstance, err := pricedOrderItem(db, product, orderItemJson.Quantity, RequestCurrency(c))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Checking the stock, it is only taken out once the order is placed
+	if err := inventory.Check(db, []models.OrderItem{orderItemInstance}); err != nil {
+		return StockErrorResponse(c, err)
 	}
 
 	// Creating the OrderItem
@@ -179,6 +519,27 @@ func UpdateOrderItem(c *fiber.Ctx) error {
 	orderItem := models.OrderItem{}
 	db.First(&orderItem, id)
 
+	if orderItem.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order Item not found with id " + strconv.Itoa(id),
+		})
+	}
+
+	previous := orderItem
+
+	// Items of an order only change while the order can
+	if status := orderStatus(db, orderItem.OrderID); !orderstatus.Editable(status) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Order can no longer be changed, it is " + status,
+		})
+	}
+
+	if orderItemJson.Quantity < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Quantity must be greater than 0",
+		})
+	}
+
 	if orderItemJson.ProductID != 0 {
 		orderItem.ProductID = orderItemJson.ProductID
 	}
@@ -195,9 +556,47 @@ func UpdateOrderItem(c *fiber.Ctx) error {
 			"error": "Product not found with id " + strconv.Itoa(orderItem.ProductID),
 		})
 	}
-	orderItem.Price = product.Price * float64(orderItem.Quantity)
 
-	db.Save(&orderItem)
+	// Items already ordered keep their product when it is unpublished
+	if orderItem.ProductID != previous.ProductID && product.Status != models.ProductPublished {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Product is not available",
+		})
+	}
+
+	// Repricing in the currency the item was created in
+	quote, err := pricing.QuoteProduct(db, product, orderItem.Currency)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	orderItem.Price = quote.Price.Times(orderItem.Quantity)
+	orderItem.Currency = quote.Currency
+	orderItem.ExchangeRate = quote.Rate
+
+	// Items of a placed order already hold stock, so move it to the new values
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if orderItem.OrderID == 0 {
+			if err := inventory.Check(tx, []models.OrderItem{orderItem}); err != nil {
+				return err
+			}
+		} else {
+			if err := inventory.Restock(tx, []models.OrderItem{previous}, utils.CurrentUserID(c)); err != nil {
+				return err
+			}
+			if err := inventory.Decrement(tx, []models.OrderItem{orderItem}, utils.CurrentUserID(c)); err != nil {
+				return err
+			}
+		}
+
+		return tx.Save(&orderItem).Error
+	})
+	if err != nil {
+		return StockErrorResponse(c, err)
+	}
+
+	inventory.WatchItems([]models.OrderItem{previous, orderItem})
 
 	return c.JSON(OrderItemsResponse(orderItem, product))
 }
@@ -221,7 +620,27 @@ func DeleteOrderItem(c *fiber.Ctx) error {
 		})
 	}
 
-	database.Database.Db.Delete(&orderItem)
+	if status := orderStatus(database.Database.Db, orderItem.OrderID); !orderstatus.Editable(status) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Order can no longer be changed, it is " + status,
+		})
+	}
+
+	// Giving the stock back if the item was part of a placed order
+	err = database.Database.Db.Transaction(func(tx *gorm.DB) error {
+		if orderItem.OrderID != 0 {
+			if err := inventory.Restock(tx, []models.OrderItem{orderItem}, utils.CurrentUserID(c)); err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&orderItem).Error
+	})
+	if err != nil {
+		return StockErrorResponse(c, err)
+	}
+
+	inventory.WatchItems([]models.OrderItem{orderItem})
 
 	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
 }
@@ -231,7 +650,15 @@ func DeleteOrderItem(c *fiber.Ctx) error {
 // CreateOrder - Create Order
 func CreateOrder(c *fiber.Ctx) error {
 	type OrderCreate struct {
-		OrderItemIds []int `json:"order_item_ids"`
+		OrderItemIds []int  `json:"order_item_ids"`
+		Region       string `json:"region"`
+		Country      string `json:"country"`
+		CouponCode   string `json:"coupon_code"`
+		// The cheapest method for the address is taken without one
+		ShippingMethodID *uint `json:"shipping_method_id"`
+		// Paid first, the rest of the order is paid the usual way
+		GiftCardCodes  []string `json:"gift_card_codes"`
+		UseStoreCredit bool     `json:"use_store_credit"`
 	}
 
 	claims := c.Locals("user")
@@ -246,11 +673,25 @@ func CreateOrder(c *fiber.Ctx) error {
 		})
 	}
 
+	country := tax.Country(orderJson.Country)
+	if country != "" && !tax.ValidCountry(country) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Country must be a two letter code",
+		})
+	}
+
 	orderItems_all := make([]models.OrderItem, len(orderJson.OrderItemIds))
-	price := 0.0
 	quantity := len(orderJson.OrderItemIds)
 
+	listed := map[int]bool{}
 	for i, orderItemID := range orderJson.OrderItemIds {
+		if listed[orderItemID] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Order Item " + strconv.Itoa(orderItemID) + " is listed more than once",
+			})
+		}
+		listed[orderItemID] = true
+
 		orderItem := models.OrderItem{}
 		db.First(&orderItem, orderItemID)
 
@@ -260,19 +701,101 @@ func CreateOrder(c *fiber.Ctx) error {
 			})
 		}
 
-		price += orderItem.Price
+		if orderItem.OrderID != 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Order Item " + strconv.Itoa(orderItemID) + " already belongs to an order",
+			})
+		}
 
 		orderItems_all[i] = orderItem
 	}
 
+	currency, rate, err := orderCurrency(c, orderItems_all)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	order := models.Order{
-		Price:    price,
-		Quantity: quantity,
-		UserID:   int(userID),
+		Quantity:     quantity,
+		Currency:     currency,
+		ExchangeRate: rate,
+		Region:       orderJson.Region,
+		Country:      country,
+		UserID:       int(userID),
+		Status:       models.OrderPending,
+
+		ShippingMethodID: orderJson.ShippingMethodID,
+	}
+
+	cards, err := giftcard.FindUsable(db, orderJson.GiftCardCodes, currency, time.Now())
+	if err != nil {
+		return PaymentErrorResponse(c, err)
+	}
+
+	var applied *models.Coupon
+	if orderJson.CouponCode != "" {
+		found, err := coupon.Find(db, orderJson.CouponCode)
+		if err == nil {
+			err = coupon.Check(db, found, uint(userID), time.Now())
+		}
+		if err != nil {
+			return CouponErrorResponse(c, err)
+		}
+		applied = &found
 	}
-	db.Create(&order)
 
-	db.Model(&order).Association("OrderItems").Append(orderItems_all)
+	priced, err := priceOrder(db, &order, orderItems_all, applied)
+	if err != nil {
+		return PricingErrorResponse(c, err)
+	}
+
+	// Taking the items out of stock together with creating the order
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		buyerID := uint(userID)
+		if err := orderstatus.Start(tx, order, &buyerID); err != nil {
+			return err
+		}
+
+		for i := range orderItems_all {
+			orderItems_all[i].OrderID = order.ID
+		}
+
+		if err := inventory.Decrement(tx, orderItems_all, uint(userID)); err != nil {
+			return err
+		}
+
+		// Claiming the items only if no other order took them in the meantime
+		if err := claimOrderItems(tx, order.ID, orderItems_all); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&order).Association("OrderItems").Append(orderItems_all); err != nil {
+			return err
+		}
+
+		if err := recordPricing(tx, order, orderItems_all, priced); err != nil {
+			return err
+		}
+
+		if applied != nil {
+			if err := coupon.Redeem(tx, *applied, order, order.CouponDiscount); err != nil {
+				return err
+			}
+		}
+
+		return settleOrder(tx, &order, cards, orderJson.UseStoreCredit)
+	})
+	if err != nil {
+		return PaymentErrorResponse(c, err)
+	}
+
+	inventory.WatchItems(orderItems_all)
 
 	user := models.User{}
 	database.Database.Db.First(&user, order.UserID)
@@ -323,13 +846,18 @@ func GetOrderByID(c *fiber.Ctx) error {
 	user := models.User{}
 	database.Database.Db.First(&user, order.UserID)
 
-	return c.JSON(OrderResponse(order, orderItems, user))
+	response := OrderResponse(order, orderItems, user)
+	response["status_history"] = OrderStatusHistoryResponse(orderstatus.History(db, order.ID))
+
+	return c.JSON(response)
 }
 
 // UpdateOrder - Update Order
 func UpdateOrder(c *fiber.Ctx) error {
 	type OrderUpdate struct {
 		OrderItemIds []int `json:"order_item_ids"`
+		// The order keeps its shipping method without one
+		ShippingMethodID *uint `json:"shipping_method_id"`
 	}
 
 	db := database.Database.Db
@@ -350,24 +878,141 @@ func UpdateOrder(c *fiber.Ctx) error {
 		})
 	}
 
+	if !orderstatus.Editable(order.Status) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Order can no longer be changed, it is " + order.Status,
+		})
+	}
+
 	orderItems_all := make([]models.OrderItem, len(orderJson.OrderItemIds))
-	price := 0.0
 	quantity := len(orderJson.OrderItemIds)
 
+	// Items joining the order take stock, items leaving it give it back
+	added := []models.OrderItem{}
+	kept := map[uint]bool{}
+
+	listed := map[int]bool{}
 	for i, orderItemID := range orderJson.OrderItemIds {
+		if listed[orderItemID] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Order Item " + strconv.Itoa(orderItemID) + " is listed more than once",
+			})
+		}
+		listed[orderItemID] = true
+
 		orderItem := models.OrderItem{}
 		db.First(&orderItem, orderItemID)
 
-		price += orderItem.Price
+		if orderItem.ID == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Order Item not found with id " + strconv.Itoa(orderItemID),
+			})
+		}
+
+		if orderItem.OrderID != 0 && orderItem.OrderID != order.ID {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Order Item " + strconv.Itoa(orderItemID) + " already belongs to an order",
+			})
+		}
+
+		if orderItem.OrderID == 0 {
+			orderItem.OrderID = order.ID
+			added = append(added, orderItem)
+		}
+		kept[orderItem.ID] = true
+
 		orderItems_all[i] = orderItem
 	}
 
-	order.Price = price
+	var current []models.OrderItem
+	db.Where("order_id = ?", order.ID).Find(&current)
+
+	removed := []models.OrderItem{}
+	for _, orderItem := range current {
+		if !kept[orderItem.ID] {
+			removed = append(removed, orderItem)
+		}
+	}
+
+	currency, rate, err := orderCurrency(c, orderItems_all)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	order.Quantity = quantity
+	order.Currency = currency
+	order.ExchangeRate = rate
+	if orderJson.ShippingMethodID != nil {
+		order.ShippingMethodID = orderJson.ShippingMethodID
+	}
 
-	db.Save(&order)
+	// The coupon stays on the order, it was checked when it was redeemed
+	applied := orderCoupon(db, order)
+	priced, err := priceOrder(db, &order, orderItems_all, applied)
+	if err != nil {
+		return PricingErrorResponse(c, err)
+	}
 
-	db.Model(&order).Association("OrderItems").Replace(orderItems_all)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := inventory.Restock(tx, removed, utils.CurrentUserID(c)); err != nil {
+			return err
+		}
+
+		cards, usedCredit, err := giftcard.Release(tx, &order)
+		if err != nil {
+			return err
+		}
+
+		// Removed items are no longer priced with the order
+		for _, orderItem := range removed {
+			if err := tx.Model(&models.OrderItem{}).Where("id = ?", orderItem.ID).UpdateColumns(map[string]interface{}{
+				"discount_minor": 0,
+				"tax_minor":      0,
+				"tax_rate":       0,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if err := promotion.Record(tx, removed, nil); err != nil {
+			return err
+		}
+
+		if err := claimOrderItems(tx, order.ID, added); err != nil {
+			return err
+		}
+
+		if err := inventory.Decrement(tx, added, utils.CurrentUserID(c)); err != nil {
+			return err
+		}
+
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&order).Association("OrderItems").Replace(orderItems_all); err != nil {
+			return err
+		}
+
+		if err := recordPricing(tx, order, orderItems_all, priced); err != nil {
+			return err
+		}
+
+		if applied != nil {
+			if err := coupon.Redeem(tx, *applied, order, order.CouponDiscount); err != nil {
+				return err
+			}
+		}
+
+		// Paid again the same way as before, up to the new price
+		return settleOrder(tx, &order, cards, usedCredit)
+	})
+	if err != nil {
+		return PaymentErrorResponse(c, err)
+	}
+
+	inventory.WatchItems(append(added, removed...))
 
 	user := models.User{}
 	database.Database.Db.First(&user, order.UserID)
@@ -397,7 +1042,40 @@ func GetOrdersByUserID(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(responseOrders)
 }
 
-// DeleteOrder - Delete Order
+// MarkOrderDelivered - records that the order reached the customer
+func MarkOrderDelivered(c *fiber.Ctx) error {
+	var order models.Order
+	db := database.Database.Db
+
+	id := c.Params("id")
+
+	db.First(&order, id)
+
+	if order.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order not found with id " + id,
+		})
+	}
+
+	adminID := utils.CurrentUserID(c)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return transitionOrder(tx, &order, models.OrderDelivered, &adminID, "")
+	})
+	if err != nil {
+		return StatusErrorResponse(c, err)
+	}
+
+	orderItems := make([]models.OrderItem, len(order.OrderItems))
+	db.Where("order_id = ?", order.ID).Find(&orderItems)
+
+	user := models.User{}
+	db.First(&user, order.UserID)
+
+	return c.JSON(OrderResponse(order, orderItems, user))
+}
+
+// DeleteOrder - Delete Order. Only pending and cancelled orders can be
+// deleted, by their buyer or an admin. Pending orders are cancelled first.
 func DeleteOrder(c *fiber.Ctx) error {
 	var order models.Order
 	db := database.Database.Db
@@ -412,7 +1090,38 @@ func DeleteOrder(c *fiber.Ctx) error {
 		})
 	}
 
-	db.Delete(&order)
+	current := models.User{}
+	db.First(&current, utils.CurrentUserID(c))
+
+	if current.ID != uint(order.UserID) && !current.IsAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the buyer or an admin can delete the order",
+		})
+	}
+
+	if order.Status != models.OrderPending && order.Status != models.OrderCancelled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Only pending and cancelled orders can be deleted, the order is " + order.Status,
+		})
+	}
+
+	var orderItems []models.OrderItem
+	db.Where("order_id = ?", order.ID).Find(&orderItems)
+
+	// Cancelling gives the stock, coupon, gift card balances and store credit
+	// of the order back. Cancelled orders already did.
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := transitionOrder(tx, &order, models.OrderCancelled, &current.ID, "Deleted"); err != nil {
+			return err
+		}
+
+		return tx.Delete(&order).Error
+	})
+	if err != nil {
+		return StatusErrorResponse(c, err)
+	}
+
+	inventory.WatchItems(orderItems)
 
 	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
 }
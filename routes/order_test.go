@@ -0,0 +1,172 @@
+package routes
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// appModels - the tables the route tests need
+var appModels = []interface{}{
+	&models.User{}, &models.Product{}, &models.Order{}, &models.OrderItem{},
+	&models.StockMovement{}, &models.ProductPrice{},
+	&models.ExchangeRate{}, &models.ProductCurrencyPrice{},
+	&models.ProductSlug{}, &models.AttributeDefinition{}, &models.ProductAttribute{},
+	&models.BundleItem{}, &models.OrderItemComponent{},
+	&models.Warehouse{}, &models.WarehouseStock{}, &models.StockAllocation{}, &models.StockTransfer{},
+	&models.TaxRate{}, &models.OrderTax{},
+	&models.Promotion{}, &models.PromotionTarget{}, &models.PromotionCondition{}, &models.PromotionAction{},
+	&models.OrderItemPromotion{},
+	&models.ShippingZone{}, &models.ShippingZoneRegion{}, &models.ShippingMethod{}, &models.ShippingRateTier{},
+	&models.GiftCard{}, &models.GiftCardTransaction{}, &models.StoreCredit{}, &models.OrderPayment{},
+	&models.OrderStatusChange{},
+}
+
+// testApp - an app on an empty database, the requests are made as user 1. The
+// test adds the routes it calls.
+func testApp(t *testing.T) *fiber.App {
+	t.Helper()
+	testdb.Env(t)
+
+	database.Database.Db = testdb.Open(t, appModels...)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", jwt.MapClaims{"user_id": float64(1)})
+		return c.Next()
+	})
+	return app
+}
+
+// orderApp - the order routes
+func orderApp(t *testing.T) *fiber.App {
+	t.Helper()
+
+	app := testApp(t)
+	app.Post("/orders", CreateOrder)
+	app.Put("/orders/:id", UpdateOrder)
+	return app
+}
+
+// send - the status of a JSON request to the app
+func send(t *testing.T, app *fiber.App, method string, path string, body string) int {
+	t.Helper()
+
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := app.Test(request, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode
+}
+
+// ids - an order of the items shipping to the US as JSON, shipUS lets it
+// ship there
+func ids(items ...int) string {
+	listed := make([]string, len(items))
+	for i, item := range items {
+		listed[i] = fmt.Sprint(item)
+	}
+	return `{"country":"US","order_item_ids":[` + strings.Join(listed, ",") + `]}`
+}
+
+// shipUS - a zone shipping anywhere in the US for free
+func shipUS(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	zone := models.ShippingZone{
+		Name:    "United States",
+		Regions: []models.ShippingZoneRegion{{Country: "US"}},
+		Methods: []models.ShippingMethod{{Name: "Standard", Kind: models.ShippingFlat}},
+	}
+	if err := db.Create(&zone).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReorderItems(t *testing.T) {
+	tests := []struct {
+		name string
+		// Items of the first order, what it is updated to and the items of
+		// a second order
+		placed  []int
+		updated []int
+		second  []int
+		want    int
+	}{
+		{"removed item can be ordered again", []int{1, 2}, []int{1}, []int{2}, fiber.StatusCreated},
+		{"all items removed", []int{1, 2}, []int{}, []int{1, 2}, fiber.StatusCreated},
+		{"kept item stays taken", []int{1, 2}, []int{1}, []int{1}, fiber.StatusConflict},
+		{"item of another order", []int{1}, nil, []int{1, 2}, fiber.StatusConflict},
+		{"item listed twice", []int{1}, nil, []int{2, 2}, fiber.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := orderApp(t)
+			db := database.Database.Db
+
+			for n := 1; n <= 2; n++ {
+				product := models.Product{Name: fmt.Sprintf("Product %d", n), Price: 1000, Quantity: 5}
+				db.Create(&product)
+				db.Create(&models.OrderItem{ProductID: int(product.ID), Quantity: 1, Price: 1000})
+			}
+			inventory.SeedWarehouses(db)
+			shipUS(t, db)
+
+			if status := send(t, app, "POST", "/orders", ids(test.placed...)); status != fiber.StatusCreated {
+				t.Fatalf("placing the first order = %d", status)
+			}
+			if test.updated != nil {
+				if status := send(t, app, "PUT", "/orders/1", ids(test.updated...)); status != fiber.StatusOK {
+					t.Fatalf("updating the first order = %d", status)
+				}
+			}
+
+			if status := send(t, app, "POST", "/orders", ids(test.second...)); status != test.want {
+				t.Errorf("placing the second order = %d, want %d", status, test.want)
+			}
+		})
+	}
+}
+
+func TestCreateOrderShipping(t *testing.T) {
+	tests := []struct {
+		name    string
+		digital bool
+		body    string
+		want    int
+	}{
+		{"shipped to a zone", false, `{"country":"US","order_item_ids":[1]}`, fiber.StatusCreated},
+		{"country no zone covers", false, `{"country":"FR","order_item_ids":[1]}`, fiber.StatusBadRequest},
+		{"no country", false, `{"order_item_ids":[1]}`, fiber.StatusBadRequest},
+		{"nothing to ship", true, `{"order_item_ids":[1]}`, fiber.StatusCreated},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := orderApp(t)
+			db := database.Database.Db
+
+			db.Create(&models.Product{Name: "Mug", Price: 1000, Quantity: 5, IsDigital: test.digital})
+			db.Create(&models.OrderItem{ProductID: 1, Quantity: 1, Price: 1000})
+			inventory.SeedWarehouses(db)
+			shipUS(t, db)
+
+			if status := send(t, app, "POST", "/orders", test.body); status != test.want {
+				t.Errorf("placing the order = %d, want %d", status, test.want)
+			}
+		})
+	}
+}
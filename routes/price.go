@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"github.com/rama-kairi/fiber-api/pricing"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"gorm.io/gorm"
+)
+
+func ProductPriceResponse(price models.ProductPrice) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         price.ID,
+		"created_at": price.CreatedAt,
+		"product_id": price.ProductID,
+		"price":      price.Price,
+		"starts_at":  price.StartsAt,
+		"ends_at":    price.EndsAt,
+		"applied":    price.Applied,
+		"ended":      price.Ended,
+		"actor_id":   price.ActorID,
+	}
+}
+
+// GetProductPrices - returns the price history of a product, including the
+// scheduled entries
+func GetProductPrices(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	product := models.Product{}
+	db.First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	prices, err := pricing.History(db, product.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	responsePrices := make([]map[string]interface{}, len(prices))
+
+	for i, price := range prices {
+		responsePrices[i] = ProductPriceResponse(price)
+	}
+
+	return c.JSON(responsePrices)
+}
+
+// ScheduleProductPrice - adds a price entry to a product, applied now or at
+// starts_at. With ends_at it is a temporary sale.
+func ScheduleProductPrice(c *fiber.Ctx) error {
+	type priceSchedule struct {
+		Price    money.Amount `json:"price"`
+		StartsAt *time.Time   `json:"starts_at"`
+		EndsAt   *time.Time   `json:"ends_at"`
+	}
+
+	db := database.Database.Db
+	priceJson := new(priceSchedule)
+
+	if err := c.BodyParser(&priceJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if priceJson.Price < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Price can not be negative",
+		})
+	}
+
+	product := models.Product{}
+	db.First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	startsAt := time.Now()
+	if priceJson.StartsAt != nil {
+		startsAt = *priceJson.StartsAt
+	}
+
+	var entry models.ProductPrice
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		entry, err = pricing.Schedule(tx, product, priceJson.Price, startsAt, priceJson.EndsAt, utils.CurrentUserID(c))
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(ProductPriceResponse(entry))
+}
+
+// DeleteProductPrice - cancels a price entry that has not started yet
+func DeleteProductPrice(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	price := models.ProductPrice{}
+	db.Where("product_id = ?", c.Params("id")).First(&price, c.Params("priceId"))
+
+	if price.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Price not found with id " + c.Params("priceId"),
+		})
+	}
+
+	if price.Applied {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Price has already been applied",
+		})
+	}
+
+	db.Delete(&price)
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
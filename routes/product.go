@@ -1,26 +1,107 @@
 package routes
 
 import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/catalog"
+	"github.com/rama-kairi/fiber-api/config"
 	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/i18n"
+	"github.com/rama-kairi/fiber-api/inventory"
 	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/pricing"
+	"github.com/rama-kairi/fiber-api/recommend"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"github.com/rama-kairi/fiber-api/tax"
+	"gorm.io/gorm"
 )
 
-func ProductResponse(product models.Product) map[string]interface{} {
+// RequestCurrency - the currency picked for the request by middleware.Currency
+func RequestCurrency(c *fiber.Ctx) string {
+	currency, _ := c.Locals("currency").(string)
+	return currency
+}
+
+// productInput - the parts of a product body stored outside the products
+// table: attribute values by code and the components of a bundle
+type productInput struct {
+	Attributes map[string]interface{}    `json:"attributes"`
+	Components *[]catalog.ComponentInput `json:"components"`
+}
+
+// ComponentList - the components sent, none when the key was left out
+func (input productInput) ComponentList() []catalog.ComponentInput {
+	if input.Components == nil {
+		return nil
+	}
+	return *input.Components
+}
+
+func ProductResponse(c *fiber.Ctx, product models.Product) map[string]interface{} {
+	// The stock of a bundle is how many can be put together
+	product.Quantity = inventory.Available(database.Database.Db, product)
+
+	// Content is shown in the locale of the request where it is translated
+	locales := RequestLocales(c)
+	name, description := i18n.Product(database.Database.Db, product, locales)
+
 	response := map[string]interface{}{
-		"id":         product.ID,
-		"created_at": product.CreatedAt,
-		"updated_at": product.UpdatedAt,
-		"name":       product.Name,
-		"price":      product.Price,
-		"quantity":   product.Quantity,
+		"id":                product.ID,
+		"created_at":        product.CreatedAt,
+		"updated_at":        product.UpdatedAt,
+		"name":              name,
+		"description":       description,
+		"locale":            locales[0],
+		"slug":              product.Slug,
+		"sku":               product.SKU,
+		"price":             product.Price,
+		"compare_at_price":  product.CompareAtPrice,
+		"currency":          RequestCurrency(c),
+		"product_type":      product.ProductType,
+		"tax_category":      tax.NormalizeCategory(product.TaxCategory),
+		"category":          i18n.Category(database.Database.Db, product.ProductType, locales),
+		"attributes":        catalog.ProductAttributes(database.Database.Db, product.ID),
+		"quantity":          product.Quantity,
+		"is_bundle":         product.IsBundle,
+		"is_gift_card":      product.IsGiftCard,
+		"weight":            product.Weight,
+		"length":            product.Length,
+		"width":             product.Width,
+		"height":            product.Height,
+		"status":            product.Status,
+		"publish_at":        product.PublishAt,
+		"unpublish_at":      product.UnpublishAt,
+		"reorder_threshold": product.ReorderThreshold,
+		"low_stock":         inventory.IsLowStock(product),
+		"rating_average":    RatingAverage(product),
+		"rating_count":      product.RatingCount,
+	}
+
+	if product.IsBundle {
+		response["components"] = inventory.Components(database.Database.Db, product.ID)
+	} else {
+		response["warehouses"] = inventory.Levels(database.Database.Db, product.ID)
 	}
+
+	// Prices are shown in the currency of the request
+	if quote, err := pricing.QuoteProduct(database.Database.Db, product, RequestCurrency(c)); err == nil {
+		response["price"] = quote.Price
+		response["compare_at_price"] = quote.CompareAtPrice
+		response["currency"] = quote.Currency
+	}
+
 	return response
 }
 
 // CreateProduct creates a new product
 func CreateProduct(c *fiber.Ctx) error {
 	product := models.Product{}
+	inputJson := productInput{}
 
 	if err := c.BodyParser(&product); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -28,49 +109,264 @@ func CreateProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := database.Database.Db.Create(&product).Error; err != nil {
+	if err := c.BodyParser(&inputJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if product.Quantity < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Quantity can not be negative",
+		})
+	}
+
+	if product.IsBundle && product.Quantity != 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Bundle stock comes from its components, quantity must be 0",
+		})
+	}
+
+	if product.Weight < 0 || product.Length < 0 || product.Width < 0 || product.Height < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Weight and dimensions can not be negative",
+		})
+	}
+
+	product.SKU = normalizeSKU(product.SKU)
+	product.TaxCategory = strings.ToLower(strings.TrimSpace(product.TaxCategory))
+
+	// New products stay hidden until they are published
+	if product.Status == "" {
+		product.Status = models.ProductDraft
+	}
+
+	if err := catalog.ValidateStatus(product); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var bundleItems []models.BundleItem
+	if product.IsBundle || inputJson.Components != nil {
+		var err error
+		if bundleItems, err = catalog.ValidateComponents(database.Database.Db, product, inputJson.ComponentList()); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	// The initial stock goes through the ledger like every other change
+	quantity := product.Quantity
+	product.Quantity = 0
+
+	err := database.Database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+
+		if err := pricing.SetPrice(tx, product.ID, product.Price, utils.CurrentUserID(c)); err != nil {
+			return err
+		}
+
+		if err := catalog.AssignSlug(tx, &product); err != nil {
+			return err
+		}
+
+		if err := catalog.SetAttributes(tx, product, inputJson.Attributes); err != nil {
+			return err
+		}
+
+		if bundleItems != nil {
+			if err := catalog.SetComponents(tx, product, bundleItems); err != nil {
+				return err
+			}
+		}
+
+		if quantity == 0 {
+			return nil
+		}
+		return inventory.Adjust(tx, product.ID, quantity, models.MovementRestock, utils.CurrentUserID(c), "Initial stock")
+	})
+	if err != nil {
+		var attributeErr *catalog.AttributeError
+		if errors.As(err, &attributeErr) {
+			return AttributeErrorResponse(c, attributeErr)
+		}
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
+	inventory.Watch(product.ID)
+	catalog.RunSchedule(database.Database.Db, time.Now())
+	database.Database.Db.First(&product, product.ID)
 
-	responseProduct := ProductResponse(product)
+	responseProduct := ProductResponse(c, product)
 
 	return c.Status(fiber.StatusCreated).JSON(responseProduct)
 }
 
-// GetAllProducts returns all products
+// normalizeSKU - trims the sku, an empty one is stored as NULL
+func normalizeSKU(sku *string) *string {
+	if sku == nil {
+		return nil
+	}
+
+	trimmed := strings.TrimSpace(*sku)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// isStaff - whether the request comes from an admin, who can see products
+// that are not published
+func isStaff(c *fiber.Ctx) bool {
+	userID := utils.CurrentUserID(c)
+	if userID == 0 {
+		return false
+	}
+
+	user := models.User{}
+	database.Database.Db.First(&user, userID)
+	return user.IsAdmin
+}
+
+// visible - whether the product can be shown to the request
+func visible(c *fiber.Ctx, product models.Product) bool {
+	return product.Status == models.ProductPublished || isStaff(c)
+}
+
+// GetAllProducts returns the published products, filtered by ?product_type=
+// and by attribute values as ?attr.<code>=value, or ?attr.<code>.min= and
+// ?attr.<code>.max= for number attributes. Staff see every product and can
+// filter by ?status=
 func GetAllProducts(c *fiber.Ctx) error {
 	var products []models.Product
 
-	database.Database.Db.Find(&products)
+	db := database.Database.Db
+	query := db.Model(&models.Product{})
+
+	if !isStaff(c) {
+		query = query.Scopes(catalog.Published)
+	} else if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if productType := c.Query("product_type"); productType != "" {
+		query = query.Where("product_type = ?", productType)
+	}
+
+	filters := map[string]string{}
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		if code := strings.TrimPrefix(string(key), "attr."); code != string(key) {
+			filters[code] = string(value)
+		}
+	})
+
+	query, err := catalog.FilterByAttributes(db, query, filters)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	query.Find(&products)
 
 	responseProducts := make([]map[string]interface{}, len(products))
 
 	for i, product := range products {
-		responseProducts[i] = ProductResponse(product)
+		responseProducts[i] = ProductResponse(c, product)
 	}
 
 	return c.JSON(responseProducts)
 }
 
-// GetProduct returns a product by id
+// GetProduct returns a product by id or slug, old slugs redirect to the current one
 func GetProduct(c *fiber.Ctx) error {
+	// Slugs in other scripts than Latin arrive percent encoded
+	idOrSlug := c.Params("idOrSlug")
+	if decoded, err := url.PathUnescape(idOrSlug); err == nil {
+		idOrSlug = decoded
+	}
+
+	product := catalog.FindProduct(database.Database.Db, idOrSlug)
+
+	if product.ID == 0 || !visible(c, product) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id or slug " + idOrSlug,
+		})
+	}
+
+	if catalog.Slugify(idOrSlug) == idOrSlug && idOrSlug != product.Slug {
+		location := "/api/products/" + url.PathEscape(product.Slug)
+		if query := c.Request().URI().QueryString(); len(query) > 0 {
+			location += "?" + string(query)
+		}
+		return c.Redirect(location, fiber.StatusMovedPermanently)
+	}
+
+	responseProduct := ProductResponse(c, product)
+
+	return c.JSON(responseProduct)
+}
+
+// GetProductBySKU returns a product by sku
+func GetProductBySKU(c *fiber.Ctx) error {
 	var product models.Product
 
-	id := c.Params("id")
+	sku := c.Params("sku")
 
-	database.Database.Db.First(&product, id)
+	database.Database.Db.Where("sku = ?", sku).Limit(1).Find(&product)
 
-	if product.ID == 0 {
+	if product.ID == 0 || !visible(c, product) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found with id " + id,
+			"error": "Product not found with sku " + sku,
 		})
 	}
 
-	responseProduct := ProductResponse(product)
+	return c.JSON(ProductResponse(c, product))
+}
+
+// GetRelatedProducts returns the products often bought together with a
+// product, ?limit= of them (5 by default)
+func GetRelatedProducts(c *fiber.Ctx) error {
+	db := database.Database.Db
 
-	return c.JSON(responseProduct)
+	product := models.Product{}
+	db.First(&product, c.Params("id"))
+
+	if product.ID == 0 || !visible(c, product) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	limit := c.Query("limit", "5")
+	count, err := strconv.Atoi(limit)
+	if err != nil || count < 1 || count > config.GetConfig().Recommend.TopN {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Limit must be between 1 and " + strconv.Itoa(config.GetConfig().Recommend.TopN),
+		})
+	}
+
+	recommendations, err := recommend.Related(db, product, count)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	responseProducts := make([]map[string]interface{}, len(recommendations))
+
+	for i, recommendation := range recommendations {
+		responseProducts[i] = ProductResponse(c, recommendation.Product)
+		responseProducts[i]["related_score"] = recommendation.Score
+		responseProducts[i]["related_source"] = recommendation.Source
+	}
+
+	return c.JSON(responseProducts)
 }
 
 // UpdateProduct updates a product by id
@@ -80,6 +376,12 @@ func UpdateProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	database.Database.Db.First(&product, id)
+	previousQuantity := product.Quantity
+	previousPrice := product.Price
+	previousName := product.Name
+	previousType := product.ProductType
+	previousBundle := product.IsBundle
+	inputJson := productInput{}
 
 	if err := c.BodyParser(&product); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -87,15 +389,103 @@ func UpdateProduct(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := c.BodyParser(&inputJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	if product.ID == 0 {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Product not found with id " + id,
 		})
 	}
 
-	database.Database.Db.Save(&product)
+	product.SKU = normalizeSKU(product.SKU)
+	product.TaxCategory = strings.ToLower(strings.TrimSpace(product.TaxCategory))
+
+	if err := catalog.ValidateStatus(product); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if product.Weight < 0 || product.Length < 0 || product.Width < 0 || product.Height < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Weight and dimensions can not be negative",
+		})
+	}
+
+	if product.IsBundle && !previousBundle && previousQuantity != 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Stock must be 0 before a product becomes a bundle",
+		})
+	}
+
+	// Quantity is never written directly, the difference is applied as an
+	// adjustment so concurrent orders are not overwritten. Price changes go
+	// through the price history. Bundles have no stock of their own, the
+	// quantity sent back for them is the derived one.
+	change := product.Quantity - previousQuantity
+	if product.IsBundle {
+		change = 0
+	}
+
+	var bundleItems []models.BundleItem
+	if inputJson.Components != nil || product.IsBundle != previousBundle {
+		var err error
+		if bundleItems, err = catalog.ValidateComponents(database.Database.Db, product, inputJson.ComponentList()); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	err := database.Database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("quantity", "price_minor", "compare_at_price_minor", "slug").Save(&product).Error; err != nil {
+			return err
+		}
+
+		if product.Name != previousName {
+			if err := catalog.AssignSlug(tx, &product); err != nil {
+				return err
+			}
+		}
+
+		// Attributes are validated again when they are sent or the product
+		// type changes, the others keep their values
+		if inputJson.Attributes != nil || product.ProductType != previousType {
+			if err := catalog.SetAttributes(tx, product, inputJson.Attributes); err != nil {
+				return err
+			}
+		}
+
+		if bundleItems != nil {
+			if err := catalog.SetComponents(tx, product, bundleItems); err != nil {
+				return err
+			}
+		}
+
+		if product.Price != previousPrice {
+			if err := pricing.SetPrice(tx, product.ID, product.Price, utils.CurrentUserID(c)); err != nil {
+				return err
+			}
+		}
+
+		if change == 0 {
+			return nil
+		}
+		return inventory.Adjust(tx, product.ID, change, models.MovementAdjustment, utils.CurrentUserID(c), "Product update")
+	})
+	if err != nil {
+		return productErrorResponse(c, err)
+	}
+
+	inventory.Watch(product.ID)
+	catalog.RunSchedule(database.Database.Db, time.Now())
+	database.Database.Db.First(&product, product.ID)
 
-	responseProduct := ProductResponse(product)
+	responseProduct := ProductResponse(c, product)
 
 	return c.JSON(responseProduct)
 }
@@ -0,0 +1,395 @@
+package routes
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
+	"gorm.io/gorm"
+)
+
+// promotionInput - the rules of a promotion, left alone on update when absent
+type promotionInput struct {
+	Targets    *[]models.PromotionTarget    `json:"targets"`
+	Conditions *[]models.PromotionCondition `json:"conditions"`
+	Actions    *[]models.PromotionAction    `json:"actions"`
+}
+
+func PromotionResponse(item models.Promotion) map[string]interface{} {
+	targets := make([]map[string]interface{}, len(item.Targets))
+	for i, target := range item.Targets {
+		targets[i] = map[string]interface{}{
+			"product_id": target.ProductID,
+			"category":   target.Category,
+		}
+	}
+
+	conditions := make([]map[string]interface{}, len(item.Conditions))
+	for i, condition := range item.Conditions {
+		conditions[i] = map[string]interface{}{
+			"kind":     condition.Kind,
+			"amount":   condition.Amount,
+			"quantity": condition.Quantity,
+		}
+	}
+
+	actions := make([]map[string]interface{}, len(item.Actions))
+	for i, action := range item.Actions {
+		actions[i] = map[string]interface{}{
+			"kind":     action.Kind,
+			"percent":  action.Percent,
+			"amount":   action.Amount,
+			"buy":      action.Buy,
+			"get":      action.Get,
+			"quantity": action.Quantity,
+		}
+	}
+
+	return map[string]interface{}{
+		"id":         item.ID,
+		"created_at": item.CreatedAt,
+		"updated_at": item.UpdatedAt,
+		"name":       item.Name,
+		"priority":   item.Priority,
+		"exclusive":  item.Exclusive,
+		"starts_at":  item.StartsAt,
+		"ends_at":    item.EndsAt,
+		"targets":    targets,
+		"conditions": conditions,
+		"actions":    actions,
+	}
+}
+
+// PromotionsAppliedResponse - the promotions that discounted an order item
+func PromotionsAppliedResponse(applied []models.OrderItemPromotion) []map[string]interface{} {
+	responseApplied := make([]map[string]interface{}, len(applied))
+
+	for i, line := range applied {
+		responseApplied[i] = map[string]interface{}{
+			"promotion_id": line.PromotionID,
+			"name":         line.Name,
+			"discount":     line.Discount,
+		}
+	}
+
+	return responseApplied
+}
+
+// preloadRules - loads the targets, conditions and actions of promotions
+func preloadRules(db *gorm.DB) *gorm.DB {
+	return db.Preload("Targets", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Conditions", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Actions", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+}
+
+// checkPromotion - returns why the promotion can not be saved, empty when it can
+func checkPromotion(item *models.Promotion) string {
+	item.Name = strings.TrimSpace(item.Name)
+
+	switch {
+	case item.Name == "":
+		return "Name is required"
+	case item.StartsAt != nil && item.EndsAt != nil && !item.EndsAt.After(*item.StartsAt):
+		return "ends_at must be after starts_at"
+	}
+
+	return ""
+}
+
+// checkRules - returns why the rules can not be saved, empty when they can
+func checkRules(input promotionInput) string {
+	if input.Targets != nil {
+		for _, target := range *input.Targets {
+			category := strings.TrimSpace(target.Category)
+
+			switch {
+			case (target.ProductID == 0) == (category == ""):
+				return "Targets need either a product_id or a category"
+			case target.ProductID != 0:
+				product := models.Product{}
+				database.Database.Db.First(&product, target.ProductID)
+
+				if product.ID == 0 {
+					return "Product not found with id " + strconv.Itoa(int(target.ProductID))
+				}
+			}
+		}
+	}
+
+	if input.Conditions != nil {
+		for _, condition := range *input.Conditions {
+			switch condition.Kind {
+			case models.ConditionMinSubtotal:
+				if condition.Amount <= 0 {
+					return "min_subtotal conditions need an amount greater than 0"
+				}
+			case models.ConditionMinQuantity:
+				if condition.Quantity <= 0 {
+					return "min_quantity conditions need a quantity greater than 0"
+				}
+			default:
+				return "Condition kind must be min_subtotal or min_quantity"
+			}
+		}
+	}
+
+	if input.Actions != nil {
+		for _, action := range *input.Actions {
+			switch action.Kind {
+			case models.ActionPercentOff:
+				if action.Percent <= 0 || action.Percent > 100 {
+					return "percent_off actions need a percent greater than 0 and at most 100"
+				}
+			case models.ActionAmountOff:
+				if action.Amount <= 0 {
+					return "amount_off actions need an amount greater than 0"
+				}
+			case models.ActionBuyGet:
+				if action.Buy <= 0 || action.Get <= 0 {
+					return "buy_get actions need buy and get greater than 0"
+				}
+			case models.ActionTier:
+				if action.Quantity <= 0 || action.Percent <= 0 || action.Percent > 100 {
+					return "tier actions need a quantity greater than 0 and a percent greater than 0 and at most 100"
+				}
+			default:
+				return "Action kind must be percent_off, amount_off, buy_get or tier"
+			}
+		}
+	}
+
+	return ""
+}
+
+// saveRules - replaces the targets, conditions and actions of the promotion
+// with the ones given
+func saveRules(tx *gorm.DB, item *models.Promotion, input promotionInput) error {
+	if input.Targets != nil {
+		if err := tx.Unscoped().Where("promotion_id = ?", item.ID).Delete(&models.PromotionTarget{}).Error; err != nil {
+			return err
+		}
+
+		item.Targets = []models.PromotionTarget{}
+		for _, target := range *input.Targets {
+			rule := models.PromotionTarget{PromotionID: item.ID, ProductID: target.ProductID, Category: strings.TrimSpace(target.Category)}
+			if err := tx.Create(&rule).Error; err != nil {
+				return err
+			}
+			item.Targets = append(item.Targets, rule)
+		}
+	}
+
+	if input.Conditions != nil {
+		if err := tx.Unscoped().Where("promotion_id = ?", item.ID).Delete(&models.PromotionCondition{}).Error; err != nil {
+			return err
+		}
+
+		item.Conditions = []models.PromotionCondition{}
+		for _, condition := range *input.Conditions {
+			rule := models.PromotionCondition{PromotionID: item.ID, Kind: condition.Kind, Amount: condition.Amount, Quantity: condition.Quantity}
+			if err := tx.Create(&rule).Error; err != nil {
+				return err
+			}
+			item.Conditions = append(item.Conditions, rule)
+		}
+	}
+
+	if input.Actions != nil {
+		if err := tx.Unscoped().Where("promotion_id = ?", item.ID).Delete(&models.PromotionAction{}).Error; err != nil {
+			return err
+		}
+
+		item.Actions = []models.PromotionAction{}
+		for _, action := range *input.Actions {
+			rule := models.PromotionAction{
+				PromotionID: item.ID,
+				Kind:        action.Kind,
+				Percent:     action.Percent,
+				Amount:      action.Amount,
+				Buy:         action.Buy,
+				Get:         action.Get,
+				Quantity:    action.Quantity,
+			}
+			if err := tx.Create(&rule).Error; err != nil {
+				return err
+			}
+			item.Actions = append(item.Actions, rule)
+		}
+	}
+
+	return nil
+}
+
+// GetPromotions - returns the promotions in the order they are applied
+func GetPromotions(c *fiber.Ctx) error {
+	var promotions []models.Promotion
+
+	preloadRules(database.Database.Db).Order("priority, id").Find(&promotions)
+
+	responsePromotions := make([]map[string]interface{}, len(promotions))
+
+	for i, item := range promotions {
+		responsePromotions[i] = PromotionResponse(item)
+	}
+
+	return c.JSON(responsePromotions)
+}
+
+// GetPromotion - returns a promotion with its rules
+func GetPromotion(c *fiber.Ctx) error {
+	item := models.Promotion{}
+	preloadRules(database.Database.Db).First(&item, c.Params("id"))
+
+	if item.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Promotion not found with id " + c.Params("id"),
+		})
+	}
+
+	return c.JSON(PromotionResponse(item))
+}
+
+// CreatePromotion - adds a promotion, it applies to orders priced from then on
+func CreatePromotion(c *fiber.Ctx) error {
+	item := models.Promotion{}
+	inputJson := promotionInput{}
+
+	if err := c.BodyParser(&item); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := c.BodyParser(&inputJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	item.ID = 0
+
+	msg := checkPromotion(&item)
+	if msg == "" {
+		msg = checkRules(inputJson)
+	}
+	if msg == "" && (inputJson.Actions == nil || len(*inputJson.Actions) == 0) {
+		msg = "A promotion needs at least one action"
+	}
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err := database.Database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Targets", "Conditions", "Actions").Create(&item).Error; err != nil {
+			return err
+		}
+		return saveRules(tx, &item, inputJson)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(PromotionResponse(item))
+}
+
+// UpdatePromotion - changes a promotion, priced orders keep their discounts
+// until they are updated
+func UpdatePromotion(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	item := models.Promotion{}
+	db.First(&item, c.Params("id"))
+
+	if item.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Promotion not found with id " + c.Params("id"),
+		})
+	}
+
+	id := item.ID
+	inputJson := promotionInput{}
+
+	if err := c.BodyParser(&item); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := c.BodyParser(&inputJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	item.ID = id
+
+	msg := checkPromotion(&item)
+	if msg == "" {
+		msg = checkRules(inputJson)
+	}
+	if msg == "" && inputJson.Actions != nil && len(*inputJson.Actions) == 0 {
+		msg = "A promotion needs at least one action"
+	}
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Targets", "Conditions", "Actions").Save(&item).Error; err != nil {
+			return err
+		}
+		return saveRules(tx, &item, inputJson)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	preloadRules(db).First(&item, item.ID)
+
+	return c.JSON(PromotionResponse(item))
+}
+
+// DeletePromotion - removes a promotion and its rules. Orders it discounted
+// keep their discounts and its name.
+func DeletePromotion(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	item := models.Promotion{}
+	db.First(&item, c.Params("id"))
+
+	if item.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Promotion not found with id " + c.Params("id"),
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, rule := range []interface{}{
+			&models.PromotionTarget{},
+			&models.PromotionCondition{},
+			&models.PromotionAction{},
+		} {
+			if err := tx.Unscoped().Where("promotion_id = ?", item.ID).Delete(rule).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&item).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
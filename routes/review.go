@@ -0,0 +1,439 @@
+package routes
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"gorm.io/gorm"
+)
+
+// Sort orders for the review listing
+var reviewSorts = map[string]string{
+	"helpful":     "helpful_count desc, created_at desc",
+	"newest":      "created_at desc",
+	"rating_desc": "rating desc, created_at desc",
+	"rating_asc":  "rating asc, created_at desc",
+}
+
+func ReviewResponse(review models.Review) map[string]interface{} {
+	author := review.User.FirstName
+	// The initial is the first letter, which can take more than one byte
+	if lastName := []rune(strings.TrimSpace(review.User.LastName)); len(lastName) > 0 {
+		author += " " + string(lastName[0]) + "."
+	}
+
+	return map[string]interface{}{
+		"id":            review.ID,
+		"created_at":    review.CreatedAt,
+		"updated_at":    review.UpdatedAt,
+		"product_id":    review.ProductID,
+		"user_id":       review.UserID,
+		"author":        author,
+		"rating":        review.Rating,
+		"title":         review.Title,
+		"body":          review.Body,
+		"status":        review.Status,
+		"helpful_count": review.HelpfulCount,
+	}
+}
+
+// RatingAverage - the average of the approved ratings, 0 without any
+func RatingAverage(product models.Product) float64 {
+	if product.RatingCount == 0 {
+		return 0
+	}
+	return float64(product.RatingSum) / float64(product.RatingCount)
+}
+
+// applyRating - moves the rating aggregates of a product when an approved
+// review is added, changed or removed
+func applyRating(tx *gorm.DB, productID uint, count int, sum int) error {
+	if count == 0 && sum == 0 {
+		return nil
+	}
+
+	return tx.Model(&models.Product{}).Where("id = ?", productID).UpdateColumns(map[string]interface{}{
+		"rating_count": gorm.Expr("rating_count + ?", count),
+		"rating_sum":   gorm.Expr("rating_sum + ?", sum),
+	}).Error
+}
+
+// ratingChange - the aggregate change of a review going from one state and
+// rating to another
+func ratingChange(fromStatus string, fromRating int, toStatus string, toRating int) (int, int) {
+	count, sum := 0, 0
+	if fromStatus == models.ReviewApproved {
+		count--
+		sum -= fromRating
+	}
+	if toStatus == models.ReviewApproved {
+		count++
+		sum += toRating
+	}
+	return count, sum
+}
+
+// hasDeliveredProduct - whether the user received an order containing the product
+func hasDeliveredProduct(db *gorm.DB, userID uint, productID uint) bool {
+	var count int64
+	db.Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("orders.user_id = ? AND orders.delivered_at IS NOT NULL AND order_items.product_id = ?", userID, productID).
+		Count(&count)
+	return count > 0
+}
+
+// validateReview - checks the rating and title of a review
+func validateReview(rating int, title string) string {
+	if rating < 1 || rating > 5 {
+		return "Rating must be between 1 and 5"
+	}
+	if len(title) > 128 {
+		return "Title must be less than 128 characters long"
+	}
+	return ""
+}
+
+// CreateReview - reviews a product the user received, pending moderation
+func CreateReview(c *fiber.Ctx) error {
+	type reviewCreate struct {
+		Rating int    `json:"rating"`
+		Title  string `json:"title"`
+		Body   string `json:"body"`
+	}
+
+	db := database.Database.Db
+	reviewJson := new(reviewCreate)
+
+	if err := c.BodyParser(&reviewJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if msg := validateReview(reviewJson.Rating, reviewJson.Title); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	product := models.Product{}
+	db.First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	userID := utils.CurrentUserID(c)
+
+	if !hasDeliveredProduct(db, userID, product.ID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only customers with a delivered order of this product can review it",
+		})
+	}
+
+	review := models.Review{
+		ProductID: product.ID,
+		UserID:    userID,
+		Rating:    reviewJson.Rating,
+		Title:     strings.TrimSpace(reviewJson.Title),
+		Body:      strings.TrimSpace(reviewJson.Body),
+		Status:    models.ReviewPending,
+	}
+
+	var existing int64
+	db.Model(&models.Review{}).Where("product_id = ? AND user_id = ?", product.ID, userID).Count(&existing)
+
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "You already reviewed this product",
+		})
+	}
+
+	if err := db.Create(&review).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	db.Preload("User").First(&review, review.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(ReviewResponse(review))
+}
+
+// GetProductReviews - lists the approved reviews of a product, paginated
+func GetProductReviews(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	sort, ok := reviewSorts[c.Query("sort", "helpful")]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Sort must be one of helpful, newest, rating_desc or rating_asc",
+		})
+	}
+
+	query := db.Model(&models.Review{}).Where("product_id = ? AND status = ?", c.Params("id"), models.ReviewApproved)
+
+	var total int64
+	query.Count(&total)
+
+	var reviews []models.Review
+	query.Preload("User").Order(sort).Offset((page - 1) * limit).Limit(limit).Find(&reviews)
+
+	responseReviews := make([]map[string]interface{}, len(reviews))
+
+	for i, review := range reviews {
+		responseReviews[i] = ReviewResponse(review)
+	}
+
+	return c.JSON(fiber.Map{
+		"items": responseReviews,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
+
+// GetPendingReviews - lists the reviews waiting for moderation, oldest first
+func GetPendingReviews(c *fiber.Ctx) error {
+	var reviews []models.Review
+
+	database.Database.Db.Preload("User").Where("status = ?", models.ReviewPending).Order("created_at").Find(&reviews)
+
+	responseReviews := make([]map[string]interface{}, len(reviews))
+
+	for i, review := range reviews {
+		responseReviews[i] = ReviewResponse(review)
+	}
+
+	return c.JSON(responseReviews)
+}
+
+// UpdateReview - lets the author edit a review, which goes back to moderation
+func UpdateReview(c *fiber.Ctx) error {
+	type reviewUpdate struct {
+		Rating int    `json:"rating"`
+		Title  string `json:"title"`
+		Body   string `json:"body"`
+	}
+
+	db := database.Database.Db
+	reviewJson := new(reviewUpdate)
+
+	if err := c.BodyParser(&reviewJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	review := models.Review{}
+	db.First(&review, c.Params("id"))
+
+	if review.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Review not found with id " + c.Params("id"),
+		})
+	}
+
+	if review.UserID != utils.CurrentUserID(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the author can edit a review",
+		})
+	}
+
+	previousStatus, previousRating := review.Status, review.Rating
+
+	if reviewJson.Rating != 0 {
+		review.Rating = reviewJson.Rating
+	}
+	if reviewJson.Title != "" {
+		review.Title = strings.TrimSpace(reviewJson.Title)
+	}
+	if reviewJson.Body != "" {
+		review.Body = strings.TrimSpace(reviewJson.Body)
+	}
+	review.Status = models.ReviewPending
+
+	if msg := validateReview(review.Rating, review.Title); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&review).Error; err != nil {
+			return err
+		}
+
+		count, sum := ratingChange(previousStatus, previousRating, review.Status, review.Rating)
+		return applyRating(tx, review.ProductID, count, sum)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	db.Preload("User").First(&review, review.ID)
+
+	return c.JSON(ReviewResponse(review))
+}
+
+// ModerateReview - approves or rejects a review
+func ModerateReview(c *fiber.Ctx) error {
+	type reviewModerate struct {
+		Status string `json:"status"`
+	}
+
+	db := database.Database.Db
+	moderateJson := new(reviewModerate)
+
+	if err := c.BodyParser(&moderateJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	switch moderateJson.Status {
+	case models.ReviewPending, models.ReviewApproved, models.ReviewRejected:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Status must be one of pending, approved or rejected",
+		})
+	}
+
+	review := models.Review{}
+	db.First(&review, c.Params("id"))
+
+	if review.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Review not found with id " + c.Params("id"),
+		})
+	}
+
+	// Only moves the aggregates when the status really changes, so repeated
+	// requests do not count a review twice
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Review{}).
+			Where("id = ? AND status = ?", review.ID, review.Status).
+			Update("status", moderateJson.Status)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		count, sum := ratingChange(review.Status, review.Rating, moderateJson.Status, review.Rating)
+		return applyRating(tx, review.ProductID, count, sum)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	db.Preload("User").First(&review, review.ID)
+
+	return c.JSON(ReviewResponse(review))
+}
+
+// DeleteReview - removes a review, by its author or an admin
+func DeleteReview(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	review := models.Review{}
+	db.First(&review, c.Params("id"))
+
+	if review.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Review not found with id " + c.Params("id"),
+		})
+	}
+
+	user := models.User{}
+	db.First(&user, utils.CurrentUserID(c))
+
+	if review.UserID != user.ID && !user.IsAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the author or an admin can delete a review",
+		})
+	}
+
+	// Hard deleted so the author can review the product again
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("review_id = ?", review.ID).Delete(&models.ReviewVote{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(&review).Error; err != nil {
+			return err
+		}
+
+		count, sum := ratingChange(review.Status, review.Rating, "", 0)
+		return applyRating(tx, review.ProductID, count, sum)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// VoteReviewHelpful - marks a review as helpful, once per user
+func VoteReviewHelpful(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	review := models.Review{}
+	db.Where("status = ?", models.ReviewApproved).First(&review, c.Params("id"))
+
+	if review.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Review not found with id " + c.Params("id"),
+		})
+	}
+
+	userID := utils.CurrentUserID(c)
+
+	var voted int64
+	db.Model(&models.ReviewVote{}).Where("review_id = ? AND user_id = ?", review.ID, userID).Count(&voted)
+
+	if voted > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "You already voted for this review",
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.ReviewVote{ReviewID: review.ID, UserID: userID}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Review{}).Where("id = ?", review.ID).
+			UpdateColumn("helpful_count", gorm.Expr("helpful_count + 1")).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	db.Preload("User").First(&review, review.ID)
+
+	return c.JSON(ReviewResponse(review))
+}
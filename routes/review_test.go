@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+)
+
+func TestRatingAggregates(t *testing.T) {
+	db := testdb.Open(t, &models.Product{})
+	product := models.Product{Name: "Mug"}
+	db.Create(&product)
+
+	// A review's life, with the average after every step
+	steps := []struct {
+		name       string
+		fromStatus string
+		fromRating int
+		toStatus   string
+		toRating   int
+		average    float64
+	}{
+		{"submitted", "", 0, models.ReviewPending, 4, 0},
+		{"approved", models.ReviewPending, 4, models.ReviewApproved, 4, 4},
+		{"second approved", "", 0, models.ReviewApproved, 2, 3},
+		{"edited back to moderation", models.ReviewApproved, 2, models.ReviewPending, 5, 4},
+		{"approved after the edit", models.ReviewPending, 5, models.ReviewApproved, 5, 4.5},
+		{"rejected", models.ReviewApproved, 4, models.ReviewRejected, 4, 5},
+		{"deleted", models.ReviewApproved, 5, "", 0, 0},
+	}
+
+	for _, step := range steps {
+		count, sum := ratingChange(step.fromStatus, step.fromRating, step.toStatus, step.toRating)
+		if err := applyRating(db, product.ID, count, sum); err != nil {
+			t.Fatal(err)
+		}
+
+		db.First(&product, product.ID)
+		if got := RatingAverage(product); got != step.average {
+			t.Errorf("%s: average = %v, want %v", step.name, got, step.average)
+		}
+	}
+}
@@ -0,0 +1,550 @@
+package routes
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/pricing"
+	"github.com/rama-kairi/fiber-api/promotion"
+	"github.com/rama-kairi/fiber-api/shipping"
+	"github.com/rama-kairi/fiber-api/tax"
+	"gorm.io/gorm"
+)
+
+// zoneInput - the places of a zone, left alone on update when absent
+type zoneInput struct {
+	Regions *[]models.ShippingZoneRegion `json:"regions"`
+}
+
+// methodInput - the tiers of a method, left alone on update when absent
+type methodInput struct {
+	Tiers *[]models.ShippingRateTier `json:"tiers"`
+}
+
+func ShippingMethodResponse(method models.ShippingMethod) map[string]interface{} {
+	tiers := make([]map[string]interface{}, len(method.Tiers))
+	for i, tier := range method.Tiers {
+		tiers[i] = map[string]interface{}{
+			"min_weight":   tier.MinWeight,
+			"min_subtotal": tier.MinSubtotal,
+			"cost":         tier.Cost,
+		}
+	}
+
+	return map[string]interface{}{
+		"id":         method.ID,
+		"updated_at": method.UpdatedAt,
+		"zone_id":    method.ZoneID,
+		"name":       method.Name,
+		"kind":       method.Kind,
+		"amount":     method.Amount,
+		"threshold":  method.Threshold,
+		"tiers":      tiers,
+	}
+}
+
+func ShippingZoneResponse(zone models.ShippingZone) map[string]interface{} {
+	regions := make([]map[string]interface{}, len(zone.Regions))
+	for i, place := range zone.Regions {
+		regions[i] = map[string]interface{}{
+			"country": place.Country,
+			"region":  place.Region,
+		}
+	}
+
+	methods := make([]map[string]interface{}, len(zone.Methods))
+	for i, method := range zone.Methods {
+		methods[i] = ShippingMethodResponse(method)
+	}
+
+	return map[string]interface{}{
+		"id":         zone.ID,
+		"created_at": zone.CreatedAt,
+		"updated_at": zone.UpdatedAt,
+		"name":       zone.Name,
+		"regions":    regions,
+		"methods":    methods,
+	}
+}
+
+// preloadZone - loads the places and methods of zones
+func preloadZone(db *gorm.DB) *gorm.DB {
+	return db.Preload("Regions", func(db *gorm.DB) *gorm.DB { return db.Order("country, region") }).
+		Preload("Methods", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Methods.Tiers", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+}
+
+// checkZone - returns why the zone can not be saved, empty when it can
+func checkZone(zone *models.ShippingZone, input zoneInput) string {
+	zone.Name = strings.TrimSpace(zone.Name)
+	if zone.Name == "" {
+		return "Name is required"
+	}
+
+	if input.Regions != nil {
+		for i, place := range *input.Regions {
+			place.Country = strings.ToUpper(strings.TrimSpace(place.Country))
+			place.Region = shipping.NormalizeRegion(place.Region)
+
+			if !tax.ValidCountry(place.Country) {
+				return "Country must be a two letter code"
+			}
+			(*input.Regions)[i] = place
+		}
+	}
+
+	return ""
+}
+
+// saveRegions - replaces the places of the zone with the ones given
+func saveRegions(tx *gorm.DB, zone *models.ShippingZone, input zoneInput) error {
+	if input.Regions == nil {
+		return nil
+	}
+
+	if err := tx.Unscoped().Where("zone_id = ?", zone.ID).Delete(&models.ShippingZoneRegion{}).Error; err != nil {
+		return err
+	}
+
+	zone.Regions = []models.ShippingZoneRegion{}
+	for _, place := range *input.Regions {
+		region := models.ShippingZoneRegion{ZoneID: zone.ID, Country: place.Country, Region: place.Region}
+		if err := tx.Create(&region).Error; err != nil {
+			return err
+		}
+		zone.Regions = append(zone.Regions, region)
+	}
+	return nil
+}
+
+// checkMethod - returns why the method can not be saved, empty when it can
+func checkMethod(method *models.ShippingMethod, input methodInput) string {
+	method.Name = strings.TrimSpace(method.Name)
+
+	zone := models.ShippingZone{}
+	database.Database.Db.First(&zone, method.ZoneID)
+
+	switch {
+	case zone.ID == 0:
+		return "Shipping zone not found with id " + strconv.Itoa(int(method.ZoneID))
+	case method.Name == "":
+		return "Name is required"
+	case method.Amount < 0 || method.Threshold < 0:
+		return "Amounts can not be negative"
+	case method.Kind == models.ShippingFreeOver && method.Threshold <= 0:
+		return "free_over methods need a threshold greater than 0"
+	case method.Kind != models.ShippingFlat && method.Kind != models.ShippingWeight &&
+		method.Kind != models.ShippingPrice && method.Kind != models.ShippingFreeOver:
+		return "Kind must be flat, weight, price or free_over"
+	}
+
+	if input.Tiers != nil {
+		for _, tier := range *input.Tiers {
+			if tier.MinWeight < 0 || tier.MinSubtotal < 0 || tier.Cost < 0 {
+				return "Tiers can not be negative"
+			}
+		}
+	}
+
+	// Tiered methods need tiers, the ones they have when none are sent
+	tiered := method.Kind == models.ShippingWeight || method.Kind == models.ShippingPrice
+	if tiered && (input.Tiers == nil && len(method.Tiers) == 0 || input.Tiers != nil && len(*input.Tiers) == 0) {
+		return method.Kind + " methods need at least one tier"
+	}
+
+	return ""
+}
+
+// saveTiers - replaces the tiers of the method with the ones given
+func saveTiers(tx *gorm.DB, method *models.ShippingMethod, input methodInput) error {
+	if input.Tiers == nil {
+		return nil
+	}
+
+	if err := tx.Unscoped().Where("method_id = ?", method.ID).Delete(&models.ShippingRateTier{}).Error; err != nil {
+		return err
+	}
+
+	method.Tiers = []models.ShippingRateTier{}
+	for _, tier := range *input.Tiers {
+		rule := models.ShippingRateTier{MethodID: method.ID, MinWeight: tier.MinWeight, MinSubtotal: tier.MinSubtotal, Cost: tier.Cost}
+		if err := tx.Create(&rule).Error; err != nil {
+			return err
+		}
+		method.Tiers = append(method.Tiers, rule)
+	}
+	return nil
+}
+
+// deleteMethods - removes the methods matching the condition and their tiers
+// for good
+func deleteMethods(tx *gorm.DB, query string, arg interface{}) error {
+	methods := tx.Unscoped().Model(&models.ShippingMethod{}).Select("id").Where(query, arg)
+	if err := tx.Unscoped().Where("method_id IN (?)", methods).Delete(&models.ShippingRateTier{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where(query, arg).Delete(&models.ShippingMethod{}).Error
+}
+
+// GetShippingZones - returns the zones with their places and methods
+func GetShippingZones(c *fiber.Ctx) error {
+	var zones []models.ShippingZone
+
+	preloadZone(database.Database.Db).Order("name").Find(&zones)
+
+	responseZones := make([]map[string]interface{}, len(zones))
+
+	for i, zone := range zones {
+		responseZones[i] = ShippingZoneResponse(zone)
+	}
+
+	return c.JSON(responseZones)
+}
+
+// CreateShippingZone - adds a zone with its places
+func CreateShippingZone(c *fiber.Ctx) error {
+	zone := models.ShippingZone{}
+	inputJson := zoneInput{}
+
+	if err := c.BodyParser(&zone); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := c.BodyParser(&inputJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	zone.ID = 0
+
+	if msg := checkZone(&zone, inputJson); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err := database.Database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Regions", "Methods").Create(&zone).Error; err != nil {
+			return err
+		}
+		return saveRegions(tx, &zone, inputJson)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A place of the zone already belongs to a zone",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(ShippingZoneResponse(zone))
+}
+
+// UpdateShippingZone - renames a zone or replaces its places
+func UpdateShippingZone(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	zone := models.ShippingZone{}
+	db.First(&zone, c.Params("id"))
+
+	if zone.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipping zone not found with id " + c.Params("id"),
+		})
+	}
+
+	id := zone.ID
+	inputJson := zoneInput{}
+
+	if err := c.BodyParser(&zone); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := c.BodyParser(&inputJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	zone.ID = id
+
+	if msg := checkZone(&zone, inputJson); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Regions", "Methods").Save(&zone).Error; err != nil {
+			return err
+		}
+		return saveRegions(tx, &zone, inputJson)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A place of the zone already belongs to a zone",
+		})
+	}
+
+	preloadZone(db).First(&zone, zone.ID)
+
+	return c.JSON(ShippingZoneResponse(zone))
+}
+
+// DeleteShippingZone - removes a zone with its places and methods. Orders keep
+// the name and cost of their method.
+func DeleteShippingZone(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	zone := models.ShippingZone{}
+	db.First(&zone, c.Params("id"))
+
+	if zone.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipping zone not found with id " + c.Params("id"),
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := deleteMethods(tx, "zone_id = ?", zone.ID); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("zone_id = ?", zone.ID).Delete(&models.ShippingZoneRegion{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&zone).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// CreateShippingMethod - adds a method to a zone
+func CreateShippingMethod(c *fiber.Ctx) error {
+	method := models.ShippingMethod{}
+	inputJson := methodInput{}
+
+	if err := c.BodyParser(&method); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := c.BodyParser(&inputJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	method.ID = 0
+
+	if msg := checkMethod(&method, inputJson); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err := database.Database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Tiers").Create(&method).Error; err != nil {
+			return err
+		}
+		return saveTiers(tx, &method, inputJson)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(ShippingMethodResponse(method))
+}
+
+// UpdateShippingMethod - changes a method, priced orders keep their shipping
+// cost until they are updated
+func UpdateShippingMethod(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	method := models.ShippingMethod{}
+	db.Preload("Tiers").First(&method, c.Params("id"))
+
+	if method.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipping method not found with id " + c.Params("id"),
+		})
+	}
+
+	id := method.ID
+	inputJson := methodInput{}
+
+	if err := c.BodyParser(&method); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := c.BodyParser(&inputJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	method.ID = id
+
+	if msg := checkMethod(&method, inputJson); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Tiers").Save(&method).Error; err != nil {
+			return err
+		}
+		return saveTiers(tx, &method, inputJson)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	db.Preload("Tiers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&method, method.ID)
+
+	return c.JSON(ShippingMethodResponse(method))
+}
+
+// DeleteShippingMethod - removes a method and its tiers. Orders keep its name
+// and their cost.
+func DeleteShippingMethod(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	method := models.ShippingMethod{}
+	db.First(&method, c.Params("id"))
+
+	if method.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipping method not found with id " + c.Params("id"),
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return deleteMethods(tx, "id = ?", method.ID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// QuoteShipping - the methods that ship the items to the address with their
+// cost in the currency of the request, cheapest first. Running promotions are
+// taken off the items, a coupon applied at checkout may still lower a
+// subtotal that a method depends on.
+func QuoteShipping(c *fiber.Ctx) error {
+	type quoteItem struct {
+		ProductID int `json:"product_id"`
+		Quantity  int `json:"quantity"`
+	}
+	type quoteRequest struct {
+		Country string      `json:"country"`
+		Region  string      `json:"region"`
+		Items   []quoteItem `json:"items"`
+	}
+
+	db := database.Database.Db
+	quoteJson := quoteRequest{}
+
+	if err := c.BodyParser(&quoteJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	country := tax.Country(quoteJson.Country)
+	if !tax.ValidCountry(country) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Country must be a two letter code",
+		})
+	}
+
+	if len(quoteJson.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Items are required",
+		})
+	}
+
+	currency := RequestCurrency(c)
+	rate, err := pricing.Rate(db, currency)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	items := make([]models.OrderItem, len(quoteJson.Items))
+	for i, item := range quoteJson.Items {
+		if item.Quantity <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Quantity must be greater than 0",
+			})
+		}
+
+		product := models.Product{}
+		db.Where("status = ?", models.ProductPublished).First(&product, item.ProductID)
+
+		if product.ID == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found with id " + strconv.Itoa(item.ProductID),
+			})
+		}
+
+		if items[i], err = pricedOrderItem(db, product, item.Quantity, currency); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	order := models.Order{Currency: currency, ExchangeRate: rate, Country: country, Region: quoteJson.Region}
+	promotion.Apply(db, order, items, time.Now())
+
+	parcel := shipping.Measure(db, items)
+	if !parcel.Shippable {
+		return c.JSON([]map[string]interface{}{})
+	}
+
+	rates, err := shipping.Quote(db, country, quoteJson.Region, parcel, rate)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	responseRates := make([]map[string]interface{}, len(rates))
+	for i, quote := range rates {
+		responseRates[i] = map[string]interface{}{
+			"method_id": quote.Method.ID,
+			"name":      quote.Method.Name,
+			"kind":      quote.Method.Kind,
+			"cost":      quote.Cost,
+			"currency":  currency,
+			"weight":    parcel.Weight,
+		}
+	}
+
+	return c.JSON(responseRates)
+}
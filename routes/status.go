@@ -0,0 +1,206 @@
+package routes
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/coupon"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/digital"
+	"github.com/rama-kairi/fiber-api/giftcard"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/orderstatus"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"gorm.io/gorm"
+)
+
+func OrderStatusChangeResponse(change models.OrderStatusChange) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         change.ID,
+		"created_at": change.CreatedAt,
+		"from":       change.From,
+		"to":         change.To,
+		"user_id":    change.UserID,
+		"note":       change.Note,
+	}
+}
+
+func OrderStatusHistoryResponse(history []models.OrderStatusChange) []map[string]interface{} {
+	responseHistory := make([]map[string]interface{}, len(history))
+
+	for i, change := range history {
+		responseHistory[i] = OrderStatusChangeResponse(change)
+	}
+
+	return responseHistory
+}
+
+// StatusErrorResponse - returns why an order can not move to a status, other
+// errors are reported like payment errors
+func StatusErrorResponse(c *fiber.Ctx, err error) error {
+	var transition *orderstatus.TransitionError
+
+	switch {
+	case errors.As(err, &transition), errors.Is(err, giftcard.ErrRefund):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, orderstatus.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return PaymentErrorResponse(c, err)
+}
+
+// orderStatus - the status of the order, items outside of an order are
+// treated as part of a pending one
+func orderStatus(db *gorm.DB, orderID uint) string {
+	if orderID == 0 {
+		return models.OrderPending
+	}
+
+	order := models.Order{}
+	db.Select("status").First(&order, orderID)
+	return order.Status
+}
+
+// transitionOrder - moves the order to the status along with what comes with
+// it. Paid orders hand out what they bought, cancelled orders give back their
+// stock, coupon, gift card balances and store credit, and refunded orders
+// give the buyer what is left to refund as store credit. Gift cards bought by
+// cancelled or refunded orders are voided, refunded orders refund what was
+// left on them, and both lose their downloads.
+func transitionOrder(tx *gorm.DB, order *models.Order, to string, userID *uint, note string) error {
+	if order.Status == to {
+		return nil
+	}
+
+	if to == models.OrderPaid {
+		return payOrder(tx, order, userID, note)
+	}
+
+	if err := orderstatus.Move(tx, order, to, userID, note); err != nil {
+		return err
+	}
+
+	if to == models.OrderCancelled || to == models.OrderRefunded {
+		if err := digital.Revoke(tx, order.ID); err != nil {
+			return err
+		}
+	}
+
+	switch to {
+	case models.OrderCancelled:
+		var orderItems []models.OrderItem
+		tx.Where("order_id = ?", order.ID).Find(&orderItems)
+
+		var by uint
+		if userID != nil {
+			by = *userID
+		}
+		if err := inventory.Restock(tx, orderItems, by); err != nil {
+			return err
+		}
+
+		if err := coupon.Release(tx, order.ID); err != nil {
+			return err
+		}
+
+		if err := giftcard.VoidPurchased(tx, *order, note); err != nil {
+			return err
+		}
+
+		_, _, err := giftcard.Release(tx, order)
+		return err
+
+	case models.OrderRefunded:
+		if err := giftcard.VoidPurchased(tx, *order, note); err != nil {
+			return err
+		}
+
+		if giftcard.Refundable(tx, *order) <= 0 {
+			return nil
+		}
+
+		_, err := giftcard.Refund(tx, *order, 0, note)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateOrderStatus - moves an order to the next status. Buyers can check out,
+// reopen and cancel their own orders before they are paid, the other moves
+// are for admins.
+func UpdateOrderStatus(c *fiber.Ctx) error {
+	type statusUpdate struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+
+	db := database.Database.Db
+
+	order := models.Order{}
+	db.First(&order, c.Params("id"))
+
+	if order.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order not found with id " + c.Params("id"),
+		})
+	}
+
+	statusJson := statusUpdate{}
+	if err := c.BodyParser(&statusJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	status := strings.ToLower(strings.TrimSpace(statusJson.Status))
+	if !orderstatus.Valid(status) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Status must be one of " + strings.Join(orderstatus.Statuses, ", "),
+		})
+	}
+
+	current := models.User{}
+	db.First(&current, utils.CurrentUserID(c))
+
+	if current.ID != uint(order.UserID) && !current.IsAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the buyer or an admin can change the status of the order",
+		})
+	}
+
+	if order.Status != status {
+		if err := orderstatus.Check(order, status, current.IsAdmin); err != nil {
+			return StatusErrorResponse(c, err)
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return transitionOrder(tx, &order, status, &current.ID, statusJson.Note)
+	})
+	if err != nil {
+		return StatusErrorResponse(c, err)
+	}
+
+	var orderItems []models.OrderItem
+	db.Where("order_id = ?", order.ID).Find(&orderItems)
+
+	if status == models.OrderCancelled {
+		inventory.WatchItems(orderItems)
+	}
+
+	user := models.User{}
+	db.First(&user, order.UserID)
+
+	response := OrderResponse(order, orderItems, user)
+	response["status_history"] = OrderStatusHistoryResponse(orderstatus.History(db, order.ID))
+
+	return c.JSON(response)
+}
@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"gorm.io/gorm"
+)
+
+func StockMovementResponse(movement models.StockMovement) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           movement.ID,
+		"created_at":   movement.CreatedAt,
+		"product_id":   movement.ProductID,
+		"warehouse_id": movement.WarehouseID,
+		"change":       movement.Change,
+		"reason":       movement.Reason,
+		"actor_id":     movement.ActorID,
+		"order_id":     movement.OrderID,
+		"note":         movement.Note,
+	}
+}
+
+// AdjustProductStock - records a manual stock change for a product
+func AdjustProductStock(c *fiber.Ctx) error {
+	type stockAdjust struct {
+		Change      int    `json:"change"`
+		Reason      string `json:"reason"`
+		Note        string `json:"note"`
+		WarehouseID uint   `json:"warehouse_id"`
+	}
+
+	db := database.Database.Db
+	adjustJson := new(stockAdjust)
+
+	if err := c.BodyParser(&adjustJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Sales are only recorded by placing orders
+	switch adjustJson.Reason {
+	case models.MovementRestock, models.MovementAdjustment, models.MovementReturn, models.MovementCorrection:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Reason must be one of restock, adjustment, return or correction",
+		})
+	}
+
+	if adjustJson.Change == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Change can not be 0",
+		})
+	}
+
+	product := models.Product{}
+	db.First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	// The default warehouse is used when none is given
+	if adjustJson.WarehouseID != 0 {
+		warehouse := models.Warehouse{}
+		db.First(&warehouse, adjustJson.WarehouseID)
+
+		if warehouse.ID == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Warehouse not found with id " + strconv.Itoa(int(adjustJson.WarehouseID)),
+			})
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return inventory.AdjustAt(tx, adjustJson.WarehouseID, product.ID, adjustJson.Change, adjustJson.Reason, utils.CurrentUserID(c), adjustJson.Note)
+	})
+	if err != nil {
+		return StockErrorResponse(c, err)
+	}
+
+	inventory.Watch(product.ID)
+	db.First(&product, product.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(ProductResponse(c, product))
+}
+
+// GetProductMovements - returns the stock movement history of a product
+func GetProductMovements(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	product := models.Product{}
+	db.First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	movements, err := inventory.History(db, product.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	responseMovements := make([]map[string]interface{}, len(movements))
+
+	for i, movement := range movements {
+		responseMovements[i] = StockMovementResponse(movement)
+	}
+
+	return c.JSON(responseMovements)
+}
+
+// GetLowStockProducts - returns the products at or below their reorder threshold
+func GetLowStockProducts(c *fiber.Ctx) error {
+	products, err := inventory.LowStock(database.Database.Db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	responseProducts := make([]map[string]interface{}, len(products))
+
+	for i, product := range products {
+		responseProducts[i] = ProductResponse(c, product)
+	}
+
+	return c.JSON(responseProducts)
+}
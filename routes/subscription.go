@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"net/mail"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+)
+
+func StockSubscriptionResponse(subscription models.StockSubscription) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          subscription.ID,
+		"created_at":  subscription.CreatedAt,
+		"product_id":  subscription.ProductID,
+		"email":       subscription.Email,
+		"notified_at": subscription.NotifiedAt,
+		"token":       subscription.Token,
+	}
+}
+
+// SubscribeToStock - asks to be told by email when an out of stock product is
+// back. Signed in users may leave out the email to use their own. Subscribing
+// again after a notice rearms the subscription.
+func SubscribeToStock(c *fiber.Ctx) error {
+	type subscriptionCreate struct {
+		Email string `json:"email"`
+	}
+
+	db := database.Database.Db
+
+	product := models.Product{}
+	db.First(&product, c.Params("id"))
+
+	if product.ID == 0 || !visible(c, product) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	if inventory.Available(db, product) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Product is in stock",
+		})
+	}
+
+	subscriptionJson := new(subscriptionCreate)
+	if err := c.BodyParser(&subscriptionJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	user := models.User{}
+	if userID := utils.CurrentUserID(c); userID != 0 {
+		db.First(&user, userID)
+	}
+
+	email := strings.ToLower(strings.TrimSpace(subscriptionJson.Email))
+	if email == "" {
+		email = strings.ToLower(user.Email)
+	}
+
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A valid email is required",
+		})
+	}
+
+	// One subscription per email and product
+	subscription := models.StockSubscription{}
+	db.Where("product_id = ? AND email = ?", product.ID, email).Limit(1).Find(&subscription)
+
+	status := fiber.StatusOK
+	if subscription.ID == 0 {
+		token, err := utils.RandomToken()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		subscription = models.StockSubscription{ProductID: product.ID, Email: email, Token: token}
+		status = fiber.StatusCreated
+	}
+
+	subscription.NotifiedAt = nil
+	if user.ID != 0 && strings.EqualFold(user.Email, email) {
+		subscription.UserID = &user.ID
+	}
+
+	if err := db.Save(&subscription).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(status).JSON(StockSubscriptionResponse(subscription))
+}
+
+// GetStockSubscriptions - returns the subscriptions of the current user,
+// including those made as a guest with the same email
+func GetStockSubscriptions(c *fiber.Ctx) error {
+	var subscriptions []models.StockSubscription
+	db := database.Database.Db
+
+	user := models.User{}
+	db.First(&user, utils.CurrentUserID(c))
+
+	db.Where("user_id = ? OR email = ?", user.ID, strings.ToLower(user.Email)).Order("id").Find(&subscriptions)
+
+	responseSubscriptions := make([]map[string]interface{}, len(subscriptions))
+
+	for i, subscription := range subscriptions {
+		responseSubscriptions[i] = StockSubscriptionResponse(subscription)
+	}
+
+	return c.JSON(responseSubscriptions)
+}
+
+// Unsubscribe - removes a subscription by its token, which is only known to
+// the subscriber
+func Unsubscribe(c *fiber.Ctx) error {
+	result := database.Database.Db.Unscoped().Where("token = ?", c.Params("token")).Delete(&models.StockSubscription{})
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Subscription not found",
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
@@ -0,0 +1,156 @@
+package routes
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/tax"
+)
+
+func TaxRateResponse(rate models.TaxRate) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         rate.ID,
+		"updated_at": rate.UpdatedAt,
+		"country":    rate.Country,
+		"region":     rate.Region,
+		"category":   rate.Category,
+		"name":       rate.Name,
+		"rate":       rate.Rate,
+		"inclusive":  rate.Inclusive,
+	}
+}
+
+func TaxBreakdownResponse(breakdown tax.Breakdown) []map[string]interface{} {
+	responseLines := make([]map[string]interface{}, len(breakdown))
+
+	for i, line := range breakdown {
+		responseLines[i] = map[string]interface{}{
+			"name":      line.Name,
+			"country":   line.Country,
+			"region":    line.Region,
+			"category":  line.Category,
+			"rate":      line.Rate,
+			"inclusive": line.Inclusive,
+			"taxable":   line.Taxable,
+			"amount":    line.Amount,
+		}
+	}
+
+	return responseLines
+}
+
+// checkTaxRate - cleans up the jurisdiction of the rate and returns why it
+// can not be saved, empty when it can
+func checkTaxRate(rate *models.TaxRate) string {
+	rate.Country = strings.ToUpper(strings.TrimSpace(rate.Country))
+	rate.Region = strings.ToUpper(strings.TrimSpace(rate.Region))
+	rate.Category = strings.ToLower(strings.TrimSpace(rate.Category))
+
+	if !tax.ValidCountry(rate.Country) {
+		return "Country must be a two letter code"
+	}
+
+	if rate.Rate < 0 || rate.Rate > 100 {
+		return "Rate must be a percentage between 0 and 100"
+	}
+
+	return ""
+}
+
+// GetTaxRates - returns the tax rates by jurisdiction, only those of
+// ?country= when it is given
+func GetTaxRates(c *fiber.Ctx) error {
+	var rates []models.TaxRate
+
+	query := database.Database.Db.Order("country, region, category")
+	if country := c.Query("country"); country != "" {
+		query = query.Where("country = ?", strings.ToUpper(country))
+	}
+	query.Find(&rates)
+
+	responseRates := make([]map[string]interface{}, len(rates))
+
+	for i, rate := range rates {
+		responseRates[i] = TaxRateResponse(rate)
+	}
+
+	return c.JSON(responseRates)
+}
+
+// CreateTaxRate - adds the tax rate of a jurisdiction
+func CreateTaxRate(c *fiber.Ctx) error {
+	rate := models.TaxRate{}
+
+	if err := c.BodyParser(&rate); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	rate.ID = 0
+	if msg := checkTaxRate(&rate); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if err := database.Database.Db.Create(&rate).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A tax rate already exists for this country, region and category",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(TaxRateResponse(rate))
+}
+
+// UpdateTaxRate - changes a tax rate, orders already placed keep theirs
+func UpdateTaxRate(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	rate := models.TaxRate{}
+	db.First(&rate, c.Params("id"))
+
+	if rate.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Tax rate not found with id " + c.Params("id"),
+		})
+	}
+
+	id := rate.ID
+	if err := c.BodyParser(&rate); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	rate.ID = id
+	if msg := checkTaxRate(&rate); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if err := db.Save(&rate).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A tax rate already exists for this country, region and category",
+		})
+	}
+
+	return c.JSON(TaxRateResponse(rate))
+}
+
+// DeleteTaxRate - removes a tax rate, orders already placed keep theirs
+func DeleteTaxRate(c *fiber.Ctx) error {
+	// Rows are unique per jurisdiction, so they are removed instead of soft deleted
+	result := database.Database.Db.Unscoped().Delete(&models.TaxRate{}, c.Params("id"))
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Tax rate not found with id " + c.Params("id"),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
@@ -0,0 +1,206 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/config"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/i18n"
+	"github.com/rama-kairi/fiber-api/models"
+)
+
+// translationUpdate - the body of a translation, categories only use the name
+type translationUpdate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// RequestLocales - the locales picked for the request by middleware.Locale,
+// most wanted first
+func RequestLocales(c *fiber.Ctx) []string {
+	if chain, ok := c.Locals("locales").([]string); ok {
+		return chain
+	}
+	return []string{config.GetConfig().Locale.Default}
+}
+
+func ProductTranslationResponse(translation models.ProductTranslation) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          translation.ID,
+		"updated_at":  translation.UpdatedAt,
+		"product_id":  translation.ProductID,
+		"locale":      translation.Locale,
+		"name":        translation.Name,
+		"description": translation.Description,
+	}
+}
+
+func CategoryTranslationResponse(translation models.CategoryTranslation) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           translation.ID,
+		"updated_at":   translation.UpdatedAt,
+		"product_type": translation.ProductType,
+		"locale":       translation.Locale,
+		"name":         translation.Name,
+	}
+}
+
+// GetProductTranslations - returns the translations of a product
+func GetProductTranslations(c *fiber.Ctx) error {
+	var translations []models.ProductTranslation
+
+	database.Database.Db.Where("product_id = ?", c.Params("id")).Order("locale").Find(&translations)
+
+	responseTranslations := make([]map[string]interface{}, len(translations))
+
+	for i, translation := range translations {
+		responseTranslations[i] = ProductTranslationResponse(translation)
+	}
+
+	return c.JSON(responseTranslations)
+}
+
+// SetProductTranslation - creates or replaces the translation of a product in
+// a locale. The default locale is the product's own name and description.
+func SetProductTranslation(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	product := models.Product{}
+	db.First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	locale := config.NormalizeLocale(c.Params("locale"))
+
+	if !i18n.IsSupported(locale) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported locale " + c.Params("locale"),
+		})
+	}
+
+	if locale == config.GetConfig().Locale.Default {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The default locale is set on the product itself",
+		})
+	}
+
+	translationJson := new(translationUpdate)
+	if err := c.BodyParser(&translationJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if translationJson.Name == "" && translationJson.Description == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name or description is required",
+		})
+	}
+
+	translation := models.ProductTranslation{}
+	db.Where("product_id = ? AND locale = ?", product.ID, locale).Limit(1).Find(&translation)
+
+	translation.ProductID = product.ID
+	translation.Locale = locale
+	translation.Name = translationJson.Name
+	translation.Description = translationJson.Description
+
+	if err := db.Save(&translation).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(ProductTranslationResponse(translation))
+}
+
+// DeleteProductTranslation - removes the translation of a product in a locale
+func DeleteProductTranslation(c *fiber.Ctx) error {
+	result := database.Database.Db.Unscoped().
+		Where("product_id = ? AND locale = ?", c.Params("id"), config.NormalizeLocale(c.Params("locale"))).
+		Delete(&models.ProductTranslation{})
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Translation not found for locale " + c.Params("locale"),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// GetCategoryTranslations - returns the display names of a product type
+func GetCategoryTranslations(c *fiber.Ctx) error {
+	var translations []models.CategoryTranslation
+
+	database.Database.Db.Where("product_type = ?", c.Params("type")).Order("locale").Find(&translations)
+
+	responseTranslations := make([]map[string]interface{}, len(translations))
+
+	for i, translation := range translations {
+		responseTranslations[i] = CategoryTranslationResponse(translation)
+	}
+
+	return c.JSON(responseTranslations)
+}
+
+// SetCategoryTranslation - creates or replaces the display name of a product
+// type in a locale
+func SetCategoryTranslation(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	locale := config.NormalizeLocale(c.Params("locale"))
+
+	if !i18n.IsSupported(locale) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported locale " + c.Params("locale"),
+		})
+	}
+
+	translationJson := new(translationUpdate)
+	if err := c.BodyParser(&translationJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if translationJson.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name is required",
+		})
+	}
+
+	translation := models.CategoryTranslation{}
+	db.Where("product_type = ? AND locale = ?", c.Params("type"), locale).Limit(1).Find(&translation)
+
+	translation.ProductType = c.Params("type")
+	translation.Locale = locale
+	translation.Name = translationJson.Name
+
+	if err := db.Save(&translation).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(CategoryTranslationResponse(translation))
+}
+
+// DeleteCategoryTranslation - removes the display name of a product type in a
+// locale
+func DeleteCategoryTranslation(c *fiber.Ctx) error {
+	result := database.Database.Db.Unscoped().
+		Where("product_type = ? AND locale = ?", c.Params("type"), config.NormalizeLocale(c.Params("locale"))).
+		Delete(&models.CategoryTranslation{})
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Translation not found for locale " + c.Params("locale"),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
@@ -0,0 +1,519 @@
+package routes
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/catalog"
+	"github.com/rama-kairi/fiber-api/coupon"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/giftcard"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"gorm.io/gorm"
+)
+
+// Deleted records are soft deleted rows, they are only reachable through
+// Unscoped queries.
+
+// deleted - a query over the soft deleted rows of a model
+func deleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("deleted_at IS NOT NULL")
+}
+
+// ConflictResponse - 409 naming the live record that holds a unique value
+func ConflictResponse(c *fiber.Ctx, msg string, conflictID uint) error {
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"error":       msg,
+		"conflict_id": conflictID,
+	})
+}
+
+// Products
+
+// GetDeletedProducts - returns the deleted products, latest deleted first
+func GetDeletedProducts(c *fiber.Ctx) error {
+	var products []models.Product
+
+	deleted(database.Database.Db).Order("deleted_at desc").Find(&products)
+
+	responseProducts := make([]map[string]interface{}, len(products))
+
+	for i, product := range products {
+		responseProducts[i] = ProductResponse(c, product)
+		responseProducts[i]["deleted_at"] = product.DeletedAt.Time
+	}
+
+	return c.JSON(responseProducts)
+}
+
+// RestoreProduct - brings a deleted product back. When a live product took its
+// name or sku in the meantime the restore is refused, unless the body gives a
+// new name or sku.
+func RestoreProduct(c *fiber.Ctx) error {
+	type productRestore struct {
+		Name *string `json:"name"`
+		SKU  *string `json:"sku"`
+	}
+
+	db := database.Database.Db
+	restoreJson := new(productRestore)
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&restoreJson); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	product := models.Product{}
+	deleted(db).First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Deleted product not found with id " + c.Params("id"),
+		})
+	}
+
+	previousName := product.Name
+	if restoreJson.Name != nil {
+		product.Name = strings.TrimSpace(*restoreJson.Name)
+	}
+	if restoreJson.SKU != nil {
+		product.SKU = normalizeSKU(restoreJson.SKU)
+	}
+
+	if product.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name can not be empty",
+		})
+	}
+
+	var conflict models.Product
+	db.Where("name = ? AND id <> ?", product.Name, product.ID).Limit(1).Find(&conflict)
+	if conflict.ID != 0 {
+		return ConflictResponse(c, "A product named "+product.Name+" already exists, restore it with a new name", conflict.ID)
+	}
+
+	if product.SKU != nil {
+		db.Where("sku = ? AND id <> ?", *product.SKU, product.ID).Limit(1).Find(&conflict)
+		if conflict.ID != 0 {
+			return ConflictResponse(c, "A product with sku "+*product.SKU+" already exists, restore it with a new sku", conflict.ID)
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&product).Select("name", "sku", "deleted_at").Updates(map[string]interface{}{
+			"name":       product.Name,
+			"sku":        product.SKU,
+			"deleted_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		if product.Name == previousName {
+			return nil
+		}
+		return catalog.AssignSlug(tx, &product)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	inventory.Watch(product.ID)
+	db.First(&product, product.ID)
+
+	return c.JSON(ProductResponse(c, product))
+}
+
+// PurgeProduct - removes a deleted product for good with its prices, stock
+// ledger, slugs, attributes and reviews. Products that were ever ordered are
+// kept for the order history.
+func PurgeProduct(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	product := models.Product{}
+	deleted(db).First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Deleted product not found with id " + c.Params("id"),
+		})
+	}
+
+	var ordered int64
+	db.Unscoped().Model(&models.OrderItem{}).Where("product_id = ?", product.ID).Count(&ordered)
+	if ordered == 0 {
+		db.Unscoped().Model(&models.OrderItemComponent{}).Where("product_id = ?", product.ID).Count(&ordered)
+	}
+	if ordered > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Product is part of " + strconv.FormatInt(ordered, 10) + " order items and can not be purged",
+		})
+	}
+
+	var bundles int64
+	db.Model(&models.BundleItem{}).Where("component_id = ?", product.ID).Count(&bundles)
+	if bundles > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Product is a component of " + strconv.FormatInt(bundles, 10) + " bundles and can not be purged",
+		})
+	}
+
+	// The stored files go once their rows are gone
+	var files []models.ProductFile
+	db.Unscoped().Where("product_id = ?", product.ID).Find(&files)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		reviews := tx.Unscoped().Model(&models.Review{}).Select("id").Where("product_id = ?", product.ID)
+		if err := tx.Unscoped().Where("review_id IN (?)", reviews).Delete(&models.ReviewVote{}).Error; err != nil {
+			return err
+		}
+
+		productFiles := tx.Unscoped().Model(&models.ProductFile{}).Select("id").Where("product_id = ?", product.ID)
+		grants := tx.Unscoped().Model(&models.DownloadGrant{}).Select("id").Where("product_file_id IN (?)", productFiles)
+		if err := tx.Unscoped().Where("download_grant_id IN (?)", grants).Delete(&models.Download{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("product_file_id IN (?)", productFiles).Delete(&models.DownloadGrant{}).Error; err != nil {
+			return err
+		}
+
+		for _, dependent := range []interface{}{
+			&models.Review{},
+			&models.StockMovement{},
+			&models.ProductPrice{},
+			&models.ProductCurrencyPrice{},
+			&models.ProductSlug{},
+			&models.ProductAttribute{},
+			&models.ProductTranslation{},
+			&models.WarehouseStock{},
+			&models.StockTransfer{},
+			&models.ProductFile{},
+			&models.WishlistItem{},
+			&models.StockSubscription{},
+			&models.CouponProduct{},
+			&models.PromotionTarget{},
+		} {
+			if err := tx.Unscoped().Where("product_id = ?", product.ID).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Where("bundle_id = ?", product.ID).Delete(&models.BundleItem{}).Error; err != nil {
+			return err
+		}
+
+		// Related products point both ways
+		if err := tx.Unscoped().Where("product_id = ? OR related_id = ?", product.ID, product.ID).Delete(&models.RelatedProduct{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&product).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	for _, file := range files {
+		os.Remove(file.Path)
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// Users
+
+// GetDeletedUsers - returns the deleted users, latest deleted first
+func GetDeletedUsers(c *fiber.Ctx) error {
+	var users []models.User
+
+	deleted(database.Database.Db).Order("deleted_at desc").Find(&users)
+
+	responseUsers := make([]map[string]interface{}, len(users))
+
+	for i, user := range users {
+		responseUsers[i] = ResponseUser(user)
+		responseUsers[i]["deleted_at"] = user.DeletedAt.Time
+	}
+
+	return c.JSON(responseUsers)
+}
+
+// RestoreUser - brings a deleted user back. When a live user took the email in
+// the meantime the restore is refused, unless the body gives a new email.
+func RestoreUser(c *fiber.Ctx) error {
+	type userRestore struct {
+		Email *string `json:"email"`
+	}
+
+	db := database.Database.Db
+	restoreJson := new(userRestore)
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&restoreJson); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	user := models.User{}
+	deleted(db).First(&user, c.Params("id"))
+
+	if user.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Deleted user not found with id " + c.Params("id"),
+		})
+	}
+
+	if restoreJson.Email != nil {
+		user.Email = strings.TrimSpace(*restoreJson.Email)
+	}
+
+	if user.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Email can not be empty",
+		})
+	}
+
+	// Emails are compared without case, the way people type them
+	var conflict models.User
+	db.Where("LOWER(email) = LOWER(?) AND id <> ?", user.Email, user.ID).Limit(1).Find(&conflict)
+	if conflict.ID != 0 {
+		return ConflictResponse(c, "A user with email "+user.Email+" already exists, restore it with a new email", conflict.ID)
+	}
+
+	if err := db.Unscoped().Model(&user).Select("email", "deleted_at").Updates(map[string]interface{}{
+		"email":      user.Email,
+		"deleted_at": nil,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	db.First(&user, user.ID)
+
+	return c.JSON(ResponseUser(user))
+}
+
+// PurgeUser - removes a deleted user for good with their reviews and votes.
+// Users with orders are kept for the order history.
+func PurgeUser(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	user := models.User{}
+	deleted(db).First(&user, c.Params("id"))
+
+	if user.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Deleted user not found with id " + c.Params("id"),
+		})
+	}
+
+	var orders int64
+	db.Unscoped().Model(&models.Order{}).Where("user_id = ?", user.ID).Count(&orders)
+	if orders > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "User has " + strconv.FormatInt(orders, 10) + " orders and can not be purged",
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var reviews []models.Review
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Find(&reviews).Error; err != nil {
+			return err
+		}
+
+		for _, review := range reviews {
+			if review.DeletedAt.Valid {
+				continue
+			}
+			count, sum := ratingChange(review.Status, review.Rating, models.ReviewRejected, 0)
+			if err := applyRating(tx, review.ProductID, count, sum); err != nil {
+				return err
+			}
+		}
+
+		reviewIDs := tx.Unscoped().Model(&models.Review{}).Select("id").Where("user_id = ?", user.ID)
+		if err := tx.Unscoped().Where("user_id = ? OR review_id IN (?)", user.ID, reviewIDs).Delete(&models.ReviewVote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Review{}).Error; err != nil {
+			return err
+		}
+		wishlists := tx.Unscoped().Model(&models.Wishlist{}).Select("id").Where("user_id = ?", user.ID)
+		if err := tx.Unscoped().Where("wishlist_id IN (?)", wishlists).Delete(&models.WishlistItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Wishlist{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.StoreCredit{}).Error; err != nil {
+			return err
+		}
+		// Gift cards are spent by whoever has the code, they outlive their owner
+		if err := tx.Unscoped().Model(&models.GiftCard{}).Where("user_id = ?", user.ID).Update("user_id", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&user).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// Orders
+
+// GetDeletedOrders - returns the deleted orders, latest deleted first
+func GetDeletedOrders(c *fiber.Ctx) error {
+	var orders []models.Order
+	db := database.Database.Db
+
+	deleted(db).Order("deleted_at desc").Find(&orders)
+
+	responseOrders := make([]map[string]interface{}, len(orders))
+
+	for i, order := range orders {
+		var orderItems []models.OrderItem
+		db.Where("order_id = ?", order.ID).Find(&orderItems)
+
+		user := models.User{}
+		db.Unscoped().First(&user, order.UserID)
+
+		responseOrders[i] = OrderResponse(order, orderItems, user)
+		responseOrders[i]["deleted_at"] = order.DeletedAt.Time
+	}
+
+	return c.JSON(responseOrders)
+}
+
+// RestoreOrder - brings a deleted order back. Deleting the order put its items
+// back into stock and gave back its coupon, so they are taken again and the
+// restore fails with 409 when the stock or the coupon uses are gone.
+func RestoreOrder(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	order := models.Order{}
+	deleted(db).First(&order, c.Params("id"))
+
+	if order.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Deleted order not found with id " + c.Params("id"),
+		})
+	}
+
+	// Gift cards and store credit given back when the order was deleted may
+	// have been spent since
+	if order.Status != models.OrderCancelled && giftcard.Released(db, order.ID) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Order gave its gift card and store credit payments back when it was deleted and can not be restored",
+		})
+	}
+
+	var orderItems []models.OrderItem
+	db.Where("order_id = ?", order.ID).Find(&orderItems)
+
+	// Cancelled orders gave their stock and coupon back before they were deleted
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if order.Status == models.OrderCancelled {
+			return tx.Unscoped().Model(&order).Update("deleted_at", nil).Error
+		}
+
+		if err := inventory.Decrement(tx, orderItems, utils.CurrentUserID(c)); err != nil {
+			return err
+		}
+
+		if applied := orderCoupon(tx, order); applied != nil {
+			if err := coupon.Redeem(tx, *applied, order, order.CouponDiscount); err != nil {
+				return err
+			}
+		}
+
+		return tx.Unscoped().Model(&order).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		return CouponErrorResponse(c, err)
+	}
+
+	inventory.WatchItems(orderItems)
+
+	user := models.User{}
+	db.First(&user, order.UserID)
+
+	db.First(&order, order.ID)
+
+	return c.JSON(OrderResponse(order, orderItems, user))
+}
+
+// PurgeOrder - removes a deleted order and its items for good. Its stock was
+// already given back when it was deleted.
+func PurgeOrder(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	order := models.Order{}
+	deleted(db).First(&order, c.Params("id"))
+
+	if order.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Deleted order not found with id " + c.Params("id"),
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		orderItems := tx.Unscoped().Model(&models.OrderItem{}).Select("id").Where("order_id = ?", order.ID)
+		if err := tx.Unscoped().Where("order_item_id IN (?)", orderItems).Delete(&models.OrderItemComponent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("order_item_id IN (?)", orderItems).Delete(&models.OrderItemPromotion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("order_id = ?", order.ID).Delete(&models.StockAllocation{}).Error; err != nil {
+			return err
+		}
+		grants := tx.Unscoped().Model(&models.DownloadGrant{}).Select("id").Where("order_id = ?", order.ID)
+		if err := tx.Unscoped().Where("download_grant_id IN (?)", grants).Delete(&models.Download{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("order_id = ?", order.ID).Delete(&models.DownloadGrant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("order_id = ?", order.ID).Delete(&models.OrderTax{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("order_id = ?", order.ID).Delete(&models.CouponRedemption{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("order_id = ?", order.ID).Delete(&models.OrderPayment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("order_id = ?", order.ID).Delete(&models.OrderStatusChange{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("order_id = ?", order.ID).Delete(&models.OrderItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&order).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
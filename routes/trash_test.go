@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+)
+
+// trashApp - the trash routes
+func trashApp(t *testing.T) *fiber.App {
+	t.Helper()
+
+	app := testApp(t)
+	app.Post("/trash/products/:id/restore", RestoreProduct)
+	app.Post("/trash/orders/:id/restore", RestoreOrder)
+	return app
+}
+
+func TestRestoreProduct(t *testing.T) {
+	app := trashApp(t)
+	db := database.Database.Db
+
+	mug := models.Product{Name: "Mug"}
+	db.Create(&mug)
+	db.Delete(&mug)
+	db.Create(&models.Product{Name: "Cup"})
+
+	if status := send(t, app, "POST", "/trash/products/1/restore", `{"name":"Cup"}`); status != fiber.StatusConflict {
+		t.Errorf("restoring with a taken name = %d, want 409", status)
+	}
+	if status := send(t, app, "POST", "/trash/products/1/restore", `{"name":"Old Mug"}`); status != fiber.StatusOK {
+		t.Fatalf("restoring with a new name = %d, want 200", status)
+	}
+
+	db.First(&mug, mug.ID)
+	if mug.Name != "Old Mug" || mug.Slug != "old-mug" {
+		t.Errorf("restored as %q with slug %q, want Old Mug and old-mug", mug.Name, mug.Slug)
+	}
+	if status := send(t, app, "POST", "/trash/products/1/restore", ""); status != fiber.StatusNotFound {
+		t.Errorf("restoring a live product = %d, want 404", status)
+	}
+}
+
+func TestRestoreOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		stock int
+		want  int
+	}{
+		{"stock is there", 2, fiber.StatusOK},
+		{"stock sold since", 1, fiber.StatusConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := trashApp(t)
+			db := database.Database.Db
+
+			db.Create(&models.User{FirstName: "Ada", Email: "ada@example.com"})
+			db.Create(&models.Product{Name: "Mug", Price: 1000, Quantity: test.stock})
+			inventory.SeedWarehouses(db)
+			order := models.Order{UserID: 1}
+			db.Create(&order)
+			db.Create(&models.OrderItem{OrderID: order.ID, ProductID: 1, Quantity: 2, Price: 1000})
+			db.Delete(&order)
+
+			if status := send(t, app, "POST", "/trash/orders/1/restore", ""); status != test.want {
+				t.Fatalf("restoring = %d, want %d", status, test.want)
+			}
+
+			product := models.Product{}
+			db.First(&product, 1)
+			if want := test.stock - 2; test.want == fiber.StatusOK && product.Quantity != want {
+				t.Errorf("stock = %d, want %d", product.Quantity, want)
+			}
+			if test.want != fiber.StatusOK && product.Quantity != test.stock {
+				t.Errorf("stock = %d after a refused restore, want %d", product.Quantity, test.stock)
+			}
+		})
+	}
+}
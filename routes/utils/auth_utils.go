@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/rama-kairi/fiber-api/config"
 	"github.com/rama-kairi/fiber-api/database"
@@ -90,6 +91,17 @@ func DecodeToken(tokenString string, tokenType string) (jwt.MapClaims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
+// CurrentUserID - returns the id of the authenticated user, 0 if there is none.
+func CurrentUserID(c *fiber.Ctx) uint {
+	claims, ok := c.Locals("user").(jwt.MapClaims)
+	if !ok {
+		return 0
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	return uint(userID)
+}
+
 // IsAuthenticated - checks if the user is authenticated.
 func IsAuthenticated(username string, password string) (bool, models.User) {
 	db := database.Database.Db
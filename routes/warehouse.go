@@ -0,0 +1,244 @@
+package routes
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"gorm.io/gorm"
+)
+
+func WarehouseResponse(warehouse models.Warehouse) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       warehouse.ID,
+		"code":     warehouse.Code,
+		"name":     warehouse.Name,
+		"region":   warehouse.Region,
+		"priority": warehouse.Priority,
+	}
+}
+
+func StockTransferResponse(transfer models.StockTransfer) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                transfer.ID,
+		"created_at":        transfer.CreatedAt,
+		"product_id":        transfer.ProductID,
+		"from_warehouse_id": transfer.FromWarehouseID,
+		"to_warehouse_id":   transfer.ToWarehouseID,
+		"quantity":          transfer.Quantity,
+		"actor_id":          transfer.ActorID,
+		"note":              transfer.Note,
+	}
+}
+
+// GetWarehouses - returns the warehouses in priority order
+func GetWarehouses(c *fiber.Ctx) error {
+	var warehouses []models.Warehouse
+
+	database.Database.Db.Order("priority, id").Find(&warehouses)
+
+	responseWarehouses := make([]map[string]interface{}, len(warehouses))
+
+	for i, warehouse := range warehouses {
+		responseWarehouses[i] = WarehouseResponse(warehouse)
+	}
+
+	return c.JSON(responseWarehouses)
+}
+
+// CreateWarehouse - adds a warehouse
+func CreateWarehouse(c *fiber.Ctx) error {
+	warehouse := models.Warehouse{}
+
+	if err := c.BodyParser(&warehouse); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	warehouse.Code = strings.TrimSpace(warehouse.Code)
+	if warehouse.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Code is required",
+		})
+	}
+
+	if err := database.Database.Db.Create(&warehouse).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Warehouse already exists with code " + warehouse.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(WarehouseResponse(warehouse))
+}
+
+// UpdateWarehouse - changes the name, region or priority of a warehouse
+func UpdateWarehouse(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	warehouse := models.Warehouse{}
+	db.First(&warehouse, c.Params("id"))
+
+	if warehouse.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Warehouse not found with id " + c.Params("id"),
+		})
+	}
+
+	if err := c.BodyParser(&warehouse); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	warehouse.Code = strings.TrimSpace(warehouse.Code)
+	if warehouse.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Code is required",
+		})
+	}
+
+	if err := db.Save(&warehouse).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Warehouse already exists with code " + warehouse.Code,
+		})
+	}
+
+	return c.JSON(WarehouseResponse(warehouse))
+}
+
+// DeleteWarehouse - removes an empty warehouse, the last one is kept
+func DeleteWarehouse(c *fiber.Ctx) error {
+	db := database.Database.Db
+
+	warehouse := models.Warehouse{}
+	db.First(&warehouse, c.Params("id"))
+
+	if warehouse.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Warehouse not found with id " + c.Params("id"),
+		})
+	}
+
+	var stocked int64
+	db.Model(&models.WarehouseStock{}).Where("warehouse_id = ? AND quantity <> 0", warehouse.ID).Count(&stocked)
+
+	if stocked > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Warehouse still holds stock, transfer it first",
+		})
+	}
+
+	var count int64
+	db.Model(&models.Warehouse{}).Count(&count)
+
+	if count == 1 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "The last warehouse can not be deleted",
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("warehouse_id = ?", warehouse.ID).Delete(&models.WarehouseStock{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&warehouse).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// GetProductStockLevels - returns the stock of a product in every warehouse
+func GetProductStockLevels(c *fiber.Ctx) error {
+	product := models.Product{}
+	database.Database.Db.First(&product, c.Params("id"))
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + c.Params("id"),
+		})
+	}
+
+	return c.JSON(inventory.Levels(database.Database.Db, product.ID))
+}
+
+// GetStockTransfers - returns the transfers, newest first, only those of
+// ?product_id= when it is given
+func GetStockTransfers(c *fiber.Ctx) error {
+	var transfers []models.StockTransfer
+
+	query := database.Database.Db.Order("id desc")
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	query.Find(&transfers)
+
+	responseTransfers := make([]map[string]interface{}, len(transfers))
+
+	for i, transfer := range transfers {
+		responseTransfers[i] = StockTransferResponse(transfer)
+	}
+
+	return c.JSON(responseTransfers)
+}
+
+// CreateStockTransfer - moves stock of a product between two warehouses
+func CreateStockTransfer(c *fiber.Ctx) error {
+	db := database.Database.Db
+	transfer := models.StockTransfer{}
+
+	if err := c.BodyParser(&transfer); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if transfer.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Quantity must be greater than 0",
+		})
+	}
+
+	product := models.Product{}
+	db.First(&product, transfer.ProductID)
+
+	if product.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + strconv.Itoa(int(transfer.ProductID)),
+		})
+	}
+
+	for _, id := range []uint{transfer.FromWarehouseID, transfer.ToWarehouseID} {
+		warehouse := models.Warehouse{}
+		db.First(&warehouse, id)
+
+		if warehouse.ID == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Warehouse not found with id " + strconv.Itoa(int(id)),
+			})
+		}
+	}
+
+	transfer.ID = 0
+	if actorID := utils.CurrentUserID(c); actorID != 0 {
+		transfer.ActorID = &actorID
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return inventory.Transfer(tx, &transfer)
+	})
+	if err != nil {
+		return StockErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(StockTransferResponse(transfer))
+}
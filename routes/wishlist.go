@@ -0,0 +1,364 @@
+package routes
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rama-kairi/fiber-api/database"
+	"github.com/rama-kairi/fiber-api/inventory"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/routes/utils"
+	"gorm.io/gorm"
+)
+
+// WishlistResponse - a wishlist with its products. Shared wishlists only show
+// the products customers can see.
+func WishlistResponse(c *fiber.Ctx, list models.Wishlist, shared bool) map[string]interface{} {
+	var items []models.WishlistItem
+	database.Database.Db.Joins("Product").Where("wishlist_id = ?", list.ID).Order("wishlist_items.id").Find(&items)
+
+	products := []map[string]interface{}{}
+	for _, item := range items {
+		if item.Product.ID == 0 || item.Product.DeletedAt.Valid || (shared && item.Product.Status != models.ProductPublished) {
+			continue
+		}
+
+		product := ProductResponse(c, item.Product)
+		product["added_at"] = item.CreatedAt
+		products = append(products, product)
+	}
+
+	response := map[string]interface{}{
+		"id":       list.ID,
+		"name":     list.Name,
+		"products": products,
+	}
+
+	if !shared {
+		response["created_at"] = list.CreatedAt
+		response["updated_at"] = list.UpdatedAt
+		response["share_url"] = nil
+		if list.ShareToken != nil {
+			response["share_url"] = c.BaseURL() + "/api/wishlists/shared/" + *list.ShareToken
+		}
+	}
+
+	return response
+}
+
+// ownWishlist - the wishlist of the route owned by the current user
+func ownWishlist(c *fiber.Ctx) models.Wishlist {
+	list := models.Wishlist{}
+	database.Database.Db.Where("user_id = ?", utils.CurrentUserID(c)).First(&list, c.Params("id"))
+	return list
+}
+
+func wishlistNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error": "Wishlist not found with id " + c.Params("id"),
+	})
+}
+
+// GetWishlists - returns the wishlists of the current user
+func GetWishlists(c *fiber.Ctx) error {
+	var lists []models.Wishlist
+
+	database.Database.Db.Where("user_id = ?", utils.CurrentUserID(c)).Order("id").Find(&lists)
+
+	responseLists := make([]map[string]interface{}, len(lists))
+
+	for i, list := range lists {
+		responseLists[i] = WishlistResponse(c, list, false)
+	}
+
+	return c.JSON(responseLists)
+}
+
+// CreateWishlist - adds a named wishlist for the current user
+func CreateWishlist(c *fiber.Ctx) error {
+	list := models.Wishlist{}
+
+	if err := c.BodyParser(&list); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	list = models.Wishlist{
+		UserID: utils.CurrentUserID(c),
+		Name:   strings.TrimSpace(list.Name),
+	}
+
+	if list.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name is required",
+		})
+	}
+
+	if err := database.Database.Db.Create(&list).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Wishlist already exists with name " + list.Name,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(WishlistResponse(c, list, false))
+}
+
+// GetWishlist - returns a wishlist of the current user
+func GetWishlist(c *fiber.Ctx) error {
+	list := ownWishlist(c)
+
+	if list.ID == 0 {
+		return wishlistNotFound(c)
+	}
+
+	return c.JSON(WishlistResponse(c, list, false))
+}
+
+// RenameWishlist - changes the name of a wishlist
+func RenameWishlist(c *fiber.Ctx) error {
+	type wishlistUpdate struct {
+		Name string `json:"name"`
+	}
+
+	list := ownWishlist(c)
+
+	if list.ID == 0 {
+		return wishlistNotFound(c)
+	}
+
+	listJson := new(wishlistUpdate)
+	if err := c.BodyParser(&listJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	list.Name = strings.TrimSpace(listJson.Name)
+	if list.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name is required",
+		})
+	}
+
+	if err := database.Database.Db.Save(&list).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Wishlist already exists with name " + list.Name,
+		})
+	}
+
+	return c.JSON(WishlistResponse(c, list, false))
+}
+
+// DeleteWishlist - removes a wishlist and its items
+func DeleteWishlist(c *fiber.Ctx) error {
+	list := ownWishlist(c)
+
+	if list.ID == 0 {
+		return wishlistNotFound(c)
+	}
+
+	// Names are unique per user, so wishlists are removed instead of soft deleted
+	err := database.Database.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("wishlist_id = ?", list.ID).Delete(&models.WishlistItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&list).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// AddWishlistItem - saves a product to a wishlist
+func AddWishlistItem(c *fiber.Ctx) error {
+	type wishlistItemCreate struct {
+		ProductID uint `json:"product_id"`
+	}
+
+	db := database.Database.Db
+	list := ownWishlist(c)
+
+	if list.ID == 0 {
+		return wishlistNotFound(c)
+	}
+
+	itemJson := new(wishlistItemCreate)
+	if err := c.BodyParser(&itemJson); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	product := models.Product{}
+	db.First(&product, itemJson.ProductID)
+
+	if product.ID == 0 || product.Status != models.ProductPublished {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found with id " + strconv.Itoa(int(itemJson.ProductID)),
+		})
+	}
+
+	item := models.WishlistItem{
+		WishlistID: list.ID,
+		ProductID:  product.ID,
+		Price:      product.Price,
+	}
+
+	if err := db.Create(&item).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Product is already on the wishlist",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(WishlistResponse(c, list, false))
+}
+
+// RemoveWishlistItem - takes a product off a wishlist
+func RemoveWishlistItem(c *fiber.Ctx) error {
+	list := ownWishlist(c)
+
+	if list.ID == 0 {
+		return wishlistNotFound(c)
+	}
+
+	result := database.Database.Db.Unscoped().
+		Where("wishlist_id = ? AND product_id = ?", list.ID, c.Params("productId")).
+		Delete(&models.WishlistItem{})
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product " + c.Params("productId") + " is not on the wishlist",
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{})
+}
+
+// MoveWishlistItemToCart - turns a wishlist product into an order item, ready
+// to be placed with an order, and takes it off the wishlist
+func MoveWishlistItemToCart(c *fiber.Ctx) error {
+	type cartMove struct {
+		Quantity int `json:"quantity"`
+	}
+
+	db := database.Database.Db
+	list := ownWishlist(c)
+
+	if list.ID == 0 {
+		return wishlistNotFound(c)
+	}
+
+	moveJson := cartMove{Quantity: 1}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&moveJson); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	if moveJson.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Quantity must be greater than 0",
+		})
+	}
+
+	item := models.WishlistItem{}
+	db.Joins("Product").Where("wishlist_id = ? AND product_id = ?", list.ID, c.Params("productId")).Limit(1).Find(&item)
+
+	if item.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product " + c.Params("productId") + " is not on the wishlist",
+		})
+	}
+
+	if item.Product.ID == 0 || item.Product.DeletedAt.Valid || item.Product.Status != models.ProductPublished {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Product is not available",
+		})
+	}
+
+	orderItem, err := pricedOrderItem(db, item.Product, moveJson.Quantity, RequestCurrency(c))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Checking the stock, it is only taken out once the order is placed
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := inventory.Check(tx, []models.OrderItem{orderItem}); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&orderItem).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&item).Error
+	})
+	if err != nil {
+		return StockErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(OrderItemsResponse(orderItem, item.Product))
+}
+
+// ShareWishlist - makes a wishlist readable through a share link, keeping the
+// link it already has
+func ShareWishlist(c *fiber.Ctx) error {
+	list := ownWishlist(c)
+
+	if list.ID == 0 {
+		return wishlistNotFound(c)
+	}
+
+	if list.ShareToken == nil {
+		token, err := utils.RandomToken()
+		if err == nil {
+			list.ShareToken = &token
+			err = database.Database.Db.Model(&list).Update("share_token", token).Error
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(WishlistResponse(c, list, false))
+}
+
+// UnshareWishlist - makes a wishlist private again, the old link stops working
+func UnshareWishlist(c *fiber.Ctx) error {
+	list := ownWishlist(c)
+
+	if list.ID == 0 {
+		return wishlistNotFound(c)
+	}
+
+	list.ShareToken = nil
+	database.Database.Db.Model(&list).Update("share_token", nil)
+
+	return c.JSON(WishlistResponse(c, list, false))
+}
+
+// GetSharedWishlist - returns a wishlist by its share token, without login
+func GetSharedWishlist(c *fiber.Ctx) error {
+	list := models.Wishlist{}
+	database.Database.Db.Where("share_token = ?", c.Params("token")).Limit(1).Find(&list)
+
+	if list.ID == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Wishlist not found",
+		})
+	}
+
+	return c.JSON(WishlistResponse(c, list, true))
+}
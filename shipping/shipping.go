@@ -0,0 +1,189 @@
+package shipping
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/rama-kairi/fiber-api/config"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNoZone - no shipping zone covers the address
+	ErrNoZone = errors.New("We do not ship to this address")
+	// ErrUnavailable - the method does not ship the order
+	ErrUnavailable = errors.New("Shipping method is not available for this order")
+)
+
+// Parcel - what is shipped for an order. Weight is the billable weight in
+// grams, Subtotal what is left of the items after discounts.
+type Parcel struct {
+	Weight    int
+	Subtotal  money.Amount
+	Shippable bool
+}
+
+// Rate - what a method charges for a parcel, in the currency of the order
+type Rate struct {
+	Method models.ShippingMethod
+	Cost   money.Amount
+}
+
+// NormalizeRegion - regions are kept upper case without surrounding spaces
+func NormalizeRegion(region string) string {
+	return strings.ToUpper(strings.TrimSpace(region))
+}
+
+// Zone - the zone shipping to the address, one for the region comes before
+// one for the whole country
+func Zone(db *gorm.DB, country string, region string) (models.ShippingZone, error) {
+	place := models.ShippingZoneRegion{}
+	if country != "" {
+		db.Where("country = ? AND region IN ?", country, []string{"", NormalizeRegion(region)}).
+			Order("region = ''").Limit(1).Find(&place)
+	}
+
+	zone := models.ShippingZone{}
+	if place.ID != 0 {
+		db.Preload("Methods", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+			Preload("Methods.Tiers").First(&zone, place.ZoneID)
+	}
+
+	if zone.ID == 0 {
+		return zone, ErrNoZone
+	}
+	return zone, nil
+}
+
+// UnitWeight - the billable weight of one of the product in grams, its
+// volumetric weight when that is more
+func UnitWeight(product models.Product) int {
+	weight := product.Weight
+
+	if divisor := config.GetConfig().Shipping.VolumetricDivisor; divisor > 0 {
+		// Cubic millimetres over cubic centimetres per kilogram are grams
+		volumetric := product.Length * product.Width * product.Height / divisor
+		if volumetric > weight {
+			weight = volumetric
+		}
+	}
+	return weight
+}
+
+// Measure - the parcel of the items. Digital products are not shipped.
+func Measure(db *gorm.DB, items []models.OrderItem) Parcel {
+	parcel := Parcel{}
+
+	for _, item := range items {
+		product := models.Product{}
+		db.Unscoped().First(&product, item.ProductID)
+
+		if product.IsDigital {
+			continue
+		}
+
+		parcel.Shippable = true
+		parcel.Weight += UnitWeight(product) * item.Quantity
+		parcel.Subtotal += item.Price - item.Discount
+	}
+	return parcel
+}
+
+// Cost - what the method charges for the parcel at the exchange rate, ok is
+// false when it can not ship it
+func Cost(method models.ShippingMethod, parcel Parcel, rate float64) (money.Amount, bool) {
+	switch method.Kind {
+	case models.ShippingFlat:
+		return method.Amount.Convert(rate), true
+
+	case models.ShippingFreeOver:
+		if parcel.Subtotal >= method.Threshold.Convert(rate) {
+			return 0, true
+		}
+		return method.Amount.Convert(rate), true
+
+	case models.ShippingWeight, models.ShippingPrice:
+		// Tiers start at a weight for weight methods, at a subtotal for price ones
+		reach := func(tier models.ShippingRateTier) money.Amount {
+			if method.Kind == models.ShippingWeight {
+				return money.Amount(tier.MinWeight)
+			}
+			return tier.MinSubtotal.Convert(rate)
+		}
+		reached := money.Amount(parcel.Weight)
+		if method.Kind == models.ShippingPrice {
+			reached = parcel.Subtotal
+		}
+
+		var best *models.ShippingRateTier
+		for i, tier := range method.Tiers {
+			if reach(tier) <= reached && (best == nil || reach(tier) > reach(*best)) {
+				best = &method.Tiers[i]
+			}
+		}
+
+		if best == nil {
+			return 0, false
+		}
+		return best.Cost.Convert(rate), true
+	}
+
+	return 0, false
+}
+
+// Quote - the methods shipping the parcel to the address with their cost,
+// cheapest first
+func Quote(db *gorm.DB, country string, region string, parcel Parcel, rate float64) ([]Rate, error) {
+	zone, err := Zone(db, country, region)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := []Rate{}
+	for _, method := range zone.Methods {
+		if cost, ok := Cost(method, parcel, rate); ok {
+			rates = append(rates, Rate{Method: method, Cost: cost})
+		}
+	}
+
+	sort.SliceStable(rates, func(a, b int) bool {
+		return rates[a].Cost < rates[b].Cost
+	})
+	return rates, nil
+}
+
+// Apply - sets the shipping of the order. The method it names is kept when it
+// ships the items to the order's address, without one the cheapest is taken.
+// Orders with nothing to ship are not charged for shipping, any other order
+// needs an address a zone covers.
+func Apply(db *gorm.DB, order *models.Order, items []models.OrderItem) error {
+	chosen := order.ShippingMethodID
+	order.ShippingMethodID = nil
+	order.ShippingMethod = ""
+	order.Shipping = 0
+
+	parcel := Measure(db, items)
+	if !parcel.Shippable {
+		return nil
+	}
+
+	rates, err := Quote(db, order.Country, order.Region, parcel, order.ExchangeRate)
+	if err != nil {
+		return err
+	}
+
+	for _, rate := range rates {
+		if chosen == nil || rate.Method.ID == *chosen {
+			id := rate.Method.ID
+			order.ShippingMethodID = &id
+			order.ShippingMethod = rate.Method.Name
+			order.Shipping = rate.Cost
+			return nil
+		}
+	}
+
+	return ErrUnavailable
+}
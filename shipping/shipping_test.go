@@ -0,0 +1,95 @@
+package shipping
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// shippingDB - a zone for the US with standard at 5.00 and express at 15.00,
+// and one for New York with courier at 8.00. Product 1 is a 500 g mug,
+// product 2 an e-book.
+func shippingDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	testdb.Env(t)
+
+	db := testdb.Open(t, &models.Product{}, &models.ShippingZone{}, &models.ShippingZoneRegion{},
+		&models.ShippingMethod{}, &models.ShippingRateTier{})
+
+	zones := []models.ShippingZone{
+		{
+			Name:    "United States",
+			Regions: []models.ShippingZoneRegion{{Country: "US"}},
+			Methods: []models.ShippingMethod{
+				{Name: "Express", Kind: models.ShippingFlat, Amount: 1500},
+				{Name: "Standard", Kind: models.ShippingFlat, Amount: 500},
+			},
+		},
+		{
+			Name:    "New York",
+			Regions: []models.ShippingZoneRegion{{Country: "US", Region: "NY"}},
+			Methods: []models.ShippingMethod{{Name: "Courier", Kind: models.ShippingFlat, Amount: 800}},
+		},
+	}
+	for _, zone := range zones {
+		if err := db.Create(&zone).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db.Create(&models.Product{Name: "Mug", Weight: 500})
+	db.Create(&models.Product{Name: "E-book", IsDigital: true})
+	return db
+}
+
+func TestApply(t *testing.T) {
+	// Method ids in the order shippingDB creates them
+	express, standard := uint(1), uint(2)
+
+	tests := []struct {
+		name    string
+		country string
+		region  string
+		method  *uint
+		// Products ordered, one of each
+		products []int
+		want     string
+		cost     money.Amount
+		err      error
+	}{
+		{"cheapest method", "US", "", nil, []int{1}, "Standard", 500, nil},
+		{"chosen method", "US", "", &express, []int{1}, "Express", 1500, nil},
+		{"region zone before the country", "US", "ny", nil, []int{1, 2}, "Courier", 800, nil},
+		{"method of another zone", "US", "NY", &standard, []int{1}, "", 0, ErrUnavailable},
+		{"unmatched country", "FR", "", nil, []int{1}, "", 0, ErrNoZone},
+		{"no country", "", "", nil, []int{1, 2}, "", 0, ErrNoZone},
+		{"nothing to ship", "FR", "", &express, []int{2}, "", 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := shippingDB(t)
+
+			items := make([]models.OrderItem, len(test.products))
+			for i, productID := range test.products {
+				items[i] = models.OrderItem{ProductID: productID, Quantity: 1, Price: 1000}
+			}
+
+			order := models.Order{Country: test.country, Region: test.region, ExchangeRate: 1, ShippingMethodID: test.method}
+			if err := Apply(db, &order, items); !errors.Is(err, test.err) {
+				t.Fatalf("Apply() = %v, want %v", err, test.err)
+			}
+
+			if order.ShippingMethod != test.want || order.Shipping != test.cost {
+				t.Errorf("shipping = %q at %s, want %q at %s", order.ShippingMethod, order.Shipping, test.want, test.cost)
+			}
+			if (order.ShippingMethodID != nil) != (test.want != "") {
+				t.Errorf("shipping method id = %v, want one only with a method", order.ShippingMethodID)
+			}
+		})
+	}
+}
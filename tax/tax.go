@@ -0,0 +1,148 @@
+package tax
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rama-kairi/fiber-api/config"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"gorm.io/gorm"
+)
+
+// Standard - the tax category of products without one
+const Standard = "standard"
+
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// ValidCountry - whether the country is a two letter code
+func ValidCountry(country string) bool {
+	return countryPattern.MatchString(country)
+}
+
+// NormalizeCategory - lower case without surrounding spaces, empty is standard
+func NormalizeCategory(category string) string {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if category == "" {
+		return Standard
+	}
+	return category
+}
+
+// Country - the country an order is taxed in, the configured one when the
+// order gives none
+func Country(country string) string {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if country == "" {
+		return config.GetConfig().Tax.Country
+	}
+	return country
+}
+
+// Lookup - the rate for a category in a jurisdiction. A rate for the region
+// comes before one for the whole country, a rate for the category before the
+// one for every category. Found is false when nothing is taxed.
+func Lookup(db *gorm.DB, country string, region string, category string) (models.TaxRate, bool) {
+	rate := models.TaxRate{}
+	if country == "" {
+		return rate, false
+	}
+
+	db.Where("country = ? AND region IN ? AND category IN ?", country, []string{"", strings.ToUpper(strings.TrimSpace(region))}, []string{"", category}).
+		Order("region = '', category = ''").Limit(1).Find(&rate)
+
+	return rate, rate.ID != 0
+}
+
+// Breakdown - the tax of an order, one line per rate
+type Breakdown []models.OrderTax
+
+// Total - all the tax of the order
+func (b Breakdown) Total() money.Amount {
+	var total money.Amount
+	for _, line := range b {
+		total += line.Amount
+	}
+	return total
+}
+
+// Added - the tax charged on top of the item prices, from exclusive rates
+func (b Breakdown) Added() money.Amount {
+	var added money.Amount
+	for _, line := range b {
+		if !line.Inclusive {
+			added += line.Amount
+		}
+	}
+	return added
+}
+
+// Compute - taxes what is left of every item after its discount, in the
+// jurisdiction of the order, setting its tax and rate. Tax is rounded per
+// item, half away from zero, and the breakdown adds the items up by rate.
+func Compute(db *gorm.DB, order models.Order, items []models.OrderItem) Breakdown {
+	breakdown := Breakdown{}
+	lines := map[uint]int{}
+
+	for i := range items {
+		item := &items[i]
+		item.Tax, item.TaxRate = 0, 0
+
+		product := models.Product{}
+		db.Unscoped().First(&product, item.ProductID)
+
+		rate, found := Lookup(db, order.Country, order.Region, NormalizeCategory(product.TaxCategory))
+		if !found {
+			continue
+		}
+
+		taxable := item.Price - item.Discount
+		item.TaxRate = rate.Rate
+		if rate.Inclusive {
+			item.Tax = taxable.PercentIncluded(rate.Rate)
+		} else {
+			item.Tax = taxable.Percent(rate.Rate)
+		}
+
+		line, ok := lines[rate.ID]
+		if !ok {
+			line = len(breakdown)
+			lines[rate.ID] = line
+			breakdown = append(breakdown, models.OrderTax{
+				TaxRateID: rate.ID,
+				Name:      rate.Name,
+				Country:   rate.Country,
+				Region:    rate.Region,
+				Category:  rate.Category,
+				Rate:      rate.Rate,
+				Inclusive: rate.Inclusive,
+			})
+		}
+		breakdown[line].Taxable += taxable
+		breakdown[line].Amount += item.Tax
+	}
+	return breakdown
+}
+
+// Record - replaces the tax breakdown of the order
+func Record(tx *gorm.DB, orderID uint, breakdown Breakdown) error {
+	if err := tx.Unscoped().Where("order_id = ?", orderID).Delete(&models.OrderTax{}).Error; err != nil {
+		return err
+	}
+
+	for i := range breakdown {
+		breakdown[i].ID = 0
+		breakdown[i].OrderID = orderID
+		if err := tx.Create(&breakdown[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OrderBreakdown - the stored tax breakdown of an order
+func OrderBreakdown(db *gorm.DB, orderID uint) Breakdown {
+	breakdown := Breakdown{}
+	db.Where("order_id = ?", orderID).Order("id").Find(&breakdown)
+	return breakdown
+}
@@ -0,0 +1,139 @@
+package tax
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+)
+
+func TestCompute(t *testing.T) {
+	type item struct {
+		category string
+		price    money.Amount
+		discount money.Amount
+	}
+
+	standard := models.TaxRate{Country: "DE", Name: "VAT", Rate: 19, Inclusive: true}
+	reduced := models.TaxRate{Country: "DE", Category: "books", Name: "Reduced VAT", Rate: 7, Inclusive: true}
+	state := models.TaxRate{Country: "US", Name: "Sales tax", Rate: 5}
+	city := models.TaxRate{Country: "US", Region: "NY", Name: "NY sales tax", Rate: 8.875}
+
+	tests := []struct {
+		name    string
+		rates   []models.TaxRate
+		country string
+		region  string
+		items   []item
+		// Tax of each item, then the total and what is added on top
+		want  []money.Amount
+		total money.Amount
+		added money.Amount
+		lines int
+	}{
+		{
+			name:    "no country is not taxed",
+			rates:   []models.TaxRate{standard},
+			country: "",
+			items:   []item{{"", 1000, 0}},
+			want:    []money.Amount{0},
+		},
+		{
+			name:    "no rate for the country",
+			rates:   []models.TaxRate{standard},
+			country: "FR",
+			items:   []item{{"", 1000, 0}},
+			want:    []money.Amount{0},
+		},
+		{
+			name:    "inclusive rate is part of the price",
+			rates:   []models.TaxRate{standard},
+			country: "DE",
+			items:   []item{{"", 1190, 0}},
+			want:    []money.Amount{190},
+			total:   190,
+			lines:   1,
+		},
+		{
+			name:    "exclusive rate after the discount",
+			rates:   []models.TaxRate{state},
+			country: "US",
+			items:   []item{{"", 1000, 100}},
+			want:    []money.Amount{45},
+			total:   45,
+			added:   45,
+			lines:   1,
+		},
+		{
+			name:    "region rate before the country rate",
+			rates:   []models.TaxRate{state, city},
+			country: "US",
+			region:  "ny",
+			items:   []item{{"", 1000, 0}},
+			want:    []money.Amount{89},
+			total:   89,
+			added:   89,
+			lines:   1,
+		},
+		{
+			name:    "category rate before the one for every category",
+			rates:   []models.TaxRate{standard, reduced},
+			country: "DE",
+			items:   []item{{"Books", 1070, 0}, {"", 1190, 0}},
+			want:    []money.Amount{70, 190},
+			total:   260,
+			lines:   2,
+		},
+		{
+			name:    "items at one rate share a line, rounded per item",
+			rates:   []models.TaxRate{state},
+			country: "US",
+			items:   []item{{"", 10, 0}, {"", 10, 0}, {"", 10, 0}},
+			want:    []money.Amount{1, 1, 1},
+			total:   3,
+			added:   3,
+			lines:   1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := testdb.Open(t, &models.Product{}, &models.TaxRate{})
+
+			for _, rate := range test.rates {
+				if err := db.Create(&rate).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			items := make([]models.OrderItem, len(test.items))
+			for i, line := range test.items {
+				product := models.Product{Name: fmt.Sprintf("Product %d", i), TaxCategory: line.category}
+				if err := db.Create(&product).Error; err != nil {
+					t.Fatal(err)
+				}
+				items[i] = models.OrderItem{ProductID: int(product.ID), Quantity: 1, Price: line.price, Discount: line.discount}
+			}
+
+			order := models.Order{Country: test.country, Region: test.region}
+			breakdown := Compute(db, order, items)
+
+			for i := range items {
+				if items[i].Tax != test.want[i] {
+					t.Errorf("item %d tax = %s, want %s", i, items[i].Tax, test.want[i])
+				}
+			}
+			if breakdown.Total() != test.total {
+				t.Errorf("total = %s, want %s", breakdown.Total(), test.total)
+			}
+			if breakdown.Added() != test.added {
+				t.Errorf("added = %s, want %s", breakdown.Added(), test.added)
+			}
+			if len(breakdown) != test.lines {
+				t.Errorf("lines = %d, want %d", len(breakdown), test.lines)
+			}
+		})
+	}
+}
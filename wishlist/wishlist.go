@@ -0,0 +1,83 @@
+package wishlist
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"github.com/rama-kairi/fiber-api/notify"
+	"gorm.io/gorm"
+)
+
+// change - a wishlist item whose product price moved since it was last seen
+type change struct {
+	ItemID    uint
+	UserID    uint
+	Email     string
+	ProductID uint
+	Name      string
+	OldPrice  money.Amount
+	NewPrice  money.Amount
+}
+
+// CheckPrices - tells owners about products on their wishlists that became
+// cheaper, once per user and product, and remembers the current price of
+// every item that changed so the next drop is measured from it.
+func CheckPrices(db *gorm.DB, notifier notify.Notifier) error {
+	var changes []change
+	if err := db.Table("wishlist_items").
+		Select("wishlist_items.id AS item_id, wishlists.user_id, users.email, products.id AS product_id, products.name, wishlist_items.price_minor AS old_price, products.price_minor AS new_price").
+		Joins("JOIN wishlists ON wishlists.id = wishlist_items.wishlist_id AND wishlists.deleted_at IS NULL").
+		Joins("JOIN users ON users.id = wishlists.user_id AND users.deleted_at IS NULL").
+		Joins("JOIN products ON products.id = wishlist_items.product_id AND products.deleted_at IS NULL").
+		Where("wishlist_items.deleted_at IS NULL AND products.price_minor <> wishlist_items.price_minor").
+		Order("wishlist_items.id").
+		Scan(&changes).Error; err != nil {
+		return err
+	}
+
+	notified := map[string]bool{}
+	for _, c := range changes {
+		key := fmt.Sprintf("%d:%d", c.UserID, c.ProductID)
+		if c.NewPrice < c.OldPrice && !notified[key] {
+			notified[key] = true
+
+			if err := notifier.Notify(notify.Message{
+				Event:   "price_drop",
+				To:      c.Email,
+				Subject: fmt.Sprintf("Price drop: %s", c.Name),
+				Body:    fmt.Sprintf("%s on your wishlist is now %s, down from %s.", c.Name, c.NewPrice, c.OldPrice),
+				Data: map[string]interface{}{
+					"user_id":    c.UserID,
+					"product_id": c.ProductID,
+					"old_price":  c.OldPrice,
+					"new_price":  c.NewPrice,
+				},
+			}); err != nil {
+				log.Println("Failed to send price drop notification: ", err.Error())
+			}
+		}
+
+		if err := db.Model(&models.WishlistItem{}).Where("id = ?", c.ItemID).UpdateColumn("price_minor", c.NewPrice).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartPriceAlerts - checks the wishlists for price drops in the background.
+func StartPriceAlerts(db *gorm.DB, notifier notify.Notifier, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := CheckPrices(db, notifier); err != nil {
+				log.Println("Failed to check wishlist prices: ", err.Error())
+			}
+			<-ticker.C
+		}
+	}()
+}
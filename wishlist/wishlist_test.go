@@ -0,0 +1,76 @@
+package wishlist
+
+import (
+	"testing"
+
+	"github.com/rama-kairi/fiber-api/internal/testdb"
+	"github.com/rama-kairi/fiber-api/models"
+	"github.com/rama-kairi/fiber-api/money"
+	"github.com/rama-kairi/fiber-api/notify"
+)
+
+// recorder - a notifier keeping what it was given
+type recorder struct {
+	sent []notify.Message
+}
+
+func (r *recorder) Notify(msg notify.Message) error {
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func TestCheckPrices(t *testing.T) {
+	db := testdb.Open(t, &models.User{}, &models.Product{}, &models.Wishlist{}, &models.WishlistItem{})
+
+	db.Create(&models.User{Email: "ann@example.com"})
+	db.Create(&models.User{Email: "bob@example.com"})
+	db.Create(&models.Product{Name: "Mug", Price: 1000})
+
+	// Ann has the mug on two wishlists, Bob on one
+	for _, wishlist := range []models.Wishlist{{UserID: 1, Name: "Kitchen"}, {UserID: 1, Name: "Gifts"}, {UserID: 2, Name: "Kitchen"}} {
+		db.Create(&wishlist)
+		db.Create(&models.WishlistItem{WishlistID: wishlist.ID, ProductID: 1, Price: 1000})
+	}
+
+	steps := []struct {
+		name  string
+		price money.Amount
+		// Who was told about a drop
+		want []string
+	}{
+		{"unchanged", 1000, nil},
+		{"price goes up", 1200, nil},
+		{"drop from the last price", 1100, []string{"ann@example.com", "bob@example.com"}},
+		{"already told", 1100, nil},
+		{"drops again", 800, []string{"ann@example.com", "bob@example.com"}},
+	}
+
+	for _, step := range steps {
+		db.Model(&models.Product{}).Where("id = ?", 1).Update("price_minor", step.price)
+
+		notifier := &recorder{}
+		if err := CheckPrices(db, notifier); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(notifier.sent) != len(step.want) {
+			t.Fatalf("%s: sent %d notifications, want %v", step.name, len(notifier.sent), step.want)
+		}
+		for i, msg := range notifier.sent {
+			if msg.To != step.want[i] || msg.Event != "price_drop" {
+				t.Errorf("%s: sent %s to %s, want price_drop to %s", step.name, msg.Event, msg.To, step.want[i])
+			}
+		}
+
+		var prices []money.Amount
+		db.Model(&models.WishlistItem{}).Pluck("price_minor", &prices)
+		if len(prices) != 3 {
+			t.Fatalf("%s: %d wishlist items, want 3", step.name, len(prices))
+		}
+		for _, price := range prices {
+			if price != step.price {
+				t.Errorf("%s: wishlist price = %v, want it moved to %v", step.name, price, step.price)
+			}
+		}
+	}
+}